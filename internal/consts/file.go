@@ -14,7 +14,11 @@ const (
 	// открыть для чтения и записи (O_RDWR)
 	CreateIfNotExists = os.O_CREATE | os.O_EXCL | os.O_RDWR
 
-	DataExtension = ".data"
-	JsonExtension = ".json"
+	DataExtension  = ".data"
+	JsonExtension  = ".json"
+	IndexExtension = ".index"
+	WalExtension   = ".wal"
+	FsmExtension   = ".fsm"
+	// страницы переполнения, в PostgreSQL похожее хранилище называется TOAST
+	OverflowExtension = ".toast"
 )
-
@@ -0,0 +1,57 @@
+package decimal
+
+import (
+	"fmt"
+	"math/big"
+)
+
+/*
+Двоичная форма числа фиксированного масштаба - unscaled в дополнительном
+коде big-endian ширины Size(precision) с инвертированным знаковым битом.
+Такие значения одной ширины сравниваются побайтово в порядке чисел,
+поэтому годятся и для ключей индекса.
+*/
+
+func ErrPrecisionOverflow(value Decimal, precision int) error {
+	return fmt.Errorf("decimal %s doesnt fit into precision %d", value, precision)
+}
+
+// размер в байтах двоичной формы чисел точности precision
+func Size(precision int) int {
+	maxUnscaled := new(big.Int).Sub(pow10(precision), big.NewInt(1))
+	// плюс знаковый бит
+	return (maxUnscaled.BitLen() + 1 + 7) / 8
+}
+
+// дописывает двоичную форму числа. масштаб числа
+// должен совпадать с масштабом колонки
+func AppendBinary(dst []byte, value Decimal, precision int) ([]byte, error) {
+	if !value.Fits(precision) {
+		return nil, ErrPrecisionOverflow(value, precision)
+	}
+
+	size := Size(precision)
+
+	twos := value.Unscaled()
+	if twos.Sign() < 0 {
+		twos.Add(twos, new(big.Int).Lsh(big.NewInt(1), uint(size*8)))
+	}
+
+	encoded := twos.FillBytes(make([]byte, size))
+	encoded[0] ^= 0x80
+
+	return append(dst, encoded...), nil
+}
+
+func FromBinary(data []byte, scale int) Decimal {
+	twos := make([]byte, len(data))
+	copy(twos, data)
+	twos[0] ^= 0x80
+
+	unscaled := new(big.Int).SetBytes(twos)
+	if twos[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+
+	return Decimal{unscaled: unscaled, scale: scale}
+}
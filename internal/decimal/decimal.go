@@ -0,0 +1,180 @@
+package decimal
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// наибольшая точность: значения помещаются в 16 байт
+const MaxPrecision = 38
+
+var ErrInvalidSyntax = errors.New("invalid decimal syntax")
+
+func ErrInvalidPrecision(precision int) error {
+	return fmt.Errorf("decimal precision %d is out of range from 1 to %d", precision, MaxPrecision)
+}
+
+/*
+Точное десятичное число unscaled * 10^-scale.
+
+Значение неизменяемое: операции возвращают новое число.
+Нулевое значение Decimal - это 0.
+*/
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+func New(unscaled int64, scale int) Decimal {
+	return Decimal{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+func NewFromBigInt(unscaled *big.Int, scale int) Decimal {
+	return Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+}
+
+// разбирает число вида -123.45. масштаб - количество цифр после точки
+func Parse(s string) (Decimal, error) {
+	digits := s
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(digits, ".")
+	if len(integer)+len(fraction) == 0 || !isDigits(integer) || !isDigits(fraction) {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidSyntax, s)
+	}
+
+	unscaled, ok := new(big.Int).SetString(integer+fraction, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidSyntax, s)
+	}
+
+	if strings.HasPrefix(s, "-") {
+		unscaled.Neg(unscaled)
+	}
+
+	return Decimal{unscaled: unscaled, scale: len(fraction)}, nil
+}
+
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+func (d Decimal) Unscaled() *big.Int {
+	return new(big.Int).Set(d.bigInt())
+}
+
+func (d Decimal) Sign() int {
+	return d.bigInt().Sign()
+}
+
+// количество значащих цифр, у нуля - одна
+func (d Decimal) Precision() int {
+	if d.Sign() == 0 {
+		return 1
+	}
+
+	return len(new(big.Int).Abs(d.bigInt()).String())
+}
+
+// число помещается в decimal(precision, scale) без округления
+// целой части
+func (d Decimal) Fits(precision int) bool {
+	return d.Precision() <= precision
+}
+
+// число с другим масштабом. лишние цифры округляются
+// половиной от нуля
+func (d Decimal) Rescale(scale int) Decimal {
+	if scale >= d.scale {
+		unscaled := new(big.Int).Mul(d.bigInt(), pow10(scale-d.scale))
+		return Decimal{unscaled: unscaled, scale: scale}
+	}
+
+	divisor := pow10(d.scale - scale)
+	quotient, remainder := new(big.Int).QuoRem(
+		new(big.Int).Abs(d.bigInt()),
+		divisor,
+		new(big.Int),
+	)
+
+	if remainder.Lsh(remainder, 1).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+
+	if d.Sign() < 0 {
+		quotient.Neg(quotient)
+	}
+
+	return Decimal{unscaled: quotient, scale: scale}
+}
+
+func (d Decimal) Cmp(other Decimal) int {
+	scale := max(d.scale, other.scale)
+	return d.Rescale(scale).bigInt().Cmp(other.Rescale(scale).bigInt())
+}
+
+// числа равны независимо от масштаба: 1.50 == 1.5
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.bigInt()).String()
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+
+	var builder strings.Builder
+	if d.Sign() < 0 {
+		builder.WriteByte('-')
+	}
+
+	builder.WriteString(digits[:len(digits)-d.scale])
+	if d.scale > 0 {
+		builder.WriteByte('.')
+		builder.WriteString(digits[len(digits)-d.scale:])
+	}
+
+	return builder.String()
+}
+
+// в json число хранится строкой, чтобы не терять точность
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) bigInt() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+
+	return d.unscaled
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,140 @@
+package decimal
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal_Parse(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		scale    int
+	}{
+		{input: "0", expected: "0", scale: 0},
+		{input: "-0.00", expected: "0.00", scale: 2},
+		{input: "123.450", expected: "123.450", scale: 3},
+		{input: "+1", expected: "1", scale: 0},
+		{input: "-.5", expected: "-0.5", scale: 1},
+		{input: "7.", expected: "7", scale: 0},
+		{input: "-0.0001", expected: "-0.0001", scale: 4},
+		{input: "99999999999999999999999999999999999999", expected: "99999999999999999999999999999999999999", scale: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			value, err := Parse(tc.input)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value.String())
+			assert.Equal(t, tc.scale, value.Scale())
+		})
+	}
+
+	t.Run("некорректные числа", func(t *testing.T) {
+		for _, input := range []string{"", "-", ".", "1.2.3", "1e5", "abc", " 1", "--1"} {
+			_, err := Parse(input)
+			assert.ErrorIs(t, err, ErrInvalidSyntax, input)
+		}
+	})
+}
+
+func TestDecimal_Rescale(t *testing.T) {
+	testCases := []struct {
+		input    string
+		scale    int
+		expected string
+	}{
+		{input: "1.5", scale: 3, expected: "1.500"},
+		{input: "1.005", scale: 2, expected: "1.01"},
+		{input: "1.004", scale: 2, expected: "1.00"},
+		{input: "-1.005", scale: 2, expected: "-1.01"},
+		{input: "-2.5", scale: 0, expected: "-3"},
+		{input: "0.4", scale: 0, expected: "0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			value, err := Parse(tc.input)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value.Rescale(tc.scale).String())
+		})
+	}
+}
+
+func TestDecimal_Cmp(t *testing.T) {
+	assert.True(t, mustParse(t, "1.50").Equal(mustParse(t, "1.5")))
+	assert.Equal(t, -1, mustParse(t, "-10").Cmp(mustParse(t, "9.99")))
+	assert.Equal(t, 1, mustParse(t, "0.001").Cmp(Decimal{}))
+	assert.Equal(t, "0", Decimal{}.String())
+}
+
+func TestDecimal_Binary(t *testing.T) {
+	t.Run("размер по точности", func(t *testing.T) {
+		assert.Equal(t, 1, Size(1))
+		assert.Equal(t, 2, Size(4))
+		assert.Equal(t, 3, Size(5))
+		assert.Equal(t, 8, Size(18))
+		assert.Equal(t, 16, Size(MaxPrecision))
+	})
+
+	t.Run("порядок байт совпадает с порядком чисел", func(t *testing.T) {
+		const (
+			precision = 6
+			scale     = 2
+		)
+
+		values := []string{"-9999.99", "-100.00", "-0.01", "0.00", "0.01", "1.00", "100.50", "9999.99"}
+
+		encoded := make([][]byte, 0, len(values))
+		for _, input := range values {
+			value := mustParse(t, input)
+
+			serialized, err := AppendBinary(nil, value, precision)
+			require.NoError(t, err)
+			require.Equal(t, Size(precision), len(serialized))
+			encoded = append(encoded, serialized)
+
+			assert.Equal(t, input, FromBinary(serialized, scale).String())
+		}
+
+		for i := 1; i < len(encoded); i++ {
+			assert.Equal(t, -1, bytes.Compare(encoded[i-1], encoded[i]), values[i])
+		}
+	})
+
+	t.Run("наибольшая точность", func(t *testing.T) {
+		for _, input := range []string{
+			"99999999999999999999999999999999999999",
+			"-99999999999999999999999999999999999999",
+		} {
+			serialized, err := AppendBinary(nil, mustParse(t, input), MaxPrecision)
+			require.NoError(t, err)
+			assert.Equal(t, input, FromBinary(serialized, 0).String())
+		}
+	})
+
+	t.Run("число не помещается в точность", func(t *testing.T) {
+		_, err := AppendBinary(nil, mustParse(t, "1000.00"), 5)
+		assert.EqualError(t, err, "decimal 1000.00 doesnt fit into precision 5")
+	})
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	marshalled, err := json.Marshal(mustParse(t, "-12.340"))
+	require.NoError(t, err)
+	assert.Equal(t, `"-12.340"`, string(marshalled))
+
+	var value Decimal
+	require.NoError(t, json.Unmarshal(marshalled, &value))
+	assert.Equal(t, "-12.340", value.String())
+}
+
+func mustParse(t *testing.T, input string) Decimal {
+	value, err := Parse(input)
+	require.NoError(t, err)
+	return value
+}
@@ -0,0 +1,19 @@
+package index
+
+import "fmt"
+
+func ErrKeyTooLarge(keyLen int) error {
+	return fmt.Errorf("index key size %d exceeds max size %d", keyLen, MaxKeySize)
+}
+
+func ErrEntryNotFound() error {
+	return fmt.Errorf("index entry not found")
+}
+
+func ErrEntryExists() error {
+	return fmt.Errorf("index entry already exists")
+}
+
+func ErrInvalidIndexFile() error {
+	return fmt.Errorf("file is not a valid index file")
+}
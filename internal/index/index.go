@@ -1,2 +1,411 @@
 package index
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+)
+
+/*
+Файл индекса - B+дерево, состоящее из страниц размера базы:
+
+Страница 0 - метаданные (magic + номер корневой страницы)
+Страницы 1..N - узлы дерева
+
+Листья хранят пары (ключ, расположение строки) и связаны в список
+слева направо, что позволяет делать range scan. Одинаковые ключи
+допускаются, записи упорядочены по (ключ, расположение строки).
+
+Удаление ленивое: записи удаляются из листьев без слияния узлов.
+Пустые листья остаются в списке и пропускаются при сканировании,
+место возвращается при пересоздании индекса.
+*/
+
+// размеры в байтах
+const (
+	// ограничение на размер ключа, чтобы в узел гарантированно
+	// помещалось несколько записей
+	MaxKeySize = 1024
+
+	// Magic (4) + Root (4)
+	metaSize = 4 + 4
+)
+
+const metaPageNum uint32 = 0
+
+var indexMagic = []byte("SDBI")
+
+// хранилище страниц индекса. *os.File удовлетворяет интерфейсу
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	Stat() (os.FileInfo, error)
+}
+
+// граница диапазона при поиске по индексу
+type Bound struct {
+	Key       []byte
+	Inclusive bool
+}
+
+type BTree struct {
+	file     File
+	pageSize int
+}
+
+// размечает пустой файл под индекс: страница метаданных и пустой корень
+func Create(file File, pageSize int) (*BTree, error) {
+	tree := &BTree{file: file, pageSize: pageSize}
+
+	root := newLeafNode(metaPageNum + 1)
+	if err := tree.writeNode(root); err != nil {
+		return nil, fmt.Errorf("BTree.writeNode: %w", err)
+	}
+
+	if err := tree.writeRoot(root.PageNum); err != nil {
+		return nil, fmt.Errorf("BTree.writeRoot: %w", err)
+	}
+
+	return tree, nil
+}
+
+func Open(file File, pageSize int) (*BTree, error) {
+	tree := &BTree{file: file, pageSize: pageSize}
+
+	if _, err := tree.readRoot(); err != nil {
+		return nil, fmt.Errorf("BTree.readRoot: %w", err)
+	}
+
+	return tree, nil
+}
+
+func (t *BTree) Insert(key []byte, location Location) error {
+	if len(key) > MaxKeySize {
+		return ErrKeyTooLarge(len(key))
+	}
+
+	rootPageNum, err := t.readRoot()
+	if err != nil {
+		return fmt.Errorf("BTree.readRoot: %w", err)
+	}
+
+	separator, err := t.insert(rootPageNum, &entry{
+		Key:      key,
+		Location: location,
+	})
+	if err != nil {
+		return fmt.Errorf("BTree.insert: %w", err)
+	}
+
+	if separator == nil {
+		return nil
+	}
+
+	// корень разделился - дерево растет на один уровень
+	newRootPageNum, err := t.allocatePage()
+	if err != nil {
+		return fmt.Errorf("BTree.allocatePage: %w", err)
+	}
+
+	newRoot := newInternalNode(newRootPageNum, rootPageNum)
+	newRoot.Entries = append(newRoot.Entries, separator)
+
+	if err := t.writeNode(newRoot); err != nil {
+		return fmt.Errorf("BTree.writeNode: %w", err)
+	}
+
+	if err := t.writeRoot(newRootPageNum); err != nil {
+		return fmt.Errorf("BTree.writeRoot: %w", err)
+	}
+
+	return nil
+}
+
+// вставляет запись в поддерево. если узел пришлось разделить,
+// возвращает запись-разделитель для родителя
+func (t *BTree) insert(pageNum uint32, e *entry) (*entry, error) {
+	current, err := t.readNode(pageNum)
+	if err != nil {
+		return nil, fmt.Errorf("BTree.readNode: %w", err)
+	}
+
+	if current.IsLeaf() {
+		i := current.upperBound(e.Key, e.Location)
+		if i > 0 && compareEntries(
+			current.Entries[i-1].Key,
+			current.Entries[i-1].Location,
+			e.Key,
+			e.Location,
+		) == 0 {
+			return nil, ErrEntryExists()
+		}
+
+		current.insertAt(i, e)
+	} else {
+		childSeparator, err := t.insert(current.childFor(e.Key, e.Location), e)
+		if err != nil {
+			return nil, err
+		}
+
+		if childSeparator == nil {
+			return nil, nil
+		}
+
+		current.insertAt(
+			current.upperBound(childSeparator.Key, childSeparator.Location),
+			childSeparator,
+		)
+	}
+
+	if current.Fits(t.pageSize) {
+		if err := t.writeNode(current); err != nil {
+			return nil, fmt.Errorf("BTree.writeNode: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	rightPageNum, err := t.allocatePage()
+	if err != nil {
+		return nil, fmt.Errorf("BTree.allocatePage: %w", err)
+	}
+
+	right, separator := current.split(rightPageNum)
+
+	if err := t.writeNode(right); err != nil {
+		return nil, fmt.Errorf("BTree.writeNode: %w", err)
+	}
+
+	if err := t.writeNode(current); err != nil {
+		return nil, fmt.Errorf("BTree.writeNode: %w", err)
+	}
+
+	return separator, nil
+}
+
+func (t *BTree) Delete(key []byte, location Location) error {
+	leaf, err := t.findLeaf(key, location)
+	if err != nil {
+		return fmt.Errorf("BTree.findLeaf: %w", err)
+	}
+
+	i := leaf.upperBound(key, location)
+	if i == 0 || compareEntries(
+		leaf.Entries[i-1].Key,
+		leaf.Entries[i-1].Location,
+		key,
+		location,
+	) != 0 {
+		return ErrEntryNotFound()
+	}
+
+	leaf.removeAt(i - 1)
+
+	if err := t.writeNode(leaf); err != nil {
+		return fmt.Errorf("BTree.writeNode: %w", err)
+	}
+
+	return nil
+}
+
+// все расположения строк с заданным ключом
+func (t *BTree) Find(key []byte) ([]Location, error) {
+	bound := &Bound{Key: key, Inclusive: true}
+	return t.Range(bound, bound)
+}
+
+// расположения строк с ключами в диапазоне [lower, upper].
+// nil граница означает отсутствие ограничения с этой стороны
+func (t *BTree) Range(lower, upper *Bound) ([]Location, error) {
+	result := make([]Location, 0)
+
+	if err := t.Scan(lower, upper, func(_ []byte, location Location) bool {
+		result = append(result, location)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// обходит записи в диапазоне по возрастанию ключа,
+// пока fn возвращает true
+func (t *BTree) Scan(
+	lower, upper *Bound,
+	fn func(key []byte, location Location) bool,
+) error {
+	var startKey []byte
+	if lower != nil {
+		startKey = lower.Key
+	}
+
+	leaf, err := t.findLeaf(startKey, minLocation)
+	if err != nil {
+		return fmt.Errorf("BTree.findLeaf: %w", err)
+	}
+
+	for {
+		for _, e := range leaf.Entries {
+			if lower != nil {
+				cmp := bytes.Compare(e.Key, lower.Key)
+				if cmp < 0 || (cmp == 0 && !lower.Inclusive) {
+					continue
+				}
+			}
+
+			if upper != nil {
+				cmp := bytes.Compare(e.Key, upper.Key)
+				if cmp > 0 || (cmp == 0 && !upper.Inclusive) {
+					return nil
+				}
+			}
+
+			if !fn(e.Key, e.Location) {
+				return nil
+			}
+		}
+
+		if leaf.Next == nilPageNum {
+			return nil
+		}
+
+		leaf, err = t.readNode(leaf.Next)
+		if err != nil {
+			return fmt.Errorf("BTree.readNode: %w", err)
+		}
+	}
+}
+
+func (t *BTree) findLeaf(key []byte, location Location) (*node, error) {
+	pageNum, err := t.readRoot()
+	if err != nil {
+		return nil, fmt.Errorf("BTree.readRoot: %w", err)
+	}
+
+	for {
+		current, err := t.readNode(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("BTree.readNode: %w", err)
+		}
+
+		if current.IsLeaf() {
+			return current, nil
+		}
+
+		pageNum = current.childFor(key, location)
+	}
+}
+
+func (t *BTree) readRoot() (uint32, error) {
+	serialized, err := t.readPage(metaPageNum)
+	if err != nil {
+		return 0, fmt.Errorf("BTree.readPage: %w", err)
+	}
+
+	metaPage, err := page.DeserializePage(serialized)
+	if err != nil {
+		page.SetCorruptedPageNum(err, int64(metaPageNum))
+		return 0, fmt.Errorf("page.DeserializePage: %w", err)
+	}
+
+	if len(metaPage.Pointers) != 1 {
+		return 0, ErrInvalidIndexFile()
+	}
+
+	meta := metaPage.GetDataByPointer(metaPage.Pointers[0])
+	if len(meta) != metaSize || string(meta[:4]) != string(indexMagic) {
+		return 0, ErrInvalidIndexFile()
+	}
+
+	return binary.BigEndian.Uint32(meta[4:]), nil
+}
+
+func (t *BTree) writeRoot(rootPageNum uint32) error {
+	meta := make([]byte, metaSize)
+	copy(meta, indexMagic)
+	binary.BigEndian.PutUint32(meta[4:], rootPageNum)
+
+	metaPage := page.NewEmptyPage(t.pageSize)
+	if _, err := metaPage.Insert(meta); err != nil {
+		return fmt.Errorf("Page.Insert: %w", err)
+	}
+
+	if err := t.writePage(metaPageNum, metaPage.Serialize()); err != nil {
+		return fmt.Errorf("BTree.writePage: %w", err)
+	}
+
+	return nil
+}
+
+func (t *BTree) readNode(pageNum uint32) (*node, error) {
+	serialized, err := t.readPage(pageNum)
+	if err != nil {
+		return nil, fmt.Errorf("BTree.readPage: %w", err)
+	}
+
+	deserialized, err := deserializeNode(pageNum, serialized)
+	if err != nil {
+		return nil, fmt.Errorf("deserializeNode: %w", err)
+	}
+
+	return deserialized, nil
+}
+
+func (t *BTree) writeNode(n *node) error {
+	serialized, err := n.Serialize(t.pageSize)
+	if err != nil {
+		return fmt.Errorf("node.Serialize: %w", err)
+	}
+
+	if err := t.writePage(n.PageNum, serialized); err != nil {
+		return fmt.Errorf("BTree.writePage: %w", err)
+	}
+
+	return nil
+}
+
+// резервирует новую страницу в конце файла
+func (t *BTree) allocatePage() (uint32, error) {
+	fileInfo, err := t.file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("File.Stat: %w", err)
+	}
+
+	pageNum := uint32(fileInfo.Size() / int64(t.pageSize))
+
+	// записываем пустой лист, чтобы следующая аллокация
+	// получила другой номер страницы
+	if err := t.writeNode(newLeafNode(pageNum)); err != nil {
+		return 0, fmt.Errorf("BTree.writeNode: %w", err)
+	}
+
+	return pageNum, nil
+}
+
+func (t *BTree) readPage(pageNum uint32) ([]byte, error) {
+	serialized := make([]byte, t.pageSize)
+	if _, err := t.file.ReadAt(
+		serialized,
+		int64(pageNum)*int64(t.pageSize),
+	); err != nil {
+		return nil, fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	return serialized, nil
+}
+
+func (t *BTree) writePage(pageNum uint32, serialized []byte) error {
+	if _, err := t.file.WriteAt(
+		serialized,
+		int64(pageNum)*int64(t.pageSize),
+	); err != nil {
+		return fmt.Errorf("File.WriteAt: %w", err)
+	}
+
+	return nil
+}
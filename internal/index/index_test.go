@@ -0,0 +1,361 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBTree_InsertFind(t *testing.T) {
+	const (
+		keysNum      = 3000
+		duplicatesOf = 7
+	)
+
+	tree, clear := createTestTree(t, page.DefaultPageSize)
+	defer clear()
+
+	keyToLocations := make(map[int32][]Location, keysNum)
+
+	for i := 0; i < keysNum; i++ {
+		// часть ключей повторяется
+		keyValue := int32(i)
+		if i%duplicatesOf == 0 {
+			keyValue = 0
+		}
+
+		location := Location{
+			PageOffset:   int64(i/10) * page.DefaultPageSize,
+			PointerIndex: i % 10,
+		}
+
+		require.NoError(t, tree.Insert(AppendInt32Key(nil, keyValue), location))
+		keyToLocations[keyValue] = append(keyToLocations[keyValue], location)
+	}
+
+	t.Run("нашли все записи по ключу", func(t *testing.T) {
+		for keyValue, expectedLocations := range keyToLocations {
+			gotLocations, err := tree.Find(AppendInt32Key(nil, keyValue))
+			require.NoError(t, err)
+			assert.ElementsMatch(t, expectedLocations, gotLocations)
+		}
+	})
+
+	t.Run("несуществующий ключ", func(t *testing.T) {
+		gotLocations, err := tree.Find(AppendInt32Key(nil, -1))
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(gotLocations))
+	})
+
+	t.Run("повторная вставка той же записи", func(t *testing.T) {
+		err := tree.Insert(AppendInt32Key(nil, 1), keyToLocations[1][0])
+		assert.EqualError(t, err, "BTree.insert: index entry already exists")
+	})
+
+	t.Run("слишком большой ключ", func(t *testing.T) {
+		err := tree.Insert(make([]byte, MaxKeySize+1), Location{})
+		assert.EqualError(t, err, "index key size 1025 exceeds max size 1024")
+	})
+}
+
+func TestBTree_Range(t *testing.T) {
+	tree, clear := createTestTree(t, page.DefaultPageSize)
+	defer clear()
+
+	// вставляем в случайном порядке
+	values := rand.Perm(1000)
+	for _, v := range values {
+		require.NoError(t, tree.Insert(
+			AppendInt32Key(nil, int32(v)-500),
+			Location{PageOffset: int64(v), PointerIndex: 0},
+		))
+	}
+
+	testCases := []struct {
+		name          string
+		lower         *Bound
+		upper         *Bound
+		expectedFirst int64
+		expectedLen   int
+	}{
+		{
+			name:          "включительные границы",
+			lower:         &Bound{Key: AppendInt32Key(nil, -10), Inclusive: true},
+			upper:         &Bound{Key: AppendInt32Key(nil, 10), Inclusive: true},
+			expectedFirst: 490,
+			expectedLen:   21,
+		},
+		{
+			name:          "исключительные границы",
+			lower:         &Bound{Key: AppendInt32Key(nil, -10), Inclusive: false},
+			upper:         &Bound{Key: AppendInt32Key(nil, 10), Inclusive: false},
+			expectedFirst: 491,
+			expectedLen:   19,
+		},
+		{
+			name:          "без нижней границы",
+			upper:         &Bound{Key: AppendInt32Key(nil, -490), Inclusive: false},
+			expectedFirst: 0,
+			expectedLen:   10,
+		},
+		{
+			name:          "без верхней границы",
+			lower:         &Bound{Key: AppendInt32Key(nil, 490), Inclusive: true},
+			expectedFirst: 990,
+			expectedLen:   10,
+		},
+		{
+			name:          "без границ",
+			expectedFirst: 0,
+			expectedLen:   1000,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotLocations, err := tree.Range(tc.lower, tc.upper)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedLen, len(gotLocations))
+
+			// записи отсортированы по ключу
+			for i, location := range gotLocations {
+				assert.Equal(t, tc.expectedFirst+int64(i), location.PageOffset)
+			}
+		})
+	}
+}
+
+func TestBTree_Delete(t *testing.T) {
+	const keysNum = 2000
+
+	tree, clear := createTestTree(t, page.DefaultPageSize)
+	defer clear()
+
+	for i := 0; i < keysNum; i++ {
+		require.NoError(t, tree.Insert(
+			AppendStringKey(nil, fmt.Sprintf("key_%d", i%100)),
+			Location{PageOffset: int64(i), PointerIndex: i % 3},
+		))
+	}
+
+	// удаляем все записи с нечетным смещением
+	for i := 1; i < keysNum; i += 2 {
+		require.NoError(t, tree.Delete(
+			AppendStringKey(nil, fmt.Sprintf("key_%d", i%100)),
+			Location{PageOffset: int64(i), PointerIndex: i % 3},
+		))
+	}
+
+	t.Run("удаленные записи не находятся", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			gotLocations, err := tree.Find(AppendStringKey(nil, fmt.Sprintf("key_%d", i)))
+			require.NoError(t, err)
+
+			if i%2 == 1 {
+				assert.Equal(t, 0, len(gotLocations))
+				continue
+			}
+
+			assert.Equal(t, keysNum/100, len(gotLocations))
+			for _, location := range gotLocations {
+				assert.Equal(t, int64(0), location.PageOffset%2)
+			}
+		}
+	})
+
+	t.Run("удаление несуществующей записи", func(t *testing.T) {
+		err := tree.Delete(AppendStringKey(nil, "key_1"), Location{PageOffset: 1, PointerIndex: 1})
+		assert.EqualError(t, err, "index entry not found")
+	})
+}
+
+func TestBTree_DeepTree(t *testing.T) {
+	const keysNum = 1500
+
+	for _, pageSize := range []int{page.MinPageSize, page.DefaultPageSize, page.MaxPageSize} {
+		t.Run(fmt.Sprintf("страницы по %d байт", pageSize), func(t *testing.T) {
+			tree, clear := createTestTree(t, pageSize)
+			defer clear()
+
+			// большие ключи, чтобы разделялись и внутренние узлы
+			getKey := func(i int) []byte {
+				return AppendStringKey(nil, fmt.Sprintf("%0500d", i))
+			}
+
+			for _, i := range rand.Perm(keysNum) {
+				require.NoError(t, tree.Insert(getKey(i), Location{PageOffset: int64(i)}))
+			}
+
+			gotLocations, err := tree.Range(nil, nil)
+			require.NoError(t, err)
+			require.Equal(t, keysNum, len(gotLocations))
+
+			for i, location := range gotLocations {
+				assert.Equal(t, int64(i), location.PageOffset)
+			}
+
+			for i := 0; i < keysNum; i += 97 {
+				gotLocations, err := tree.Find(getKey(i))
+				require.NoError(t, err)
+				assert.Equal(t, []Location{{PageOffset: int64(i)}}, gotLocations)
+			}
+		})
+	}
+}
+
+func TestBTree_Reopen(t *testing.T) {
+	tree, clear := createTestTree(t, page.DefaultPageSize)
+	defer clear()
+
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, tree.Insert(
+			AppendInt32Key(nil, int32(i)),
+			Location{PageOffset: int64(i), PointerIndex: 1},
+		))
+	}
+
+	reopened, err := Open(tree.file, page.DefaultPageSize)
+	require.NoError(t, err)
+
+	gotLocations, err := reopened.Find(AppendInt32Key(nil, 999))
+	require.NoError(t, err)
+	assert.Equal(t, []Location{{PageOffset: 999, PointerIndex: 1}}, gotLocations)
+
+	t.Run("файл не является индексом", func(t *testing.T) {
+		descriptor, err := os.CreateTemp("./", "not_index")
+		require.NoError(t, err)
+		defer func() {
+			descriptor.Close()
+			require.NoError(t, os.Remove(descriptor.Name()))
+		}()
+
+		_, err = descriptor.Write(page.NewEmptyPage(page.DefaultPageSize).Serialize())
+		require.NoError(t, err)
+
+		_, err = Open(descriptor, page.DefaultPageSize)
+		assert.EqualError(t, err, "BTree.readRoot: file is not a valid index file")
+	})
+}
+
+func TestKey_Bound(t *testing.T) {
+	short := AppendStringKey(nil, "short")
+	assert.Equal(t, short, BoundKey(short))
+
+	long := AppendStringKey(nil, strings.Repeat("a", 2*MaxKeySize))
+	otherLong := AppendStringKey(nil, strings.Repeat("a", 2*MaxKeySize)+"b")
+
+	bounded := BoundKey(long)
+	assert.Equal(t, MaxKeySize, len(bounded))
+	assert.Equal(t, long[:MaxKeyPrefixSize], bounded[:MaxKeyPrefixSize])
+	assert.Equal(t, bounded, BoundKey(long))
+
+	// ключи с общим префиксом различаются хешем
+	assert.NotEqual(t, bounded, BoundKey(otherLong))
+
+	tree, clear := createTestTree(t, page.DefaultPageSize)
+	defer clear()
+
+	require.NoError(t, tree.Insert(bounded, Location{PageOffset: 1}))
+	require.NoError(t, tree.Insert(BoundKey(otherLong), Location{PageOffset: 2}))
+
+	found, err := tree.Find(BoundKey(long))
+	require.NoError(t, err)
+	assert.Equal(t, []Location{{PageOffset: 1}}, found)
+}
+
+func TestKey_Ordering(t *testing.T) {
+	t.Run("int32", func(t *testing.T) {
+		values := []int32{-2147483648, -100, -1, 0, 1, 100, 2147483647}
+		assertKeysSorted(t, len(values), func(i int) []byte {
+			return AppendInt32Key(nil, values[i])
+		})
+	})
+
+	t.Run("int64", func(t *testing.T) {
+		values := []int64{math.MinInt64, -1 << 40, -1, 0, 1, 1 << 40, math.MaxInt64}
+		assertKeysSorted(t, len(values), func(i int) []byte {
+			return AppendInt64Key(nil, values[i])
+		})
+	})
+
+	t.Run("float64", func(t *testing.T) {
+		values := []float64{
+			math.Inf(-1), -math.MaxFloat64, -1e10, -1.5, -math.SmallestNonzeroFloat64,
+			0, math.SmallestNonzeroFloat64, 0.25, 1, 1e10, math.MaxFloat64, math.Inf(1),
+		}
+		assertKeysSorted(t, len(values), func(i int) []byte {
+			return AppendFloat64Key(nil, values[i])
+		})
+	})
+
+	t.Run("string", func(t *testing.T) {
+		values := []string{"", "\x00", "\x00\x00", "a", "a\x00", "a\x00b", "ab", "b"}
+		assertKeysSorted(t, len(values), func(i int) []byte {
+			return AppendStringKey(nil, values[i])
+		})
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		values := [][]byte{{}, {0x00}, {0x00, 0x00}, {0x00, 0xFF}, {0x01}, {0xFF}, {0xFF, 0x00}}
+		assertKeysSorted(t, len(values), func(i int) []byte {
+			return AppendBytesKey(nil, values[i])
+		})
+	})
+
+	t.Run("составной ключ", func(t *testing.T) {
+		type pair struct {
+			s string
+			v int32
+		}
+
+		values := []pair{{"a", 5}, {"a", 10}, {"ab", -1}, {"b", 0}}
+		assertKeysSorted(t, len(values), func(i int) []byte {
+			return AppendInt32Key(AppendStringKey(nil, values[i].s), values[i].v)
+		})
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		values := []bool{false, true}
+		assertKeysSorted(t, len(values), func(i int) []byte {
+			return AppendBoolKey(nil, values[i])
+		})
+	})
+}
+
+// ключи, построенные по отсортированным значениям, тоже отсортированы
+func assertKeysSorted(t *testing.T, n int, getKey func(i int) []byte) {
+	keys := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		keys = append(keys, getKey(i))
+	}
+
+	assert.True(t, sort.SliceIsSorted(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	}))
+
+	for i := 1; i < n; i++ {
+		assert.NotEqual(t, keys[i-1], keys[i])
+	}
+}
+
+func createTestTree(t *testing.T, pageSize int) (*BTree, func()) {
+	descriptor, err := os.CreateTemp("./", "test_index")
+	require.NoError(t, err)
+
+	tree, err := Create(descriptor, pageSize)
+	require.NoError(t, err)
+
+	return tree, func() {
+		descriptor.Close()
+		require.NoError(t, os.Remove(descriptor.Name()))
+	}
+}
@@ -0,0 +1,113 @@
+package index
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+/*
+Ключи индекса сравниваются побайтово, поэтому значения колонок
+кодируются так, чтобы порядок байт совпадал с порядком значений.
+Составной ключ - конкатенация закодированных значений колонок.
+*/
+
+const (
+	// строка завершается парой байт 0x00 0x01,
+	// а нулевые байты внутри строки экранируются как 0x00 0xFF.
+	// так более короткая строка всегда меньше своего продолжения
+	stringEscapeByte     byte = 0x00
+	stringEscapedZero    byte = 0xFF
+	stringTerminatorByte byte = 0x01
+
+	int32SignBit uint32 = 1 << 31
+	int64SignBit uint64 = 1 << 63
+
+	// хеш полного ключа после префикса укороченного ключа
+	keyHashSize = 8
+	// ключи длиннее хранятся укороченными
+	MaxKeyPrefixSize = MaxKeySize - keyHashSize
+)
+
+// ключ, который помещается в индекс. ключ длиннее MaxKeyPrefixSize
+// заменяется префиксом этой длины и хешем полного ключа. укороченные
+// ключи упорядочены только по префиксу, а равные хеши не значат
+// равенства полных ключей, поэтому найденные по ним строки проверяются
+func BoundKey(key []byte) []byte {
+	if !IsTruncatedKey(key) {
+		return key
+	}
+
+	hash := fnv.New64a()
+	hash.Write(key)
+
+	bounded := make([]byte, 0, MaxKeySize)
+	bounded = append(bounded, key[:MaxKeyPrefixSize]...)
+
+	return hash.Sum(bounded)
+}
+
+// ключ такой длины индекс хранит укороченным
+func IsTruncatedKey(key []byte) bool {
+	return len(key) > MaxKeyPrefixSize
+}
+
+func AppendInt32Key(dst []byte, value int32) []byte {
+	// инвертируем знаковый бит, чтобы отрицательные числа
+	// оказались меньше положительных
+	return binary.BigEndian.AppendUint32(dst, uint32(value)^int32SignBit)
+}
+
+func AppendInt64Key(dst []byte, value int64) []byte {
+	return binary.BigEndian.AppendUint64(dst, uint64(value)^int64SignBit)
+}
+
+// NaN не поддерживается: у него нет места в порядке значений
+func AppendFloat64Key(dst []byte, value float64) []byte {
+	bits := math.Float64bits(value)
+
+	// у положительных чисел достаточно выставить знаковый бит.
+	// у отрицательных инвертируем все биты: чем больше модуль,
+	// тем меньше число
+	if bits&int64SignBit != 0 {
+		bits = ^bits
+	} else {
+		bits |= int64SignBit
+	}
+
+	return binary.BigEndian.AppendUint64(dst, bits)
+}
+
+func AppendStringKey(dst []byte, value string) []byte {
+	return appendEscapedKey(dst, value)
+}
+
+func AppendBytesKey(dst []byte, value []byte) []byte {
+	return appendEscapedKey(dst, value)
+}
+
+// значения одинаковой длины сравниваются побайтово как есть
+func AppendFixedKey(dst []byte, value []byte) []byte {
+	return append(dst, value...)
+}
+
+func appendEscapedKey[T string | []byte](dst []byte, value T) []byte {
+	for i := 0; i < len(value); i++ {
+		if value[i] == stringEscapeByte {
+			dst = append(dst, stringEscapeByte, stringEscapedZero)
+			continue
+		}
+
+		dst = append(dst, value[i])
+	}
+
+	return append(dst, stringEscapeByte, stringTerminatorByte)
+}
+
+func AppendBoolKey(dst []byte, value bool) []byte {
+	if value {
+		return append(dst, 1)
+	}
+
+	return append(dst, 0)
+}
@@ -0,0 +1,327 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+)
+
+/*
+Узел дерева хранится в обычной пейдже (см. internal/page):
+
+Item 0 - заголовок узла
+Item 1..N - записи узла, отсортированные по (ключ, расположение строки)
+*/
+
+// размеры в байтах
+const (
+	// Kind (1) + Next (4) + Leftmost (4)
+	nodeHeaderSize = 1 + 4 + 4
+
+	// PageOffset (8) + PointerIndex (2)
+	locationSize = 8 + 2
+
+	// KeyLen (2) + Key + Location
+	leafEntryOverhead = 2 + locationSize
+
+	// KeyLen (2) + Key + Location + Child (4)
+	internalEntryOverhead = leafEntryOverhead + 4
+)
+
+// типы узлов
+const (
+	internalNode byte = 0
+	leafNode     byte = 1
+)
+
+// номер страницы, означающий отсутствие ссылки
+const nilPageNum uint32 = 0
+
+// расположение строки в файле данных таблицы
+type Location struct {
+	PageOffset   int64
+	PointerIndex int
+}
+
+// минимальное расположение, меньше любого реального.
+// используется для поиска первой записи с заданным ключом
+var minLocation = Location{PageOffset: -1, PointerIndex: -1}
+
+type entry struct {
+	Key      []byte
+	Location Location
+	// только для внутренних узлов:
+	// поддерево с записями >= (Key, Location)
+	Child uint32
+}
+
+type node struct {
+	PageNum uint32
+	Kind    byte
+	// только для листьев: следующий лист справа
+	Next uint32
+	// только для внутренних узлов: поддерево с записями
+	// меньше первой записи узла
+	Leftmost uint32
+	Entries  []*entry
+}
+
+func newLeafNode(pageNum uint32) *node {
+	return &node{
+		PageNum: pageNum,
+		Kind:    leafNode,
+		Next:    nilPageNum,
+	}
+}
+
+func newInternalNode(pageNum uint32, leftmost uint32) *node {
+	return &node{
+		PageNum:  pageNum,
+		Kind:     internalNode,
+		Leftmost: leftmost,
+	}
+}
+
+func (n *node) IsLeaf() bool {
+	return n.Kind == leafNode
+}
+
+func compareEntries(
+	aKey []byte,
+	aLocation Location,
+	bKey []byte,
+	bLocation Location,
+) int {
+	if cmp := bytes.Compare(aKey, bKey); cmp != 0 {
+		return cmp
+	}
+
+	switch {
+	case aLocation.PageOffset < bLocation.PageOffset:
+		return -1
+	case aLocation.PageOffset > bLocation.PageOffset:
+		return 1
+	case aLocation.PointerIndex < bLocation.PointerIndex:
+		return -1
+	case aLocation.PointerIndex > bLocation.PointerIndex:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// индекс первой записи строго больше (key, location)
+func (n *node) upperBound(key []byte, location Location) int {
+	lo, hi := 0, len(n.Entries)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if compareEntries(n.Entries[mid].Key, n.Entries[mid].Location, key, location) <= 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo
+}
+
+// поддерево внутреннего узла, в котором может находиться (key, location)
+func (n *node) childFor(key []byte, location Location) uint32 {
+	i := n.upperBound(key, location)
+	if i == 0 {
+		return n.Leftmost
+	}
+
+	return n.Entries[i-1].Child
+}
+
+func (n *node) entrySize(e *entry) int {
+	if n.IsLeaf() {
+		return leafEntryOverhead + len(e.Key) + page.ItemPointerSize
+	}
+
+	return internalEntryOverhead + len(e.Key) + page.ItemPointerSize
+}
+
+func (n *node) size() int {
+	size := page.PageHeaderSize + nodeHeaderSize + page.ItemPointerSize
+	for _, e := range n.Entries {
+		size += n.entrySize(e)
+	}
+
+	return size
+}
+
+func (n *node) Fits(pageSize int) bool {
+	return n.size() <= pageSize
+}
+
+// делит переполненный узел пополам по объему данных.
+// возвращает правый узел и запись-разделитель для родителя
+func (n *node) split(rightPageNum uint32) (*node, *entry) {
+	var (
+		half     = (n.size() - page.PageHeaderSize) / 2
+		leftSize int
+		splitAt  int
+	)
+
+	for splitAt = 0; splitAt < len(n.Entries)-1; splitAt++ {
+		leftSize += n.entrySize(n.Entries[splitAt])
+		if leftSize >= half {
+			break
+		}
+	}
+
+	// в каждой половине должна остаться хотя бы одна запись
+	if splitAt == 0 {
+		splitAt = 1
+	}
+
+	if n.IsLeaf() {
+		right := newLeafNode(rightPageNum)
+		right.Entries = append(right.Entries, n.Entries[splitAt:]...)
+		right.Next = n.Next
+
+		n.Entries = n.Entries[:splitAt]
+		n.Next = right.PageNum
+
+		separator := &entry{
+			Key:      right.Entries[0].Key,
+			Location: right.Entries[0].Location,
+			Child:    right.PageNum,
+		}
+
+		return right, separator
+	}
+
+	// во внутреннем узле средняя запись уходит в родителя
+	middle := n.Entries[splitAt]
+
+	right := newInternalNode(rightPageNum, middle.Child)
+	right.Entries = append(right.Entries, n.Entries[splitAt+1:]...)
+
+	n.Entries = n.Entries[:splitAt]
+
+	separator := &entry{
+		Key:      middle.Key,
+		Location: middle.Location,
+		Child:    right.PageNum,
+	}
+
+	return right, separator
+}
+
+func (n *node) insertAt(i int, e *entry) {
+	n.Entries = append(n.Entries, nil)
+	copy(n.Entries[i+1:], n.Entries[i:])
+	n.Entries[i] = e
+}
+
+func (n *node) removeAt(i int) {
+	n.Entries = append(n.Entries[:i], n.Entries[i+1:]...)
+}
+
+func (n *node) Serialize(pageSize int) ([]byte, error) {
+	nodePage := page.NewEmptyPage(pageSize)
+
+	header := make([]byte, nodeHeaderSize)
+	header[0] = n.Kind
+	binary.BigEndian.PutUint32(header[1:], n.Next)
+	binary.BigEndian.PutUint32(header[5:], n.Leftmost)
+
+	if _, err := nodePage.Insert(header); err != nil {
+		return nil, fmt.Errorf("Page.Insert: %w", err)
+	}
+
+	for _, e := range n.Entries {
+		if _, err := nodePage.Insert(n.serializeEntry(e)); err != nil {
+			return nil, fmt.Errorf("Page.Insert: %w", err)
+		}
+	}
+
+	return nodePage.Serialize(), nil
+}
+
+func (n *node) serializeEntry(e *entry) []byte {
+	size := leafEntryOverhead + len(e.Key)
+	if !n.IsLeaf() {
+		size = internalEntryOverhead + len(e.Key)
+	}
+
+	serialized := make([]byte, size)
+
+	offset := 0
+	binary.BigEndian.PutUint16(serialized[offset:], uint16(len(e.Key)))
+	offset += 2
+
+	copy(serialized[offset:], e.Key)
+	offset += len(e.Key)
+
+	binary.BigEndian.PutUint64(serialized[offset:], uint64(e.Location.PageOffset))
+	offset += 8
+
+	binary.BigEndian.PutUint16(serialized[offset:], uint16(e.Location.PointerIndex))
+	offset += 2
+
+	if !n.IsLeaf() {
+		binary.BigEndian.PutUint32(serialized[offset:], e.Child)
+	}
+
+	return serialized
+}
+
+func deserializeNode(pageNum uint32, serialized []byte) (*node, error) {
+	nodePage, err := page.DeserializePage(serialized)
+	if err != nil {
+		page.SetCorruptedPageNum(err, int64(pageNum))
+		return nil, fmt.Errorf("page.DeserializePage: %w", err)
+	}
+
+	if len(nodePage.Pointers) == 0 {
+		return nil, ErrInvalidIndexFile()
+	}
+
+	header := nodePage.GetDataByPointer(nodePage.Pointers[0])
+	if len(header) != nodeHeaderSize {
+		return nil, ErrInvalidIndexFile()
+	}
+
+	deserialized := &node{
+		PageNum:  pageNum,
+		Kind:     header[0],
+		Next:     binary.BigEndian.Uint32(header[1:]),
+		Leftmost: binary.BigEndian.Uint32(header[5:]),
+		Entries:  make([]*entry, 0, len(nodePage.Pointers)-1),
+	}
+
+	for _, pointer := range nodePage.Pointers[1:] {
+		data := nodePage.GetDataByPointer(pointer)
+
+		offset := 0
+		keyLen := int(binary.BigEndian.Uint16(data[offset:]))
+		offset += 2
+
+		// копируем ключ, чтобы не держать ссылку на всю страницу
+		key := make([]byte, keyLen)
+		copy(key, data[offset:offset+keyLen])
+		offset += keyLen
+
+		e := &entry{Key: key}
+
+		e.Location.PageOffset = int64(binary.BigEndian.Uint64(data[offset:]))
+		offset += 8
+
+		e.Location.PointerIndex = int(binary.BigEndian.Uint16(data[offset:]))
+		offset += 2
+
+		if !deserialized.IsLeaf() {
+			e.Child = binary.BigEndian.Uint32(data[offset:])
+		}
+
+		deserialized.Entries = append(deserialized.Entries, e)
+	}
+
+	return deserialized, nil
+}
@@ -0,0 +1,196 @@
+package jsondoc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+)
+
+/*
+Двоичная форма документа - значение с байтом-тегом типа:
+
+null, false, true - только тег
+целое - zigzag varint
+дробное - float64 (8 байт)
+строка - uvarint длины и байты строки
+массив - uvarint количества и элементы
+объект - uvarint количества и пары: uvarint длины ключа,
+ключ и значение. ключи отсортированы, поэтому равные
+документы кодируются одинаково
+*/
+
+const (
+	tagNull byte = iota
+	tagFalse
+	tagTrue
+	tagInt
+	tagFloat
+	tagString
+	tagArray
+	tagObject
+)
+
+func Encode(document any) ([]byte, error) {
+	return appendValue(nil, document)
+}
+
+func Decode(data []byte) (any, error) {
+	value, rest, err := readValue(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) != 0 {
+		return nil, ErrCorruptedDocument
+	}
+
+	return value, nil
+}
+
+func appendValue(dst []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(dst, tagNull), nil
+	case bool:
+		if v {
+			return append(dst, tagTrue), nil
+		}
+		return append(dst, tagFalse), nil
+	case int64:
+		return binary.AppendVarint(append(dst, tagInt), v), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(dst, tagFloat), math.Float64bits(v)), nil
+	case string:
+		dst = binary.AppendUvarint(append(dst, tagString), uint64(len(v)))
+		return append(dst, v...), nil
+	case []any:
+		dst = binary.AppendUvarint(append(dst, tagArray), uint64(len(v)))
+		for _, element := range v {
+			var err error
+			if dst, err = appendValue(dst, element); err != nil {
+				return nil, err
+			}
+		}
+		return dst, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		dst = binary.AppendUvarint(append(dst, tagObject), uint64(len(v)))
+		for _, key := range keys {
+			dst = binary.AppendUvarint(dst, uint64(len(key)))
+			dst = append(dst, key...)
+
+			var err error
+			if dst, err = appendValue(dst, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return dst, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected value of type %T", ErrInvalidDocument, value)
+	}
+}
+
+func readValue(data []byte) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, ErrCorruptedDocument
+	}
+
+	tag, data := data[0], data[1:]
+
+	switch tag {
+	case tagNull:
+		return nil, data, nil
+	case tagFalse:
+		return false, data, nil
+	case tagTrue:
+		return true, data, nil
+	case tagInt:
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, nil, ErrCorruptedDocument
+		}
+		return v, data[n:], nil
+	case tagFloat:
+		if len(data) < 8 {
+			return nil, nil, ErrCorruptedDocument
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), data[8:], nil
+	case tagString:
+		v, rest, err := readString(data)
+		return v, rest, err
+	case tagArray:
+		count, data, err := readUvarint(data)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// каждый элемент занимает хотя бы байт
+		if count > uint64(len(data)) {
+			return nil, nil, ErrCorruptedDocument
+		}
+
+		array := make([]any, 0, count)
+		for i := uint64(0); i < count; i++ {
+			var element any
+			if element, data, err = readValue(data); err != nil {
+				return nil, nil, err
+			}
+			array = append(array, element)
+		}
+		return array, data, nil
+	case tagObject:
+		count, data, err := readUvarint(data)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if count > uint64(len(data)) {
+			return nil, nil, ErrCorruptedDocument
+		}
+
+		object := make(map[string]any, count)
+		for i := uint64(0); i < count; i++ {
+			var key string
+			if key, data, err = readString(data); err != nil {
+				return nil, nil, err
+			}
+
+			var value any
+			if value, data, err = readValue(data); err != nil {
+				return nil, nil, err
+			}
+			object[key] = value
+		}
+		return object, data, nil
+	default:
+		return nil, nil, ErrCorruptedDocument
+	}
+}
+
+func readString(data []byte) (string, []byte, error) {
+	length, data, err := readUvarint(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if length > uint64(len(data)) {
+		return "", nil, ErrCorruptedDocument
+	}
+
+	return string(data[:length]), data[length:], nil
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, ErrCorruptedDocument
+	}
+
+	return v, data[n:], nil
+}
@@ -0,0 +1,119 @@
+package jsondoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+/*
+Документ json в памяти - значение одного из типов:
+
+nil, bool, int64, float64, string, []any, map[string]any
+
+Целые числа, которые помещаются в int64, остаются целыми,
+остальные числа становятся float64.
+*/
+
+var (
+	ErrInvalidDocument   = errors.New("invalid json document")
+	ErrCorruptedDocument = errors.New("corrupted binary json document")
+)
+
+// разбирает текст json в документ
+func Parse(text []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(text))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+
+	// после документа ничего не должно быть
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: unexpected data after document", ErrInvalidDocument)
+	}
+
+	return normalize(value)
+}
+
+// приводит значение go, например структуру или map[string]int,
+// к документу так, как его записал бы encoding/json
+func FromGo(value any) (any, error) {
+	text, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+
+	return Parse(text)
+}
+
+// компактный текст json. ключи объектов отсортированы
+func Marshal(document any) ([]byte, error) {
+	return json.Marshal(document)
+}
+
+// значение по пути из ключей объектов и номеров элементов массивов
+func Lookup(document any, path []string) (any, bool) {
+	current := document
+	for _, segment := range path {
+		switch v := current.(type) {
+		case map[string]any:
+			next, exists := v[segment]
+			if !exists {
+				return nil, false
+			}
+			current = next
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func normalize(value any) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+
+		f, err := v.Float64()
+		if err != nil || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%w: number %s is out of range", ErrInvalidDocument, v)
+		}
+		return f, nil
+	case []any:
+		for i := range v {
+			normalized, err := normalize(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = normalized
+		}
+		return v, nil
+	case map[string]any:
+		for key := range v {
+			normalized, err := normalize(v[key])
+			if err != nil {
+				return nil, err
+			}
+			v[key] = normalized
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
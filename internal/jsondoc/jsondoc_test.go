@@ -0,0 +1,111 @@
+package jsondoc
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	document, err := Parse([]byte(`{"id": 5, "ratio": 0.5, "big": 1e400, "tags": ["a", null, true]}`))
+	assert.ErrorIs(t, err, ErrInvalidDocument)
+	assert.Nil(t, document)
+
+	document, err = Parse([]byte(`{"id": 5, "ratio": 0.5, "tags": ["a", null, true], "user": {"name": "x"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id":    int64(5),
+		"ratio": 0.5,
+		"tags":  []any{"a", nil, true},
+		"user":  map[string]any{"name": "x"},
+	}, document)
+
+	t.Run("некорректный текст", func(t *testing.T) {
+		for _, text := range []string{``, `{`, `{"a": 1} {"b": 2}`, `[1,]`, `tru`} {
+			_, err := Parse([]byte(text))
+			assert.ErrorIs(t, err, ErrInvalidDocument, text)
+		}
+	})
+
+	t.Run("значения go", func(t *testing.T) {
+		document, err := FromGo(map[string]int{"b": 2, "a": 1})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"a": int64(1), "b": int64(2)}, document)
+
+		_, err = FromGo(math.NaN())
+		assert.ErrorIs(t, err, ErrInvalidDocument)
+	})
+}
+
+func TestEncodeDecode(t *testing.T) {
+	document, err := Parse([]byte(`{
+		"id": -9223372036854775808,
+		"price": -12.5,
+		"name": "имя",
+		"empty": {},
+		"items": [[], [1, 2], {"k": null}],
+		"flags": [true, false]
+	}`))
+	require.NoError(t, err)
+
+	encoded, err := Encode(document)
+	require.NoError(t, err)
+
+	decoded, err := Decode(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, document, decoded)
+
+	t.Run("порядок ключей не влияет на кодирование", func(t *testing.T) {
+		first, err := Parse([]byte(`{"a": 1, "b": {"c": 2, "d": 3}}`))
+		require.NoError(t, err)
+		second, err := Parse([]byte(`{"b": {"d": 3, "c": 2}, "a": 1}`))
+		require.NoError(t, err)
+
+		firstEncoded, err := Encode(first)
+		require.NoError(t, err)
+		secondEncoded, err := Encode(second)
+		require.NoError(t, err)
+		assert.Equal(t, firstEncoded, secondEncoded)
+	})
+
+	t.Run("поврежденные данные", func(t *testing.T) {
+		for i := 0; i < len(encoded); i++ {
+			_, err := Decode(encoded[:i])
+			assert.ErrorIs(t, err, ErrCorruptedDocument)
+		}
+
+		_, err := Decode(append(encoded, 0))
+		assert.ErrorIs(t, err, ErrCorruptedDocument)
+
+		_, err = Decode([]byte{0xFF})
+		assert.ErrorIs(t, err, ErrCorruptedDocument)
+	})
+}
+
+func TestLookup(t *testing.T) {
+	document, err := Parse([]byte(`{"user": {"id": 5, "roles": ["admin", "dev"]}, "n": null}`))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		path          []string
+		expected      any
+		expectedFound bool
+	}{
+		{path: []string{"user", "id"}, expected: int64(5), expectedFound: true},
+		{path: []string{"user", "roles", "1"}, expected: "dev", expectedFound: true},
+		{path: []string{"n"}, expected: nil, expectedFound: true},
+		{path: []string{}, expected: document, expectedFound: true},
+		{path: []string{"user", "roles", "2"}},
+		{path: []string{"user", "roles", "-1"}},
+		{path: []string{"user", "id", "x"}},
+		{path: []string{"missing"}},
+	}
+
+	for _, tc := range testCases {
+		value, found := Lookup(document, tc.path)
+		assert.Equal(t, tc.expectedFound, found, tc.path)
+		assert.Equal(t, tc.expected, value, tc.path)
+	}
+}
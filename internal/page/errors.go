@@ -1,5 +1,10 @@
 package page
 
+import (
+	"errors"
+	"fmt"
+)
+
 type ErrCantFitDataIntoPage struct {
 	DataLen          uint16
 	PageFreeSpaceLen uint16
@@ -17,3 +22,73 @@ func NewErrCantFitDataIntoPage(dataLen, pageFreeSpaceLen uint16) error {
 func (e *ErrCantFitDataIntoPage) Error() string {
 	return e.Message
 }
+
+// контрольная сумма страницы не совпала с содержимым: страница
+// повреждена на диске или записана не полностью
+type ErrCorruptedPage struct {
+	// таблица и номер страницы известны тому, кто читает страницу
+	// из файла, и заполняются по мере подъема ошибки
+	TableName string
+	PageNum   int64
+	Stored    uint32
+	Computed  uint32
+}
+
+func NewErrCorruptedPage(stored, computed uint32) error {
+	return &ErrCorruptedPage{
+		PageNum:  -1,
+		Stored:   stored,
+		Computed: computed,
+	}
+}
+
+func (e *ErrCorruptedPage) Error() string {
+	return fmt.Sprintf(
+		"page %d of table %s is corrupted: stored checksum %08x, computed %08x",
+		e.PageNum,
+		e.TableName,
+		e.Stored,
+		e.Computed,
+	)
+}
+
+// дополняет ошибку о поврежденной странице ее номером
+func SetCorruptedPageNum(err error, pageNum int64) {
+	var corrupted *ErrCorruptedPage
+	if errors.As(err, &corrupted) && corrupted.PageNum < 0 {
+		corrupted.PageNum = pageNum
+	}
+}
+
+// дополняет ошибку о поврежденной странице именем таблицы
+func SetCorruptedPageTable(err error, tableName string) {
+	var corrupted *ErrCorruptedPage
+	if errors.As(err, &corrupted) && corrupted.TableName == "" {
+		corrupted.TableName = tableName
+	}
+}
+
+func ErrNotDataFile() error {
+	return fmt.Errorf("file has no data file header")
+}
+
+func ErrUnsupportedFormatVersion(version uint16) error {
+	return fmt.Errorf(
+		"data file format version %d is not supported, expected %d",
+		version,
+		CurrentFormatVersion,
+	)
+}
+
+func ErrPageSizeMismatch(pageSize, expected int) error {
+	return fmt.Errorf("page size %d differs from database page size %d", pageSize, expected)
+}
+
+func ErrInvalidPageSize(pageSize int) error {
+	return fmt.Errorf(
+		"page size %d must be a power of two from %d to %d",
+		pageSize,
+		MinPageSize,
+		MaxPageSize,
+	)
+}
@@ -0,0 +1,155 @@
+package page
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+/*
+Заголовок файла данных занимает первую страницу файла:
+
+Magic (8)
+Version (2)
+PageSize (4)
+Checksum (4)
+SchemaIDLen (2)
+SchemaID
+
+Страницы со строками начинаются со второй страницы.
+*/
+
+const (
+	FileHeaderMagic = "SMALLDB\x00"
+
+	// версия формата файла данных:
+	// 0 - записи без заголовков версий строк
+	// 1 - страницы без заголовка файла и контрольных сумм
+	// 2 - заголовок файла и контрольные суммы страниц
+	// 3 - битовая карта null-значений в записях
+	// 4 - контрольные суммы страниц переполнения и карты свободного места
+	RawRecordsFormatVersion       uint16 = 0
+	LegacyFormatVersion           uint16 = 1
+	FileHeaderFormatVersion       uint16 = 2
+	NullBitmapFormatVersion       uint16 = 3
+	OverflowChecksumFormatVersion uint16 = 4
+	CurrentFormatVersion                 = OverflowChecksumFormatVersion
+
+	fileHeaderMagicSize   = len(FileHeaderMagic)
+	fileHeaderVersionOff  = fileHeaderMagicSize
+	fileHeaderPageSizeOff = fileHeaderVersionOff + 2
+	fileHeaderChecksumOff = fileHeaderPageSizeOff + 4
+	fileHeaderSchemaIDOff = fileHeaderChecksumOff + checksumSize
+	// заголовок помещается на страницу любого размера
+	fileHeaderSchemaLenMax = MinPageSize - fileHeaderSchemaIDOff - 2
+)
+
+// смещение первой страницы со строками: заголовок занимает
+// первую страницу целиком
+func FirstDataPageOffset(pageSize int) int64 {
+	return int64(pageSize)
+}
+
+type FileHeader struct {
+	Version  uint16
+	PageSize uint32
+	SchemaID string
+}
+
+func NewFileHeader(schemaID string, pageSize int) *FileHeader {
+	return &FileHeader{
+		Version:  CurrentFormatVersion,
+		PageSize: uint32(pageSize),
+		SchemaID: schemaID,
+	}
+}
+
+func (h *FileHeader) Serialize() ([]byte, error) {
+	if len(h.SchemaID) > fileHeaderSchemaLenMax {
+		return nil, fmt.Errorf("schema id of %d bytes doesnt fit into file header", len(h.SchemaID))
+	}
+
+	if err := ValidatePageSize(int(h.PageSize)); err != nil {
+		return nil, err
+	}
+
+	serialized := make([]byte, h.PageSize)
+	copy(serialized, FileHeaderMagic)
+	binary.BigEndian.PutUint16(serialized[fileHeaderVersionOff:], h.Version)
+	binary.BigEndian.PutUint32(serialized[fileHeaderPageSizeOff:], h.PageSize)
+	binary.BigEndian.PutUint16(serialized[fileHeaderSchemaIDOff:], uint16(len(h.SchemaID)))
+	copy(serialized[fileHeaderSchemaIDOff+2:], h.SchemaID)
+
+	binary.BigEndian.PutUint32(
+		serialized[fileHeaderChecksumOff:],
+		ChecksumExcluding(serialized, fileHeaderChecksumOff),
+	)
+
+	return serialized, nil
+}
+
+// читает и проверяет заголовок файла данных. с каким размером
+// страниц записан файл, решает вызывающий
+func ReadFileHeader(descriptor io.ReaderAt) (*FileHeader, error) {
+	// размер страницы, а значит и заголовка, записан в его начале
+	prefix := make([]byte, fileHeaderSchemaIDOff)
+	if _, err := descriptor.ReadAt(prefix, 0); err != nil {
+		if err == io.EOF {
+			return nil, ErrNotDataFile()
+		}
+		return nil, fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	if string(prefix[:fileHeaderMagicSize]) != FileHeaderMagic {
+		return nil, ErrNotDataFile()
+	}
+
+	pageSize := binary.BigEndian.Uint32(prefix[fileHeaderPageSizeOff:])
+	if err := ValidatePageSize(int(pageSize)); err != nil {
+		return nil, ErrNotDataFile()
+	}
+
+	serialized := make([]byte, pageSize)
+	if _, err := descriptor.ReadAt(serialized, 0); err != nil {
+		// файл короче страницы - точно не файл данных
+		if err == io.EOF {
+			return nil, ErrNotDataFile()
+		}
+		return nil, fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	stored := binary.BigEndian.Uint32(serialized[fileHeaderChecksumOff:])
+	if computed := ChecksumExcluding(serialized, fileHeaderChecksumOff); stored != computed {
+		err := NewErrCorruptedPage(stored, computed)
+		SetCorruptedPageNum(err, 0)
+		return nil, err
+	}
+
+	header := &FileHeader{
+		Version:  binary.BigEndian.Uint16(serialized[fileHeaderVersionOff:]),
+		PageSize: pageSize,
+	}
+
+	schemaIDLen := int(binary.BigEndian.Uint16(serialized[fileHeaderSchemaIDOff:]))
+	if schemaIDLen > fileHeaderSchemaLenMax {
+		return nil, ErrNotDataFile()
+	}
+	header.SchemaID = string(serialized[fileHeaderSchemaIDOff+2 : fileHeaderSchemaIDOff+2+schemaIDLen])
+
+	// старые версии возвращаются, чтобы файл можно было обновить
+	if header.Version > CurrentFormatVersion {
+		return nil, ErrUnsupportedFormatVersion(header.Version)
+	}
+
+	return header, nil
+}
+
+// контрольная сумма данных без 4 байт по смещению offset,
+// где хранится она сама. ей же подписываются страницы
+// файлов переполнения и карты свободного места
+func ChecksumExcluding(serialized []byte, offset int) uint32 {
+	sum := crc32.Update(0, checksumTable, serialized[:offset])
+	sum = crc32.Update(sum, checksumTable, make([]byte, checksumSize))
+	return crc32.Update(sum, checksumTable, serialized[offset+checksumSize:])
+}
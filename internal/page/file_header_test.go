@@ -0,0 +1,149 @@
+package page
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileHeader(t *testing.T) {
+	header := NewFileHeader("schema_id", DefaultPageSize)
+
+	serialized, err := header.Serialize()
+	require.NoError(t, err)
+	require.Equal(t, DefaultPageSize, len(serialized))
+
+	t.Run("заголовок читается обратно", func(t *testing.T) {
+		got, err := ReadFileHeader(bytes.NewReader(serialized))
+		require.NoError(t, err)
+		assert.Equal(t, header, got)
+	})
+
+	t.Run("файл без заголовка", func(t *testing.T) {
+		legacy := NewEmptyPage(DefaultPageSize).Serialize()
+
+		_, err := ReadFileHeader(bytes.NewReader(legacy))
+		assert.EqualError(t, err, "file has no data file header")
+
+		_, err = ReadFileHeader(bytes.NewReader(nil))
+		assert.EqualError(t, err, "file has no data file header")
+	})
+
+	t.Run("поврежденный заголовок", func(t *testing.T) {
+		corruptedHeader := bytes.Clone(serialized)
+		corruptedHeader[DefaultPageSize-1] ^= 0xFF
+
+		_, err := ReadFileHeader(bytes.NewReader(corruptedHeader))
+
+		var corrupted *ErrCorruptedPage
+		require.ErrorAs(t, err, &corrupted)
+		assert.Equal(t, int64(0), corrupted.PageNum)
+	})
+
+	t.Run("версия новее поддерживаемой", func(t *testing.T) {
+		newer := &FileHeader{Version: CurrentFormatVersion + 1, PageSize: DefaultPageSize}
+		serialized, err := newer.Serialize()
+		require.NoError(t, err)
+
+		_, err = ReadFileHeader(bytes.NewReader(serialized))
+		assert.EqualError(t, err, "data file format version 5 is not supported, expected 4")
+	})
+
+	t.Run("недопустимый размер страницы", func(t *testing.T) {
+		for _, pageSize := range []uint32{1024, 12288, 65536} {
+			_, err := (&FileHeader{Version: CurrentFormatVersion, PageSize: pageSize}).Serialize()
+			assert.EqualError(
+				t,
+				err,
+				fmt.Sprintf("page size %d must be a power of two from 4096 to 32768", pageSize),
+			)
+		}
+	})
+
+	t.Run("обход страниц пропускает заголовок", func(t *testing.T) {
+		tablePage := NewEmptyPage(DefaultPageSize)
+		_, err := tablePage.Insert([]byte("hello world"))
+		require.NoError(t, err)
+
+		file := append(bytes.Clone(serialized), tablePage.Serialize()...)
+
+		iter, err := NewPagesIter(tempFile(t, file))
+		require.NoError(t, err)
+
+		pagesNum := 0
+		for iter.Next() {
+			got, err := iter.GetPage()
+			require.NoError(t, err)
+			assert.Equal(t, []byte("hello world"), got.GetDataByPointer(got.Pointers[0]))
+			pagesNum++
+		}
+		assert.Equal(t, 1, pagesNum)
+		assert.Equal(t, 1, DataPagesNum(int64(len(file)), DefaultPageSize))
+	})
+
+	t.Run("обход файла с другим размером страниц", func(t *testing.T) {
+		for _, pageSize := range []int{MinPageSize, MaxPageSize} {
+			header, err := NewFileHeader("schema_id", pageSize).Serialize()
+			require.NoError(t, err)
+			require.Equal(t, pageSize, len(header))
+
+			tablePage := NewEmptyPage(pageSize)
+			_, err = tablePage.Insert(make([]byte, pageSize/2))
+			require.NoError(t, err)
+
+			file := append(header, tablePage.Serialize()...)
+			file = append(file, NewEmptyPage(pageSize).Serialize()...)
+
+			iter, err := NewPagesIter(tempFile(t, file))
+			require.NoError(t, err)
+			assert.Equal(t, pageSize, iter.PageSize())
+
+			freeSpace := make([]int, 0)
+			for iter.Next() {
+				got, err := iter.GetPage()
+				require.NoError(t, err)
+				freeSpace = append(freeSpace, got.FreeSpace())
+			}
+
+			emptyPageFreeSpace := pageSize - PageHeaderSize - ItemPointerSize
+			assert.Equal(
+				t,
+				[]int{emptyPageFreeSpace - pageSize/2 - ItemPointerSize, emptyPageFreeSpace},
+				freeSpace,
+			)
+		}
+	})
+
+	t.Run("обход файла старой версии", func(t *testing.T) {
+		older := bytes.Clone(serialized)
+		binary.BigEndian.PutUint16(older[fileHeaderVersionOff:], LegacyFormatVersion)
+		binary.BigEndian.PutUint32(
+			older[fileHeaderChecksumOff:],
+			ChecksumExcluding(older, fileHeaderChecksumOff),
+		)
+
+		got, err := ReadFileHeader(bytes.NewReader(older))
+		require.NoError(t, err)
+		assert.Equal(t, LegacyFormatVersion, got.Version)
+
+		_, err = NewPagesIter(tempFile(t, older))
+		assert.EqualError(t, err, "data file format version 1 is not supported, expected 4")
+	})
+}
+
+func tempFile(t *testing.T, data []byte) *os.File {
+	path := filepath.Join(t.TempDir(), "table.data")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+
+	return file
+}
@@ -3,6 +3,8 @@ package page
 import (
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
 )
 
@@ -17,15 +19,23 @@ import (
 
 // размеры в байтах
 const (
-	PageSize = 8192 // 8 KB
+	DefaultPageSize = 8192 // 8 KB
 
-	// NumSlots (2) + FreeSpaceStart (2) + FreeSpaceEnd (2) + Padding (26)
+	// смещения на странице хранятся в uint16, поэтому страница
+	// не больше 32 KB: у 64 KB конец страницы уже не помещается
+	MinPageSize = 4096  // 4 KB
+	MaxPageSize = 32768 // 32 KB
+
+	// NumSlots (2) + FreeSpaceStart (2) + FreeSpaceEnd (2) + Checksum (4) + Padding (22)
 	PageHeaderSize = 32
 
-	// NumSlots (2) + FreeSpaceStart (2) + FreeSpaceEnd (2)
-	pageHeaderPayloadSize = 2 + 2 + 2
+	// NumSlots (2) + FreeSpaceStart (2) + FreeSpaceEnd (2) + Checksum (4)
+	pageHeaderPayloadSize = 2 + 2 + 2 + 4
 	PagePaddingSize       = PageHeaderSize - pageHeaderPayloadSize
 
+	checksumOffset = 2 + 2 + 2
+	checksumSize   = 4
+
 	// Offset (2) + Size (2) + Status (1)
 	ItemPointerSize = 5
 )
@@ -36,6 +46,9 @@ const (
 	StatusActive  byte = 1
 )
 
+// контрольная сумма страницы, CRC32C
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
 // 32 bytes
 type PageHeader struct {
 	NumSlots       uint16
@@ -58,21 +71,32 @@ func NewItemPointer(offset uint16, size uint16) *ItemPointer {
 	}
 }
 
+// размер страницы равен длине RawPage
 type Page struct {
 	Header   *PageHeader
 	Pointers []*ItemPointer
 	RawPage  []byte
 }
 
-func NewEmptyPage() *Page {
+// размер страницы должен быть степенью двойки от MinPageSize до MaxPageSize
+func ValidatePageSize(pageSize int) error {
+	if pageSize < MinPageSize || pageSize > MaxPageSize || pageSize&(pageSize-1) != 0 {
+		return ErrInvalidPageSize(pageSize)
+	}
+
+	return nil
+}
+
+func NewEmptyPage(pageSize int) *Page {
 	header := &PageHeader{
 		NumSlots:       0,
 		FreeSpaceStart: PageHeaderSize,
-		FreeSpaceEnd:   PageSize,
+		FreeSpaceEnd:   uint16(pageSize),
 	}
 
 	page := &Page{
-		Header: header,
+		Header:  header,
+		RawPage: make([]byte, pageSize),
 	}
 
 	page.RawPage = page.Serialize()
@@ -81,11 +105,21 @@ func NewEmptyPage() *Page {
 }
 
 func DeserializePage(serialized []byte) (*Page, error) {
-	if len(serialized) != PageSize {
-		return nil, fmt.Errorf(
-			"failed to deserialize page: serialized page size not equal to %d bytes",
-			PageSize,
-		)
+	return DeserializePageVersion(serialized, CurrentFormatVersion)
+}
+
+// читает страницу файла данных указанной версии формата.
+// у страниц, записанных до появления контрольных сумм, сумма 0,
+// и только в файлах таких версий она не проверяется
+func DeserializePageVersion(serialized []byte, version uint16) (*Page, error) {
+	if err := ValidatePageSize(len(serialized)); err != nil {
+		return nil, fmt.Errorf("failed to deserialize page: %w", err)
+	}
+
+	stored := binary.BigEndian.Uint32(serialized[checksumOffset:])
+	unchecked := version < FileHeaderFormatVersion && stored == 0
+	if computed := checksum(serialized); !unchecked && stored != computed {
+		return nil, NewErrCorruptedPage(stored, computed)
 	}
 
 	deserialized := &Page{
@@ -124,28 +158,64 @@ func DeserializePage(serialized []byte) (*Page, error) {
 	return deserialized, nil
 }
 
-func (p *Page) Insert(data []byte) error {
+// записывает данные на страницу и возвращает номер их указателя.
+// указатель удаленной строки используется повторно, а место ее
+// данных освобождается уплотнением страницы
+func (p *Page) Insert(data []byte) (int, error) {
 	dataLen := uint16(len(data))
 
-	totalFreeSpace := p.Header.FreeSpaceEnd - p.Header.FreeSpaceStart
-	if totalFreeSpace < dataLen {
-		return NewErrCantFitDataIntoPage(dataLen, totalFreeSpace)
+	if freeSpace := p.FreeSpace(); freeSpace < len(data) {
+		return 0, NewErrCantFitDataIntoPage(dataLen, uint16(freeSpace))
 	}
 
-	p.Header.NumSlots += 1
-	p.Header.FreeSpaceStart += ItemPointerSize
-	p.Header.FreeSpaceEnd -= dataLen
+	slot := p.deletedSlot()
+
+	required := len(data)
+	if slot < 0 {
+		required += ItemPointerSize
+	}
+	if int(p.Header.FreeSpaceEnd)-int(p.Header.FreeSpaceStart) < required {
+		p.Compact()
 
+		// уплотнение отбрасывает удаленные указатели в конце списка,
+		// поэтому слот выбирается заново
+		slot = p.deletedSlot()
+	}
+
+	p.Header.FreeSpaceEnd -= dataLen
 	pointer := NewItemPointer(p.Header.FreeSpaceEnd, dataLen)
-	p.Pointers = append(p.Pointers, pointer)
+
+	if slot < 0 {
+		slot = len(p.Pointers)
+		p.Header.NumSlots += 1
+		p.Header.FreeSpaceStart += ItemPointerSize
+		p.Pointers = append(p.Pointers, pointer)
+	} else {
+		p.Pointers[slot] = pointer
+	}
 
 	copy(p.RawPage[p.Header.FreeSpaceEnd:], data)
 
-	return nil
+	return slot, nil
+}
+
+// номер первого указателя удаленной строки или -1
+func (p *Page) deletedSlot() int {
+	for i, pointer := range p.Pointers {
+		if pointer.Status == StatusDeleted {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (p *Page) Size() int {
+	return len(p.RawPage)
 }
 
 func (p *Page) Serialize() []byte {
-	serialized := make([]byte, PageSize)
+	serialized := make([]byte, p.Size())
 
 	// header
 	binary.BigEndian.PutUint16(serialized[0:], p.Header.NumSlots)
@@ -153,6 +223,7 @@ func (p *Page) Serialize() []byte {
 	binary.BigEndian.PutUint16(serialized[4:], p.Header.FreeSpaceEnd)
 
 	if p.Header.NumSlots == 0 {
+		binary.BigEndian.PutUint32(serialized[checksumOffset:], checksum(serialized))
 		return serialized
 	}
 
@@ -171,43 +242,126 @@ func (p *Page) Serialize() []byte {
 
 	copy(serialized[pointerOffset:], p.RawPage[pointerOffset:])
 
+	binary.BigEndian.PutUint32(serialized[checksumOffset:], checksum(serialized))
+
 	return serialized
 }
 
+// сумма по всей странице, кроме места под нее саму
+func checksum(serialized []byte) uint32 {
+	return ChecksumExcluding(serialized, checksumOffset)
+}
+
+// сдвигает активные записи к концу страницы, чтобы место удаленных
+// стало свободным. номера указателей активных записей не меняются,
+// удаленные указатели в конце списка отбрасываются
+func (p *Page) Compact() {
+	for len(p.Pointers) > 0 && p.Pointers[len(p.Pointers)-1].Status == StatusDeleted {
+		p.Pointers = p.Pointers[:len(p.Pointers)-1]
+		p.Header.NumSlots -= 1
+		p.Header.FreeSpaceStart -= ItemPointerSize
+	}
+
+	compacted := make([]byte, p.Size())
+	dataStart := p.Size()
+
+	for _, pointer := range p.Pointers {
+		if pointer.Status != StatusActive {
+			pointer.Offset = 0
+			pointer.Size = 0
+			continue
+		}
+
+		dataStart -= int(pointer.Size)
+		copy(compacted[dataStart:], p.GetDataByPointer(pointer))
+		pointer.Offset = uint16(dataStart)
+	}
+
+	p.RawPage = compacted
+	p.Header.FreeSpaceEnd = uint16(dataStart)
+}
+
 func (p *Page) FreeSpaceMoreThanRequired(requiredSpace int) bool {
-	freeSpace := p.Header.FreeSpaceEnd - p.Header.FreeSpaceStart
-	return int(freeSpace) >= requiredSpace
+	return p.FreeSpace() >= requiredSpace
+}
+
+// сколько байт данных поместится на страницу: с учетом места
+// удаленных строк и нового указателя, если свободного нет
+func (p *Page) FreeSpace() int {
+	freeSpace := int(p.Header.FreeSpaceEnd) - int(p.Header.FreeSpaceStart)
+
+	hasDeletedSlot := false
+	for _, pointer := range p.Pointers {
+		if pointer.Status == StatusDeleted {
+			freeSpace += int(pointer.Size)
+			hasDeletedSlot = true
+		}
+	}
+
+	if !hasDeletedSlot {
+		freeSpace -= ItemPointerSize
+	}
+
+	return max(freeSpace, 0)
 }
 
 func (p *Page) GetDataByPointer(pointer *ItemPointer) []byte {
 	return p.RawPage[pointer.Offset : pointer.Offset+pointer.Size]
 }
 
+// файл со страницами. *os.File удовлетворяет интерфейсу
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	Stat() (os.FileInfo, error)
+}
+
 type pagesIterator struct {
-	descriptor     *os.File
+	descriptor     File
+	pageSize       int
 	pageOffset     int64
 	numPages       int64
 	currentPageNum int64
 	reachedEnd     bool
 }
 
-func NewPagesIter(descriptor *os.File) (*pagesIterator, error) {
+// обходит страницы со строками файла данных,
+// предварительно проверив его заголовок
+func NewPagesIter(descriptor File) (*pagesIterator, error) {
+	header, err := ReadFileHeader(descriptor)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFileHeader: %w", err)
+	}
+
+	// файл старой версии нужно сначала обновить
+	if header.Version != CurrentFormatVersion {
+		return nil, ErrUnsupportedFormatVersion(header.Version)
+	}
+
 	fileInfo, err := descriptor.Stat()
 	if err != nil {
 		return nil, fmt.Errorf("os.Stat: %w", err)
 	}
 
-	numPages := fileInfo.Size() / PageSize
+	pageSize := int(header.PageSize)
+	numPages := fileInfo.Size() / int64(pageSize)
 
 	return &pagesIterator{
-		descriptor: descriptor,
-		numPages:   numPages,
-		pageOffset: -PageSize,
+		descriptor:     descriptor,
+		pageSize:       pageSize,
+		numPages:       numPages,
+		pageOffset:     FirstDataPageOffset(pageSize) - int64(pageSize),
+		currentPageNum: FirstDataPageOffset(pageSize) / int64(pageSize),
 	}, nil
 }
 
+// количество страниц со строками в файле данных такого размера
+func DataPagesNum(fileSize int64, pageSize int) int {
+	return int(max(fileSize-FirstDataPageOffset(pageSize), 0) / int64(pageSize))
+}
+
 func (i *pagesIterator) Next() bool {
-	i.pageOffset += PageSize
+	i.pageOffset += int64(i.pageSize)
 
 	if i.currentPageNum >= i.numPages {
 		i.reachedEnd = true
@@ -224,14 +378,35 @@ func (i *pagesIterator) ReachedEnd() bool {
 }
 
 func (i *pagesIterator) GetPage() (*Page, error) {
-	serialized := make([]byte, PageSize)
-	if _, err := i.descriptor.ReadAt(serialized, i.pageOffset); err != nil {
+	return ReadPageAt(i.descriptor, i.pageOffset, i.pageSize)
+}
+
+// размер страниц файла, который обходит итератор
+func (i *pagesIterator) PageSize() int {
+	return i.pageSize
+}
+
+// читает страницу по смещению в файле
+func ReadPageAt(descriptor io.ReaderAt, pageOffset int64, pageSize int) (*Page, error) {
+	return ReadPageAtVersion(descriptor, pageOffset, pageSize, CurrentFormatVersion)
+}
+
+// читает страницу по смещению в файле данных указанной версии формата
+func ReadPageAtVersion(
+	descriptor io.ReaderAt,
+	pageOffset int64,
+	pageSize int,
+	version uint16,
+) (*Page, error) {
+	serialized := make([]byte, pageSize)
+	if _, err := descriptor.ReadAt(serialized, pageOffset); err != nil {
 		return nil, fmt.Errorf("os.File.ReatAt: %w", err)
 	}
 
-	deserialized, err := DeserializePage(serialized)
+	deserialized, err := DeserializePageVersion(serialized, version)
 	if err != nil {
-		return nil, fmt.Errorf("DeserializePage: %w", err)
+		SetCorruptedPageNum(err, pageOffset/int64(pageSize))
+		return nil, fmt.Errorf("DeserializePageVersion: %w", err)
 	}
 
 	return deserialized, nil
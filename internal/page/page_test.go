@@ -1,6 +1,7 @@
 package page
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,15 +18,15 @@ func TestPage_SerializeDeserialize(t *testing.T) {
 			data3 = []byte("asdfasdfasdfasdf")
 		)
 
-		page := NewEmptyPage()
+		page := NewEmptyPage(DefaultPageSize)
 
-		err = page.Insert(data1)
+		_, err = page.Insert(data1)
 		require.NoError(t, err)
 
-		err = page.Insert(data2)
+		_, err = page.Insert(data2)
 		require.NoError(t, err)
 
-		err = page.Insert(data3)
+		_, err = page.Insert(data3)
 		require.NoError(t, err)
 
 		serialized := page.Serialize()
@@ -54,12 +55,12 @@ func TestPage_SerializeDeserialize(t *testing.T) {
 			data2 = make([]byte, 5000)
 		)
 
-		page := NewEmptyPage()
+		page := NewEmptyPage(DefaultPageSize)
 
-		err = page.Insert(data1)
+		_, err = page.Insert(data1)
 		require.NoError(t, err)
 
-		err = page.Insert(data2)
+		_, err = page.Insert(data2)
 		assert.EqualError(t, err, "cant fit data into page")
 
 		serialized := page.Serialize()
@@ -75,4 +76,172 @@ func TestPage_SerializeDeserialize(t *testing.T) {
 
 		assert.Equal(t, data1, gotData1)
 	})
-}
\ No newline at end of file
+
+	t.Run("место под указатель", func(t *testing.T) {
+		page := NewEmptyPage(DefaultPageSize)
+
+		// данные занимают все свободное место, но указателю его не хватит
+		data := make([]byte, DefaultPageSize-PageHeaderSize)
+		_, err := page.Insert(data)
+		assert.EqualError(t, err, "cant fit data into page")
+
+		data = make([]byte, page.FreeSpace())
+		_, err = page.Insert(data)
+		require.NoError(t, err)
+		assert.Equal(t, 0, page.FreeSpace())
+		assert.Equal(t, page.Header.FreeSpaceStart, page.Header.FreeSpaceEnd)
+	})
+
+	t.Run("уплотнение сохраняет номера указателей", func(t *testing.T) {
+		page := NewEmptyPage(DefaultPageSize)
+		for _, data := range []string{"first", "second", "third"} {
+			_, err := page.Insert([]byte(data))
+			require.NoError(t, err)
+		}
+		freeSpace := page.FreeSpace()
+
+		page.Pointers[1].Status = StatusDeleted
+		// место удаленной строки и ее указатель снова доступны
+		assert.Equal(t, freeSpace+len("second")+ItemPointerSize, page.FreeSpace())
+
+		page.Compact()
+
+		assert.Equal(t, 3, len(page.Pointers))
+		assert.Equal(t, freeSpace+len("second")+ItemPointerSize, page.FreeSpace())
+		assert.Equal(t, "first", string(page.GetDataByPointer(page.Pointers[0])))
+		assert.Equal(t, "third", string(page.GetDataByPointer(page.Pointers[2])))
+
+		restored, err := DeserializePage(page.Serialize())
+		require.NoError(t, err)
+		assert.Equal(t, "third", string(restored.GetDataByPointer(restored.Pointers[2])))
+	})
+	t.Run("повторное использование указателей удаленных строк", func(t *testing.T) {
+		page := NewEmptyPage(DefaultPageSize)
+
+		// страница заполнена строками по 1000 байт
+		data := make([]byte, 1000)
+		for i := 0; i < 8; i++ {
+			slot, err := page.Insert(data)
+			require.NoError(t, err)
+			assert.Equal(t, i, slot)
+		}
+		_, err := page.Insert(data)
+		assert.EqualError(t, err, "cant fit data into page")
+
+		page.Pointers[2].Status = StatusDeleted
+		page.Pointers[5].Status = StatusDeleted
+
+		// новая строка занимает первый освободившийся указатель,
+		// страница уплотняется, чтобы вместить ее данные
+		reused := bytes.Repeat([]byte{7}, 1500)
+		slot, err := page.Insert(reused)
+		require.NoError(t, err)
+		assert.Equal(t, 2, slot)
+		assert.Equal(t, 8, len(page.Pointers))
+
+		restored, err := DeserializePage(page.Serialize())
+		require.NoError(t, err)
+		assert.Equal(t, reused, restored.GetDataByPointer(restored.Pointers[2]))
+		assert.Equal(t, StatusDeleted, restored.Pointers[5].Status)
+		for _, i := range []int{0, 1, 3, 4, 6, 7} {
+			assert.Equal(t, data, restored.GetDataByPointer(restored.Pointers[i]))
+		}
+
+		// удаленные указатели в конце списка отбрасываются
+		page.Pointers[6].Status = StatusDeleted
+		page.Pointers[7].Status = StatusDeleted
+		page.Compact()
+		assert.Equal(t, 5, len(page.Pointers))
+		assert.Equal(t, uint16(5), page.Header.NumSlots)
+		assert.Equal(t, uint16(PageHeaderSize+5*ItemPointerSize), page.Header.FreeSpaceStart)
+	})
+
+	t.Run("удаленный указатель в конце списка при уплотнении", func(t *testing.T) {
+		page := NewEmptyPage(DefaultPageSize)
+
+		_, err := page.Insert(make([]byte, 100))
+		require.NoError(t, err)
+		_, err = page.Insert(make([]byte, 8000))
+		require.NoError(t, err)
+
+		page.Pointers[1].Status = StatusDeleted
+
+		// уплотнение отбрасывает указатель 1, и строка получает новый
+		data := bytes.Repeat([]byte{7}, 1000)
+		slot, err := page.Insert(data)
+		require.NoError(t, err)
+		assert.Equal(t, 1, slot)
+		assert.Equal(t, 2, len(page.Pointers))
+		assert.Equal(t, uint16(2), page.Header.NumSlots)
+
+		restored, err := DeserializePage(page.Serialize())
+		require.NoError(t, err)
+		assert.Equal(t, make([]byte, 100), restored.GetDataByPointer(restored.Pointers[0]))
+		assert.Equal(t, data, restored.GetDataByPointer(restored.Pointers[1]))
+	})
+}
+
+func TestPage_Checksum(t *testing.T) {
+	page := NewEmptyPage(DefaultPageSize)
+	_, err := page.Insert([]byte("hello world"))
+	require.NoError(t, err)
+
+	t.Run("поврежденная страница", func(t *testing.T) {
+		serialized := page.Serialize()
+		serialized[DefaultPageSize-1] ^= 0xFF
+
+		_, err := DeserializePage(serialized)
+
+		var corrupted *ErrCorruptedPage
+		require.ErrorAs(t, err, &corrupted)
+		assert.NotEqual(t, corrupted.Stored, corrupted.Computed)
+	})
+
+	t.Run("номер страницы в ошибке", func(t *testing.T) {
+		file := make([]byte, 0, 3*DefaultPageSize)
+		for i := 0; i < 3; i++ {
+			file = append(file, page.Serialize()...)
+		}
+		file[2*DefaultPageSize+PageHeaderSize] ^= 0xFF
+
+		for _, pageNum := range []int64{0, 1} {
+			_, err := ReadPageAt(bytes.NewReader(file), pageNum*DefaultPageSize, DefaultPageSize)
+			require.NoError(t, err)
+		}
+
+		_, err := ReadPageAt(bytes.NewReader(file), 2*DefaultPageSize, DefaultPageSize)
+
+		var corrupted *ErrCorruptedPage
+		require.ErrorAs(t, err, &corrupted)
+		assert.Equal(t, int64(2), corrupted.PageNum)
+	})
+
+	t.Run("страница без контрольной суммы", func(t *testing.T) {
+		serialized := page.Serialize()
+		clear(serialized[checksumOffset : checksumOffset+checksumSize])
+
+		// до появления контрольных сумм сумма страницы 0
+		deserialized, err := DeserializePageVersion(serialized, LegacyFormatVersion)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hello world"), deserialized.GetDataByPointer(deserialized.Pointers[0]))
+
+		for _, version := range []uint16{FileHeaderFormatVersion, CurrentFormatVersion} {
+			_, err := DeserializePageVersion(serialized, version)
+
+			var corrupted *ErrCorruptedPage
+			require.ErrorAs(t, err, &corrupted)
+			assert.Equal(t, uint32(0), corrupted.Stored)
+		}
+
+		_, err = DeserializePage(serialized)
+		assert.ErrorAs(t, err, new(*ErrCorruptedPage))
+	})
+
+	t.Run("поврежденная страница старой версии", func(t *testing.T) {
+		serialized := page.Serialize()
+		serialized[DefaultPageSize-1] ^= 0xFF
+
+		_, err := DeserializePageVersion(serialized, LegacyFormatVersion)
+		assert.ErrorAs(t, err, new(*ErrCorruptedPage))
+	})
+}
@@ -0,0 +1,305 @@
+package schema
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/artem-vildanov/small-db/internal/decimal"
+	"github.com/artem-vildanov/small-db/internal/jsondoc"
+	"github.com/google/uuid"
+)
+
+type DefaultGenerator string
+
+const (
+	// текущее время в UTC
+	NowGenerator DefaultGenerator = "now()"
+	// случайный uuid версии 4
+	UUIDGenerator DefaultGenerator = "uuid()"
+)
+
+// значение колонки, которое подставляется, если его не передали
+// при вставке: либо литерал, либо генератор
+type Default struct {
+	Value     any              `json:"value,omitempty"`
+	Generator DefaultGenerator `json:"generator,omitempty"`
+}
+
+func NewErrInvalidDefault(columnName string, columnType ColumnType) error {
+	return fmt.Errorf("default value of column %s doesnt match type %s", columnName, columnType)
+}
+
+func NewErrAmbiguousDefault(columnName string) error {
+	return fmt.Errorf("default of column %s must have either value or generator", columnName)
+}
+
+func NewErrUnsupportedDefaultGenerator(columnName string, generator DefaultGenerator) error {
+	return fmt.Errorf("default generator %s is not supported for column %s", generator, columnName)
+}
+
+// значение по умолчанию для новой записи. nil, если его нет
+func (c *Column) DefaultValue() any {
+	if c.Default == nil {
+		return nil
+	}
+
+	switch c.Default.Generator {
+	case NowGenerator:
+		now := time.Now().UTC()
+		if c.Type == TimestampType {
+			return now
+		}
+		return now.Format(time.RFC3339Nano)
+	case UUIDGenerator:
+		if c.Type == UUIDType {
+			return uuid.New()
+		}
+		return uuid.NewString()
+	default:
+		return c.Default.Value
+	}
+}
+
+// проверяет, что значение по умолчанию подходит к типу колонки,
+// и приводит литерал к типу, в котором записи хранят значения колонки
+func validateDefault(column *Column) error {
+	if column.Default == nil {
+		return nil
+	}
+
+	hasValue := column.Default.Value != nil
+	hasGenerator := column.Default.Generator != ""
+	if hasValue == hasGenerator {
+		return NewErrAmbiguousDefault(column.Name)
+	}
+
+	if hasGenerator {
+		switch column.Default.Generator {
+		case NowGenerator:
+			if column.Type != StringType && column.Type != TimestampType {
+				return NewErrUnsupportedDefaultGenerator(column.Name, column.Default.Generator)
+			}
+			return nil
+		case UUIDGenerator:
+			if column.Type != StringType && column.Type != UUIDType {
+				return NewErrUnsupportedDefaultGenerator(column.Name, column.Default.Generator)
+			}
+			return nil
+		default:
+			return NewErrUnsupportedDefaultGenerator(column.Name, column.Default.Generator)
+		}
+	}
+
+	value, ok := castDefaultValue(column, column.Default.Value)
+	if !ok {
+		return NewErrInvalidDefault(column.Name, column.Type)
+	}
+	column.Default.Value = value
+
+	return nil
+}
+
+func castDefaultValue(column *Column, raw any) (any, bool) {
+	columnType := column.Type
+
+	// числа из json читаются как json.Number, чтобы не терять точность int64
+	if number, ok := raw.(json.Number); ok && columnType != DecimalType && columnType != JSONType {
+		var err error
+		if columnType == Float64Type {
+			raw, err = number.Float64()
+		} else {
+			raw, err = number.Int64()
+		}
+
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	switch columnType {
+	case Int32Type:
+		switch v := raw.(type) {
+		case int32:
+			return v, true
+		case int:
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return nil, false
+			}
+			return int32(v), true
+		case int64:
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return nil, false
+			}
+			return int32(v), true
+		default:
+			return nil, false
+		}
+	case Int64Type:
+		switch v := raw.(type) {
+		case int64:
+			return v, true
+		case int:
+			return int64(v), true
+		case int32:
+			return int64(v), true
+		default:
+			return nil, false
+		}
+	case Float64Type:
+		switch v := raw.(type) {
+		case float64:
+			return v, !math.IsNaN(v)
+		case int:
+			return float64(v), true
+		default:
+			return nil, false
+		}
+	case TimestampType:
+		switch v := raw.(type) {
+		case time.Time:
+			return v.UTC(), true
+		// время в json хранится строкой
+		case string:
+			parsed, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, false
+			}
+			return parsed.UTC(), true
+		default:
+			return nil, false
+		}
+	case BytesType:
+		switch v := raw.(type) {
+		case []byte:
+			return v, true
+		// []byte в json хранится строкой base64
+		case string:
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, false
+			}
+			return decoded, true
+		default:
+			return nil, false
+		}
+	case UUIDType:
+		switch v := raw.(type) {
+		case uuid.UUID:
+			return v, true
+		case string:
+			parsed, err := uuid.Parse(v)
+			if err != nil {
+				return nil, false
+			}
+			return parsed, true
+		default:
+			return nil, false
+		}
+	case CharType:
+		v, ok := raw.(string)
+		if !ok || len(v) > column.Size {
+			return nil, false
+		}
+		return v, true
+	case DecimalType:
+		var value decimal.Decimal
+
+		switch v := raw.(type) {
+		case decimal.Decimal:
+			value = v
+		// decimal в json хранится строкой
+		case string:
+			parsed, err := decimal.Parse(v)
+			if err != nil {
+				return nil, false
+			}
+			value = parsed
+		case json.Number:
+			parsed, err := decimal.Parse(v.String())
+			if err != nil {
+				return nil, false
+			}
+			value = parsed
+		default:
+			return nil, false
+		}
+
+		// значение с лишними цифрами после точки не подходит
+		rescaled := value.Rescale(column.Scale)
+		return rescaled, rescaled.Equal(value) && rescaled.Fits(column.Precision)
+	case JSONType:
+		var (
+			document any
+			err      error
+		)
+
+		// строка - текст документа, остальные значения приводятся
+		// к документу как есть
+		switch v := raw.(type) {
+		case string:
+			document, err = jsondoc.Parse([]byte(v))
+		case []byte:
+			document, err = jsondoc.Parse(v)
+		case json.RawMessage:
+			document, err = jsondoc.Parse(v)
+		default:
+			document, err = jsondoc.FromGo(v)
+		}
+		if err != nil {
+			return nil, false
+		}
+
+		// в схеме хранится текст, который переживает json без изменений
+		text, err := jsondoc.Marshal(document)
+		if err != nil {
+			return nil, false
+		}
+		return string(text), true
+	case Int32ArrayType:
+		return castDefaultArray[int32](column, raw)
+	case StringArrayType:
+		return castDefaultArray[string](column, raw)
+	case BoolArrayType:
+		return castDefaultArray[bool](column, raw)
+	case EnumType:
+		v, ok := raw.(string)
+		if !ok || !slices.Contains(column.Labels, v) {
+			return nil, false
+		}
+		return v, true
+	case StringType:
+		v, ok := raw.(string)
+		return v, ok
+	case BoolType:
+		v, ok := raw.(bool)
+		return v, ok
+	default:
+		return nil, false
+	}
+}
+
+// каждый элемент приводится к типу элементов колонки
+func castDefaultArray[T any](column *Column, raw any) (any, bool) {
+	elements, ok := ArrayElements(raw)
+	if !ok {
+		return nil, false
+	}
+
+	elementType, _ := ElementType(column.Type)
+	elementColumn := &Column{Name: column.Name, Type: elementType}
+
+	array := make([]T, 0, len(elements))
+	for _, element := range elements {
+		casted, ok := castDefaultValue(elementColumn, element)
+		if !ok {
+			return nil, false
+		}
+		array = append(array, casted.(T))
+	}
+
+	return array, true
+}
@@ -1,34 +1,144 @@
 package schema
 
+import "reflect"
+
 type ColumnType string
 
 const (
-	StringType ColumnType = "string"
-	Int32Type  ColumnType = "int32"
-	BoolType   ColumnType = "bool"
+	StringType  ColumnType = "string"
+	Int32Type   ColumnType = "int32"
+	BoolType    ColumnType = "bool"
+	Int64Type   ColumnType = "int64"
+	Float64Type ColumnType = "float64"
+	// момент времени в UTC с точностью до наносекунды
+	TimestampType ColumnType = "timestamp"
+	BytesType     ColumnType = "bytes"
+	UUIDType      ColumnType = "uuid"
+	// строка фиксированной длины в байтах, которую задает размер колонки.
+	// короткие значения дополняются пробелами
+	CharType ColumnType = "char"
+	// точное десятичное число decimal(precision, scale).
+	// размер колонки - decimal.Size(precision), вычисляется при создании схемы
+	DecimalType ColumnType = "decimal"
+	// документ json, хранится в двоичной форме
+	JSONType ColumnType = "json"
+	// массивы значений. элементы не бывают null
+	Int32ArrayType  ColumnType = "array<int32>"
+	StringArrayType ColumnType = "array<string>"
+	BoolArrayType   ColumnType = "array<bool>"
+	// одна из меток, объявленных в колонке. хранится номер метки,
+	// размер колонки - EnumSize(количество меток), вычисляется при создании схемы
+	EnumType ColumnType = "enum"
 )
 
 var DynamicMemoTypes = []ColumnType{
 	StringType,
+	BytesType,
+	JSONType,
+	Int32ArrayType,
+	StringArrayType,
+	BoolArrayType,
 }
 
 const (
 	DynamicMemoTypeColumnSize = -1
+	// наибольшая длина char: запись должна помещаться в страницу
+	MaxCharSize = 1024
+	// номер метки enum занимает не больше 2 байт
+	MaxEnumLabels = 1 << 16
 )
 
 type ColumnSize int
 
 // размеры в байтах
 const (
-	Int32Size ColumnSize = 4
-	BoolSize  ColumnSize = 1
+	Int32Size     ColumnSize = 4
+	BoolSize      ColumnSize = 1
+	Int64Size     ColumnSize = 8
+	Float64Size   ColumnSize = 8
+	TimestampSize ColumnSize = 8
+	UUIDSize      ColumnSize = 16
 )
 
+// размер значений типа в записи. размер char задает колонка
+func TypeSize(columnType ColumnType) (int, bool) {
+	switch columnType {
+	case StringType, BytesType, JSONType, Int32ArrayType, StringArrayType, BoolArrayType:
+		return DynamicMemoTypeColumnSize, true
+	case Int32Type:
+		return int(Int32Size), true
+	case BoolType:
+		return int(BoolSize), true
+	case Int64Type:
+		return int(Int64Size), true
+	case Float64Type:
+		return int(Float64Size), true
+	case TimestampType:
+		return int(TimestampSize), true
+	case UUIDType:
+		return int(UUIDSize), true
+	default:
+		return 0, false
+	}
+}
+
+// размер номера метки enum: 1 байт, если меток не больше 256, иначе 2
+func EnumSize(labelsCount int) int {
+	if labelsCount <= 1<<8 {
+		return 1
+	}
+	return 2
+}
+
+// тип элементов массива. false, если тип - не массив
+func ElementType(columnType ColumnType) (ColumnType, bool) {
+	switch columnType {
+	case Int32ArrayType:
+		return Int32Type, true
+	case StringArrayType:
+		return StringType, true
+	case BoolArrayType:
+		return BoolType, true
+	default:
+		return "", false
+	}
+}
+
+// элементы значения-массива, например []int32 или []any.
+// false, если значение - не срез
+func ArrayElements(value any) ([]any, bool) {
+	switch v := value.(type) {
+	case []any:
+		return v, true
+	// []byte - значение bytes, а не массив
+	case []byte, nil:
+		return nil, false
+	}
+
+	slice := reflect.ValueOf(value)
+	if slice.Kind() != reflect.Slice {
+		return nil, false
+	}
+
+	elements := make([]any, slice.Len())
+	for i := range elements {
+		elements[i] = slice.Index(i).Interface()
+	}
+
+	return elements, true
+}
+
 type Column struct {
 	Name     string     `json:"name"`
 	Type     ColumnType `json:"type"`
 	Size     int        `json:"size"`
 	Nullable bool       `json:"nullable"`
+	Default  *Default   `json:"default,omitempty"`
+	// общее количество цифр и количество цифр после точки decimal
+	Precision int `json:"precision,omitempty"`
+	Scale     int `json:"scale,omitempty"`
+	// метки enum в порядке объявления
+	Labels []string `json:"labels,omitempty"`
 }
 
 type Schema struct {
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -9,8 +10,10 @@ import (
 	"path/filepath"
 	"slices"
 	"sort"
+	"sync"
 
 	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/decimal"
 	"github.com/google/uuid"
 )
 
@@ -18,9 +21,39 @@ func NewErrInvalidPkName(pkName string) error {
 	return fmt.Errorf("invalid pk name: %s", pkName)
 }
 
+func NewErrNullablePk(pkName string) error {
+	return fmt.Errorf("pk column %s cannot be nullable", pkName)
+}
+
+func NewErrUnknownColumnType(columnName string, columnType ColumnType) error {
+	return fmt.Errorf("column %s has unknown type %s", columnName, columnType)
+}
+
+func NewErrInvalidColumnSize(columnName string, size int, expected int) error {
+	return fmt.Errorf("column %s has size %d, expected %d", columnName, size, expected)
+}
+
+func NewErrInvalidCharSize(columnName string, size int) error {
+	return fmt.Errorf("column %s has char size %d, expected from 1 to %d", columnName, size, MaxCharSize)
+}
+
+func NewErrInvalidDecimalScale(columnName string, scale int, precision int) error {
+	return fmt.Errorf("column %s has decimal scale %d, expected from 0 to %d", columnName, scale, precision)
+}
+
+func NewErrInvalidEnumLabelsCount(columnName string, count int) error {
+	return fmt.Errorf("column %s has %d enum labels, expected from 1 to %d", columnName, count, MaxEnumLabels)
+}
+
+func NewErrDuplicateEnumLabel(columnName string, label string) error {
+	return fmt.Errorf("column %s has duplicate enum label %q", columnName, label)
+}
+
 type SchemaManager struct {
 	schemasDirPath string
-	IdToSchema     map[string]*Schema
+	// защищает IdToSchema
+	mu         sync.RWMutex
+	IdToSchema map[string]*Schema
 }
 
 func InitSchemaManager(schemasDirPath string) (*SchemaManager, error) {
@@ -46,8 +79,21 @@ func InitSchemaManager(schemasDirPath string) (*SchemaManager, error) {
 		}
 
 		var schema Schema
-		if err := json.Unmarshal(rawData, &schema); err != nil {
-			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+
+		decoder := json.NewDecoder(bytes.NewReader(rawData))
+		decoder.UseNumber()
+		if err := decoder.Decode(&schema); err != nil {
+			return nil, fmt.Errorf("Decoder.Decode: %w", err)
+		}
+
+		// NameToColumn не сохраняется в json, восстанавливаем по колонкам
+		schema.NameToColumn = make(map[string]*Column, len(schema.Columns))
+		for _, column := range schema.Columns {
+			schema.NameToColumn[column.Name] = column
+
+			if err := validateDefault(column); err != nil {
+				return nil, fmt.Errorf("schema %s: %w", schema.ID, err)
+			}
 		}
 
 		idToSchema[schema.ID] = &schema
@@ -64,11 +110,27 @@ func (m *SchemaManager) CreateNewSchema(
 	primaryKeys []string,
 ) (*Schema, error) {
 	for _, primaryKey := range primaryKeys {
-		if !slices.ContainsFunc(columns, func(column *Column) bool {
+		i := slices.IndexFunc(columns, func(column *Column) bool {
 			return column.Name == primaryKey
-		}) {
+		})
+		if i < 0 {
 			return nil, NewErrInvalidPkName(primaryKey)
 		}
+
+		if columns[i].Nullable {
+			return nil, NewErrNullablePk(primaryKey)
+		}
+	}
+
+	for _, column := range columns {
+		if err := validateColumnSize(column); err != nil {
+			return nil, err
+		}
+		deriveColumnSize(column)
+
+		if err := validateDefault(column); err != nil {
+			return nil, err
+		}
 	}
 
 	schemaID := uuid.NewString()
@@ -114,11 +176,21 @@ func (m *SchemaManager) CreateNewSchema(
 		return nil, fmt.Errorf("File.Write: %w", err)
 	}
 
+	m.mu.Lock()
 	m.IdToSchema[schema.ID] = schema
+	m.mu.Unlock()
 
 	return schema, nil
 }
 
+func (m *SchemaManager) GetSchema(schemaID string) (*Schema, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	schema, exists := m.IdToSchema[schemaID]
+	return schema, exists
+}
+
 func (m *SchemaManager) hashColumns(columns []*Column) (string, error) {
 	sortedColumns := make([]*Column, 0, len(columns))
 	copy(sortedColumns, columns)
@@ -136,6 +208,65 @@ func (m *SchemaManager) hashColumns(columns []*Column) (string, error) {
 	return hex.EncodeToString(hashBytes[:]), nil
 }
 
+func validateColumnSize(column *Column) error {
+	if column.Type == DecimalType {
+		if column.Precision < 1 || column.Precision > decimal.MaxPrecision {
+			return fmt.Errorf("column %s: %w", column.Name, decimal.ErrInvalidPrecision(column.Precision))
+		}
+
+		if column.Scale < 0 || column.Scale > column.Precision {
+			return NewErrInvalidDecimalScale(column.Name, column.Scale, column.Precision)
+		}
+
+		return nil
+	}
+
+	if column.Type == EnumType {
+		if len(column.Labels) < 1 || len(column.Labels) > MaxEnumLabels {
+			return NewErrInvalidEnumLabelsCount(column.Name, len(column.Labels))
+		}
+
+		seen := make(map[string]struct{}, len(column.Labels))
+		for _, label := range column.Labels {
+			if _, exists := seen[label]; exists {
+				return NewErrDuplicateEnumLabel(column.Name, label)
+			}
+			seen[label] = struct{}{}
+		}
+
+		return nil
+	}
+
+	if column.Type == CharType {
+		if column.Size < 1 || column.Size > MaxCharSize {
+			return NewErrInvalidCharSize(column.Name, column.Size)
+		}
+		return nil
+	}
+
+	size, known := TypeSize(column.Type)
+	if !known {
+		return NewErrUnknownColumnType(column.Name, column.Type)
+	}
+
+	if column.Size != size {
+		return NewErrInvalidColumnSize(column.Name, column.Size, size)
+	}
+
+	return nil
+}
+
+// размер колонок с параметрами типа вычисляется по ним,
+// а не задается вызывающим
+func deriveColumnSize(column *Column) {
+	switch column.Type {
+	case DecimalType:
+		column.Size = decimal.Size(column.Precision)
+	case EnumType:
+		column.Size = EnumSize(len(column.Labels))
+	}
+}
+
 func getSchemaFilePathTemplate(schemasDirPath string) string {
 	return fmt.Sprintf("%s%s%s", schemasDirPath, "%s", consts.JsonExtension)
 }
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/artem-vildanov/small-db/internal/decimal"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -243,3 +246,238 @@ func TestSchemaManager_CreateNewSchema(t *testing.T) {
 	assert.Equal(t, schema.Columns, gotSchema.Columns)
 	assert.Equal(t, schema.Hash, gotSchema.Hash)
 }
+
+func TestSchemaManager_CreateNewSchema_NullablePk(t *testing.T) {
+	manager, err := InitSchemaManager("./")
+	require.NoError(t, err)
+
+	_, err = manager.CreateNewSchema([]*Column{
+		{
+			Name:     "id",
+			Type:     Int32Type,
+			Size:     int(Int32Size),
+			Nullable: true,
+		},
+	}, []string{"id"})
+	assert.EqualError(t, err, "pk column id cannot be nullable")
+}
+
+func TestSchemaManager_CreateNewSchema_Default(t *testing.T) {
+	manager, err := InitSchemaManager("./")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		column        *Column
+		expectedError string
+	}{
+		{
+			name:          "литерал другого типа",
+			column:        &Column{Name: "count", Type: Int32Type, Size: int(Int32Size), Default: &Default{Value: "one"}},
+			expectedError: "default value of column count doesnt match type int32",
+		},
+		{
+			name:          "литерал вне диапазона типа",
+			column:        &Column{Name: "count", Type: Int32Type, Size: int(Int32Size), Default: &Default{Value: 1 << 40}},
+			expectedError: "default value of column count doesnt match type int32",
+		},
+		{
+			name: "литерал и генератор одновременно",
+			column: &Column{
+				Name:    "name",
+				Type:    StringType,
+				Size:    DynamicMemoTypeColumnSize,
+				Default: &Default{Value: "name", Generator: UUIDGenerator},
+			},
+			expectedError: "default of column name must have either value or generator",
+		},
+		{
+			name:          "пустое значение по умолчанию",
+			column:        &Column{Name: "active", Type: BoolType, Size: int(BoolSize), Default: &Default{}},
+			expectedError: "default of column active must have either value or generator",
+		},
+		{
+			name:          "неизвестный генератор",
+			column:        &Column{Name: "name", Type: StringType, Size: DynamicMemoTypeColumnSize, Default: &Default{Generator: "random()"}},
+			expectedError: "default generator random() is not supported for column name",
+		},
+		{
+			name:          "литерал timestamp",
+			column:        &Column{Name: "created_at", Type: TimestampType, Size: int(TimestampSize), Default: &Default{Value: "yesterday"}},
+			expectedError: "default value of column created_at doesnt match type timestamp",
+		},
+		{
+			name:          "литерал длиннее char",
+			column:        &Column{Name: "code", Type: CharType, Size: 2, Default: &Default{Value: "abc"}},
+			expectedError: "default value of column code doesnt match type char",
+		},
+		{
+			name:          "литерал не помещается в decimal",
+			column:        &Column{Name: "price", Type: DecimalType, Precision: 4, Scale: 2, Default: &Default{Value: "99.999"}},
+			expectedError: "default value of column price doesnt match type decimal",
+		},
+		{
+			name:          "литерал с лишними цифрами после точки",
+			column:        &Column{Name: "price", Type: DecimalType, Precision: 10, Scale: 2, Default: &Default{Value: "1.005"}},
+			expectedError: "default value of column price doesnt match type decimal",
+		},
+		{
+			name:          "литерал не является документом json",
+			column:        &Column{Name: "meta", Type: JSONType, Size: DynamicMemoTypeColumnSize, Default: &Default{Value: "{"}},
+			expectedError: "default value of column meta doesnt match type json",
+		},
+		{
+			name:          "элемент массива другого типа",
+			column:        &Column{Name: "ids", Type: Int32ArrayType, Size: DynamicMemoTypeColumnSize, Default: &Default{Value: []any{1, "2"}}},
+			expectedError: "default value of column ids doesnt match type array<int32>",
+		},
+		{
+			name:          "неизвестная метка enum",
+			column:        &Column{Name: "status", Type: EnumType, Labels: []string{"new", "done"}, Default: &Default{Value: "old"}},
+			expectedError: "default value of column status doesnt match type enum",
+		},
+		{
+			name:          "генератор не подходит к типу",
+			column:        &Column{Name: "active", Type: BoolType, Size: int(BoolSize), Default: &Default{Generator: NowGenerator}},
+			expectedError: "default generator now() is not supported for column active",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := manager.CreateNewSchema([]*Column{tc.column}, []string{})
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+
+	t.Run("значения по умолчанию сохраняются в схеме", func(t *testing.T) {
+		schema, err := manager.CreateNewSchema([]*Column{
+			{Name: "id", Type: StringType, Size: DynamicMemoTypeColumnSize, Default: &Default{Generator: UUIDGenerator}},
+			{Name: "count", Type: Int32Type, Size: int(Int32Size), Default: &Default{Value: 5}},
+			{Name: "active", Type: BoolType, Size: int(BoolSize), Default: &Default{Value: true}},
+			{Name: "big", Type: Int64Type, Size: int(Int64Size), Default: &Default{Value: int64(1<<62 + 1)}},
+			{Name: "ratio", Type: Float64Type, Size: int(Float64Size), Default: &Default{Value: 0.5}},
+			{Name: "token", Type: UUIDType, Size: int(UUIDSize), Default: &Default{Generator: UUIDGenerator}},
+			{Name: "owner", Type: UUIDType, Size: int(UUIDSize), Default: &Default{Value: "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}},
+			{Name: "payload", Type: BytesType, Size: DynamicMemoTypeColumnSize, Default: &Default{Value: []byte{0x00, 0xFF}}},
+			{Name: "code", Type: CharType, Size: 3, Default: &Default{Value: "ab"}},
+			{Name: "price", Type: DecimalType, Precision: 10, Scale: 2, Default: &Default{Value: "12.5"}},
+			{Name: "meta", Type: JSONType, Size: DynamicMemoTypeColumnSize, Default: &Default{Value: map[string]any{"tags": []string{}, "v": 1}}},
+			{Name: "ids", Type: Int32ArrayType, Size: DynamicMemoTypeColumnSize, Default: &Default{Value: []int{1, 2}}},
+			{Name: "tags", Type: StringArrayType, Size: DynamicMemoTypeColumnSize, Default: &Default{Value: []string{}}},
+			{Name: "status", Type: EnumType, Labels: []string{"new", "done"}, Default: &Default{Value: "new"}},
+			{Name: "created_at", Type: TimestampType, Size: int(TimestampSize), Default: &Default{Generator: NowGenerator}},
+			{
+				Name:    "updated_at",
+				Type:    TimestampType,
+				Size:    int(TimestampSize),
+				Default: &Default{Value: time.Date(2024, time.March, 1, 12, 0, 0, 1, time.FixedZone("MSK", 3*60*60))},
+			},
+		}, []string{"id"})
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, os.Remove(fmt.Sprintf(getSchemaFilePathTemplate("./"), schema.ID)))
+		}()
+
+		// литерал приводится к типу колонки
+		assert.Equal(t, int32(5), schema.NameToColumn["count"].Default.Value)
+		assert.Equal(t, time.Date(2024, time.March, 1, 9, 0, 0, 1, time.UTC), schema.NameToColumn["updated_at"].Default.Value)
+		assert.Equal(t, "12.50", schema.NameToColumn["price"].Default.Value.(decimal.Decimal).String())
+		assert.Equal(t, `{"tags":[],"v":1}`, schema.NameToColumn["meta"].Default.Value)
+		assert.Equal(t, []int32{1, 2}, schema.NameToColumn["ids"].Default.Value)
+
+		reloaded, err := InitSchemaManager("./")
+		require.NoError(t, err)
+
+		gotSchema, ok := reloaded.GetSchema(schema.ID)
+		require.True(t, ok)
+		assert.Equal(t, schema.Columns, gotSchema.Columns)
+	})
+}
+
+func TestColumn_DefaultValue(t *testing.T) {
+	t.Run("без значения по умолчанию", func(t *testing.T) {
+		column := &Column{Name: "count", Type: Int32Type}
+		assert.Nil(t, column.DefaultValue())
+	})
+
+	t.Run("генераторы возвращают новое значение", func(t *testing.T) {
+		column := &Column{Name: "id", Type: StringType, Default: &Default{Generator: UUIDGenerator}}
+		assert.NotEqual(t, column.DefaultValue(), column.DefaultValue())
+
+		column = &Column{Name: "created_at", Type: StringType, Default: &Default{Generator: NowGenerator}}
+		_, err := time.Parse(time.RFC3339Nano, column.DefaultValue().(string))
+		assert.NoError(t, err)
+
+		column = &Column{Name: "token", Type: UUIDType, Default: &Default{Generator: UUIDGenerator}}
+		assert.IsType(t, uuid.UUID{}, column.DefaultValue())
+
+		column = &Column{Name: "created_at", Type: TimestampType, Default: &Default{Generator: NowGenerator}}
+		assert.Equal(t, time.UTC, column.DefaultValue().(time.Time).Location())
+	})
+}
+
+func TestSchemaManager_CreateNewSchema_ColumnSize(t *testing.T) {
+	manager, err := InitSchemaManager("./")
+	require.NoError(t, err)
+
+	_, err = manager.CreateNewSchema([]*Column{
+		{Name: "status", Type: EnumType},
+	}, []string{})
+	assert.EqualError(t, err, "column status has 0 enum labels, expected from 1 to 65536")
+
+	_, err = manager.CreateNewSchema([]*Column{
+		{Name: "status", Type: EnumType, Labels: []string{"new", "done", "new"}},
+	}, []string{})
+	assert.EqualError(t, err, `column status has duplicate enum label "new"`)
+
+	_, err = manager.CreateNewSchema([]*Column{
+		{Name: "amount", Type: Float64Type, Size: int(Int32Size)},
+	}, []string{})
+	assert.EqualError(t, err, "column amount has size 4, expected 8")
+
+	_, err = manager.CreateNewSchema([]*Column{
+		{Name: "amount", Type: "money", Size: 8},
+	}, []string{})
+	assert.EqualError(t, err, "column amount has unknown type money")
+
+	_, err = manager.CreateNewSchema([]*Column{
+		{Name: "code", Type: CharType, Size: DynamicMemoTypeColumnSize},
+	}, []string{})
+	assert.EqualError(t, err, "column code has char size -1, expected from 1 to 1024")
+
+	_, err = manager.CreateNewSchema([]*Column{
+		{Name: "price", Type: DecimalType, Precision: 39},
+	}, []string{})
+	assert.EqualError(t, err, "column price: decimal precision 39 is out of range from 1 to 38")
+
+	_, err = manager.CreateNewSchema([]*Column{
+		{Name: "price", Type: DecimalType, Precision: 4, Scale: 5},
+	}, []string{})
+	assert.EqualError(t, err, "column price has decimal scale 5, expected from 0 to 4")
+
+	t.Run("размер decimal и enum вычисляется по параметрам типа", func(t *testing.T) {
+		labels := make([]string, 257)
+		for i := range labels {
+			labels[i] = fmt.Sprint(i)
+		}
+
+		schema, err := manager.CreateNewSchema([]*Column{
+			{Name: "price", Type: DecimalType, Precision: 4, Scale: 2},
+			{Name: "total", Type: DecimalType, Size: 16, Precision: 20},
+			{Name: "status", Type: EnumType, Labels: []string{"new", "done"}},
+			{Name: "code", Type: EnumType, Size: 1, Labels: labels},
+		}, []string{})
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, os.Remove(fmt.Sprintf(getSchemaFilePathTemplate("./"), schema.ID)))
+		}()
+
+		assert.Equal(t, decimal.Size(4), schema.NameToColumn["price"].Size)
+		assert.Equal(t, decimal.Size(20), schema.NameToColumn["total"].Size)
+		assert.Equal(t, 1, schema.NameToColumn["status"].Size)
+		assert.Equal(t, 2, schema.NameToColumn["code"].Size)
+	})
+}
@@ -0,0 +1,332 @@
+package table
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/artem-vildanov/small-db/internal/index"
+	"github.com/artem-vildanov/small-db/internal/page"
+)
+
+// диапазон ключей одного индекса, в котором лежат строки-кандидаты
+type indexScan struct {
+	Index *Index
+	Lower *index.Bound
+	Upper *index.Bound
+}
+
+/*
+Подбирает сканирования индексов, покрывающие все строки,
+которые могут удовлетворить предикату. nil означает, что
+индекс использовать нельзя и нужен полный обход таблицы.
+
+Найденные строки все равно проверяются полным предикатом,
+поэтому диапазоны могут быть шире, чем нужно.
+*/
+func chooseIndexScans(table *Table, predicate *boundPredicate) []*indexScan {
+	switch predicate.Operator {
+	case OpEq, OpLt, OpLte, OpGt, OpGte:
+		return chooseIndexScansForAnd(table, []*boundPredicate{predicate})
+	case OpAnd:
+		if scans := chooseIndexScansForAnd(table, predicate.operands); scans != nil {
+			return scans
+		}
+
+		// достаточно сузить выборку по одному из операндов
+		for _, operand := range predicate.operands {
+			if scans := chooseIndexScans(table, operand); scans != nil {
+				return scans
+			}
+		}
+
+		return nil
+	case OpOr:
+		// каждый операнд должен выполняться через индекс,
+		// иначе все равно придется обойти таблицу
+		scans := make([]*indexScan, 0, len(predicate.operands))
+		for _, operand := range predicate.operands {
+			operandScans := chooseIndexScans(table, operand)
+			if operandScans == nil {
+				return nil
+			}
+
+			scans = append(scans, operandScans...)
+		}
+
+		return scans
+	default:
+		return nil
+	}
+}
+
+// выбирает индекс, у которого самый длинный префикс колонок
+// покрыт условиями на равенство, с возможным диапазоном
+// по следующей за префиксом колонке
+func chooseIndexScansForAnd(table *Table, conditions []*boundPredicate) []*indexScan {
+	var (
+		bestScan  *indexScan
+		bestScore int
+	)
+
+	for _, tableIndex := range table.Indexes {
+		var (
+			prefix     = make([]byte, 0)
+			score      int
+			lower      *index.Bound
+			upper      *index.Bound
+			rangeFound bool
+		)
+
+		for _, columnName := range tableIndex.Columns {
+			eq := findCondition(conditions, columnName, OpEq)
+			if eq != nil {
+				prefix = append(prefix, eq.valueKey...)
+				score += 2
+				continue
+			}
+
+			lower = rangeLowerBound(prefix, conditions, columnName)
+			upper = rangeUpperBound(prefix, conditions, columnName)
+			rangeFound = lower != nil || upper != nil
+			if rangeFound {
+				score++
+			}
+
+			break
+		}
+
+		if score <= bestScore {
+			continue
+		}
+
+		if !rangeFound {
+			lower, upper = prefixBounds(prefix)
+		} else {
+			if lower == nil {
+				lower, _ = prefixBounds(prefix)
+			}
+			if upper == nil {
+				_, upper = prefixBounds(prefix)
+			}
+		}
+
+		bestScore = score
+		bestScan = &indexScan{
+			Index: tableIndex,
+			Lower: boundLowerKey(lower),
+			Upper: boundUpperKey(upper),
+		}
+	}
+
+	if bestScan == nil {
+		return nil
+	}
+
+	return []*indexScan{bestScan}
+}
+
+func findCondition(
+	conditions []*boundPredicate,
+	columnName string,
+	operators ...Operator,
+) *boundPredicate {
+	for _, condition := range conditions {
+		if condition.Column != columnName {
+			continue
+		}
+
+		for _, operator := range operators {
+			if condition.Operator == operator {
+				return condition
+			}
+		}
+	}
+
+	return nil
+}
+
+func rangeLowerBound(
+	prefix []byte,
+	conditions []*boundPredicate,
+	columnName string,
+) *index.Bound {
+	condition := findCondition(conditions, columnName, OpGt, OpGte)
+	if condition == nil {
+		return nil
+	}
+
+	key := append(append([]byte{}, prefix...), condition.valueKey...)
+	if condition.Operator == OpGte {
+		return &index.Bound{Key: key, Inclusive: true}
+	}
+
+	// ключи составного индекса с этим префиксом больше самого префикса,
+	// поэтому строгую границу сдвигаем за все такие ключи
+	successor := prefixSuccessor(key)
+	if successor == nil {
+		return &index.Bound{Key: key, Inclusive: false}
+	}
+
+	return &index.Bound{Key: successor, Inclusive: true}
+}
+
+func rangeUpperBound(
+	prefix []byte,
+	conditions []*boundPredicate,
+	columnName string,
+) *index.Bound {
+	condition := findCondition(conditions, columnName, OpLt, OpLte)
+	if condition == nil {
+		return nil
+	}
+
+	key := append(append([]byte{}, prefix...), condition.valueKey...)
+	if condition.Operator == OpLt {
+		return &index.Bound{Key: key, Inclusive: false}
+	}
+
+	successor := prefixSuccessor(key)
+	if successor == nil {
+		return nil
+	}
+
+	return &index.Bound{Key: successor, Inclusive: false}
+}
+
+// границы, в которые попадают все ключи, начинающиеся с prefix
+func prefixBounds(prefix []byte) (*index.Bound, *index.Bound) {
+	if len(prefix) == 0 {
+		return nil, nil
+	}
+
+	lower := &index.Bound{Key: prefix, Inclusive: true}
+
+	successor := prefixSuccessor(prefix)
+	if successor == nil {
+		return lower, nil
+	}
+
+	return lower, &index.Bound{Key: successor, Inclusive: false}
+}
+
+// индекс хранит длинные ключи укороченными и упорядоченными только
+// по префиксу, поэтому граница по длинному ключу расширяется до всех
+// ключей с тем же префиксом
+func boundLowerKey(lower *index.Bound) *index.Bound {
+	if lower == nil || !index.IsTruncatedKey(lower.Key) {
+		return lower
+	}
+
+	return &index.Bound{Key: lower.Key[:index.MaxKeyPrefixSize], Inclusive: true}
+}
+
+func boundUpperKey(upper *index.Bound) *index.Bound {
+	if upper == nil || !index.IsTruncatedKey(upper.Key) {
+		return upper
+	}
+
+	successor := prefixSuccessor(upper.Key[:index.MaxKeyPrefixSize])
+	if successor == nil {
+		return nil
+	}
+
+	return &index.Bound{Key: successor, Inclusive: false}
+}
+
+// наименьший ключ, который больше всех ключей с префиксом prefix.
+// nil, если такого ключа нет (префикс состоит из 0xFF)
+func prefixSuccessor(prefix []byte) []byte {
+	successor := append([]byte{}, prefix...)
+	for i := len(successor) - 1; i >= 0; i-- {
+		if successor[i] != 0xFF {
+			successor[i]++
+			return successor[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// читает строки-кандидаты по индексам и проверяет их полным предикатом
+func (m *TableManager) matchByIndexScans(
+	pager *pager,
+	visible func(tupleHeader) bool,
+	dataDescriptor page.File,
+	overflow io.ReaderAt,
+	table *Table,
+	scans []*indexScan,
+	predicate *boundPredicate,
+) ([]*matchedCondition, error) {
+	locations := make([]index.Location, 0, recordsPreallocSize)
+
+	for _, scan := range scans {
+		if err := m.withIndex(pager, scan.Index, func(tree *index.BTree) error {
+			found, err := tree.Range(scan.Lower, scan.Upper)
+			if err != nil {
+				return fmt.Errorf("BTree.Range: %w", err)
+			}
+
+			locations = append(locations, found...)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("index %s: %w", scan.Index.Name, err)
+		}
+	}
+
+	// порядок строк как при полном обходе таблицы.
+	// при OR одна строка может попасть в несколько диапазонов
+	slices.SortFunc(locations, compareLocations)
+	locations = slices.Compact(locations)
+
+	var (
+		matches    = make([]*matchedCondition, 0, len(locations))
+		tablePage  *page.Page
+		pageOffset int64 = -1
+	)
+
+	for _, location := range locations {
+		if location.PageOffset != pageOffset {
+			var err error
+
+			tablePage, err = page.ReadPageAt(dataDescriptor, location.PageOffset, m.pageSize)
+			if err != nil {
+				return nil, fmt.Errorf("page.ReadPageAt: %w", err)
+			}
+
+			pageOffset = location.PageOffset
+		}
+
+		if location.PointerIndex >= len(tablePage.Pointers) {
+			return nil, ErrIndexPointsToMissingRow(location.PageOffset, location.PointerIndex)
+		}
+
+		matched, err := matchRow(
+			table,
+			overflow,
+			predicate,
+			visible,
+			tablePage,
+			location.PageOffset,
+			location.PointerIndex,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("matchRow: %w", err)
+		}
+
+		if matched != nil {
+			matches = append(matches, matched)
+		}
+	}
+
+	return matches, nil
+}
+
+func compareLocations(a, b index.Location) int {
+	switch {
+	case a.PageOffset != b.PageOffset:
+		return int(a.PageOffset - b.PageOffset)
+	default:
+		return a.PointerIndex - b.PointerIndex
+	}
+}
@@ -0,0 +1,161 @@
+package table
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/artem-vildanov/small-db/internal/schema"
+)
+
+/*
+Сериализованный массив:
+
+Количество элементов (2 байта)
+Элементы по порядку, перед каждым префикс с его длиной (2 байта)
+*/
+
+// признаки в ключе индекса: перед каждым элементом и в конце массива.
+// поэтому массив меньше любого своего продолжения
+const (
+	arrayEndKeyByte     byte = 0
+	arrayElementKeyByte byte = 1
+)
+
+func elementColumn(column *schema.Column) (*schema.Column, bool) {
+	elementType, isArray := schema.ElementType(column.Type)
+	if !isArray {
+		return nil, false
+	}
+
+	return &schema.Column{Name: column.Name, Type: elementType}, true
+}
+
+func serializeArray(column *schema.Column, raw any) ([]byte, error) {
+	element, isArray := elementColumn(column)
+	if !isArray {
+		return nil, ErrUnexpectedType(column.Type)
+	}
+
+	elements, ok := schema.ArrayElements(raw)
+	if !ok || len(elements) > math.MaxUint16 {
+		return nil, ErrFailedToSerialize(column.Type)
+	}
+
+	serialized := binary.BigEndian.AppendUint16(nil, uint16(len(elements)))
+	for _, raw := range elements {
+		value, err := serializeValue(element, raw)
+		if err != nil {
+			return nil, ErrFailedToSerialize(column.Type)
+		}
+
+		if len(value) > math.MaxUint16 {
+			return nil, ErrFailedToSerialize(column.Type)
+		}
+
+		serialized = binary.BigEndian.AppendUint16(serialized, uint16(len(value)))
+		serialized = append(serialized, value...)
+	}
+
+	return serialized, nil
+}
+
+func deserializeArray(column *schema.Column, raw []byte) (any, error) {
+	switch column.Type {
+	case schema.Int32ArrayType:
+		return readArray[int32](column, raw)
+	case schema.StringArrayType:
+		return readArray[string](column, raw)
+	case schema.BoolArrayType:
+		return readArray[bool](column, raw)
+	default:
+		return nil, ErrUnexpectedType(column.Type)
+	}
+}
+
+func readArray[T any](column *schema.Column, raw []byte) ([]T, error) {
+	element, _ := elementColumn(column)
+	elementSize, _ := schema.TypeSize(element.Type)
+
+	if len(raw) < DynamicValuePrefixSize {
+		return nil, ErrCorruptedArray(column.Type)
+	}
+
+	count := int(binary.BigEndian.Uint16(raw))
+	raw = raw[DynamicValuePrefixSize:]
+
+	array := make([]T, 0, count)
+	for i := 0; i < count; i++ {
+		if len(raw) < DynamicValuePrefixSize {
+			return nil, ErrCorruptedArray(column.Type)
+		}
+
+		size := int(binary.BigEndian.Uint16(raw))
+		raw = raw[DynamicValuePrefixSize:]
+
+		validSize := elementSize == schema.DynamicMemoTypeColumnSize || size == elementSize
+		if !validSize || size > len(raw) {
+			return nil, ErrCorruptedArray(column.Type)
+		}
+
+		value, err := deserializeValue(element, raw[:size])
+		if err != nil {
+			return nil, fmt.Errorf("deserializeValue: %w", err)
+		}
+		raw = raw[size:]
+
+		array = append(array, value.(T))
+	}
+
+	if len(raw) != 0 {
+		return nil, ErrCorruptedArray(column.Type)
+	}
+
+	return array, nil
+}
+
+// ключ массива - ключи элементов по порядку, поэтому массивы
+// сравниваются поэлементно
+func appendArrayKey(dst []byte, column *schema.Column, value any) ([]byte, error) {
+	keys, err := arrayElementKeys(column, value)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		dst = append(append(dst, arrayElementKeyByte), key...)
+	}
+
+	return append(dst, arrayEndKeyByte), nil
+}
+
+func arrayElementKeys(column *schema.Column, value any) ([][]byte, error) {
+	element, isArray := elementColumn(column)
+	if !isArray {
+		return nil, ErrUnexpectedType(column.Type)
+	}
+
+	elements, ok := schema.ArrayElements(value)
+	if !ok {
+		return nil, ErrFailedToCast(column.Type)
+	}
+
+	keys := make([][]byte, 0, len(elements))
+	for _, elementValue := range elements {
+		key, err := appendColumnKey(nil, element, elementValue)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func ErrNotArrayColumn(column string, columnType schema.ColumnType) error {
+	return fmt.Errorf("column %s of type %s is not an array", column, columnType)
+}
+
+func ErrCorruptedArray(columnType schema.ColumnType) error {
+	return fmt.Errorf("failed to deserialize %s value: array is corrupted", columnType)
+}
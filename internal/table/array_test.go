@@ -0,0 +1,169 @@
+package table
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_Arrays(t *testing.T) {
+	tableName, tableManager := initTableWithColumns(t, []*schema.Column{
+		{Name: "id", Type: schema.Int32Type, Size: int(schema.Int32Size)},
+		{Name: "tags", Type: schema.StringArrayType, Size: schema.DynamicMemoTypeColumnSize},
+		{Name: "owner_ids", Type: schema.Int32ArrayType, Size: schema.DynamicMemoTypeColumnSize, Nullable: true},
+		{Name: "flags", Type: schema.BoolArrayType, Size: schema.DynamicMemoTypeColumnSize, Nullable: true},
+	}, "id")
+
+	_, err := tableManager.CreateIndex(tableName, "by_owner_ids", []string{"owner_ids"})
+	require.NoError(t, err)
+
+	rows := []map[string]any{
+		{"tags": []string{"go", "db"}, "owner_ids": []int32{1, 2}, "flags": []bool{true}},
+		{"tags": []any{"db"}, "owner_ids": []int{1}, "flags": []bool{}},
+		{"tags": []string{}, "owner_ids": []int32{1, 2, 3}},
+		{"tags": []string{"go", ""}, "owner_ids": nil, "flags": []bool{false, true}},
+	}
+	for i, row := range rows {
+		row["id"] = int32(i)
+		require.NoError(t, tableManager.Insert(tableName, row))
+	}
+
+	t.Run("массивы читаются срезами go", func(t *testing.T) {
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(1)})
+		require.NoError(t, err)
+
+		nameToValue, err := record.IntoNameToValue()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"id":        int32(1),
+			"tags":      []string{"db"},
+			"owner_ids": []int32{1},
+			"flags":     []bool{},
+		}, nameToValue)
+
+		record, err = tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(3)})
+		require.NoError(t, err)
+
+		tags, err := record.GetStringArrayFieldValue("tags")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"go", ""}, tags)
+
+		flags, err := record.GetBoolArrayFieldValue("flags")
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, true}, flags)
+
+		_, err = record.GetInt32ArrayFieldValue("owner_ids")
+		assert.EqualError(t, err, ErrFieldIsNull("owner_ids").Error())
+	})
+
+	testCases := []struct {
+		name        string
+		predicate   *Predicate
+		expectedIDs []int32
+	}{
+		{
+			name:        "содержит значение",
+			predicate:   Contains("tags", "go"),
+			expectedIDs: []int32{0, 3},
+		},
+		{
+			name:        "содержит все значения",
+			predicate:   Contains("owner_ids", 2, 1),
+			expectedIDs: []int32{0, 2},
+		},
+		{
+			name:        "содержит хотя бы одно значение",
+			predicate:   Any("owner_ids", 3, 5),
+			expectedIDs: []int32{2},
+		},
+		{
+			name:        "пустая строка - обычный элемент",
+			predicate:   Any("tags", "", "db"),
+			expectedIDs: []int32{0, 1, 3},
+		},
+		{
+			name:        "элементы bool",
+			predicate:   Contains("flags", false),
+			expectedIDs: []int32{3},
+		},
+		{
+			name:        "пустой список значений",
+			predicate:   Contains("owner_ids"),
+			expectedIDs: []int32{0, 1, 2},
+		},
+		{
+			name:        "равенство массивов",
+			predicate:   Eq("owner_ids", []int32{1, 2}),
+			expectedIDs: []int32{0},
+		},
+		{
+			name:        "массивы сравниваются поэлементно",
+			predicate:   And(Gt("owner_ids", []int32{1}), Lt("owner_ids", []int32{1, 2, 3})),
+			expectedIDs: []int32{0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotRecords, err := tableManager.FindByPredicate(tableName, tc.predicate)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expectedIDs, getRecordIDs(t, gotRecords))
+		})
+	}
+
+	t.Run("условие на элементы колонки другого типа", func(t *testing.T) {
+		_, err := tableManager.FindByPredicate(tableName, Contains("id", 1))
+		assert.ErrorContains(t, err, ErrNotArrayColumn("id", schema.Int32Type).Error())
+	})
+
+	t.Run("значение не подходит к типу элементов", func(t *testing.T) {
+		_, err := tableManager.FindByPredicate(tableName, Any("owner_ids", "1"))
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.Int32Type).Error())
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":   int32(100),
+			"tags": []any{"go", 1},
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.StringArrayType).Error())
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":   int32(100),
+			"tags": "go",
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.StringArrayType).Error())
+	})
+}
+
+func TestRecord_SerializeArray(t *testing.T) {
+	column := &schema.Column{Name: "ids", Type: schema.Int32ArrayType, Size: schema.DynamicMemoTypeColumnSize}
+
+	serialized, err := serializeValue(column, []int{7, -1})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0, 2,
+		0, 4, 0, 0, 0, 7,
+		0, 4, 0xFF, 0xFF, 0xFF, 0xFF,
+	}, serialized)
+
+	deserialized, err := deserializeValue(column, serialized)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{7, -1}, deserialized)
+
+	t.Run("поврежденный массив", func(t *testing.T) {
+		for i := 0; i < len(serialized); i++ {
+			_, err := deserializeValue(column, serialized[:i])
+			assert.EqualError(t, err, ErrCorruptedArray(schema.Int32ArrayType).Error())
+		}
+
+		_, err := deserializeValue(column, append(serialized, 0))
+		assert.EqualError(t, err, ErrCorruptedArray(schema.Int32ArrayType).Error())
+
+		// длина элемента не совпадает с размером int32
+		wrongSize := binary.BigEndian.AppendUint16([]byte{0, 1}, 2)
+		_, err = deserializeValue(column, append(wrongSize, 0, 1))
+		assert.EqualError(t, err, ErrCorruptedArray(schema.Int32ArrayType).Error())
+	})
+}
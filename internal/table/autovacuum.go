@@ -0,0 +1,232 @@
+package table
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+)
+
+const (
+	defaultAutovacuumInterval   = time.Minute
+	defaultAutovacuumDeadTuples = 50
+	defaultAutovacuumCostLimit  = 200
+	defaultAutovacuumCostDelay  = 20 * time.Millisecond
+)
+
+// настройки фонового вакуума, нулевые поля заменяются значениями по умолчанию
+type AutovacuumConfig struct {
+	// как часто проверяются таблицы
+	Interval time.Duration
+	// таблица вакуумируется, когда удаленных версий не меньше DeadTuples
+	// и их доля превышает BloatRatio
+	DeadTuples int64
+	BloatRatio float64
+	// после CostLimit обработанных страниц вакуум засыпает на CostDelay,
+	// чтобы не отнимать диск и блокировки у запросов
+	CostLimit int
+	CostDelay time.Duration
+	// вызывается из фоновой горутины, например для логирования
+	OnEvent func(AutovacuumEvent)
+}
+
+func (c AutovacuumConfig) withDefaults() AutovacuumConfig {
+	if c.Interval <= 0 {
+		c.Interval = defaultAutovacuumInterval
+	}
+	if c.DeadTuples <= 0 {
+		c.DeadTuples = defaultAutovacuumDeadTuples
+	}
+	if c.BloatRatio <= 0 {
+		c.BloatRatio = vacuumBloatTreshold
+	}
+	if c.CostLimit <= 0 {
+		c.CostLimit = defaultAutovacuumCostLimit
+	}
+	if c.CostDelay <= 0 {
+		c.CostDelay = defaultAutovacuumCostDelay
+	}
+	if c.OnEvent == nil {
+		c.OnEvent = func(AutovacuumEvent) {}
+	}
+
+	return c
+}
+
+type AutovacuumEventKind string
+
+const (
+	AutovacuumStarted  AutovacuumEventKind = "started"
+	AutovacuumFinished AutovacuumEventKind = "finished"
+	AutovacuumFailed   AutovacuumEventKind = "failed"
+)
+
+type AutovacuumEvent struct {
+	Kind      AutovacuumEventKind
+	TableName string
+	// статистика таблицы на момент решения о вакууме
+	Stats TableStats
+	// обработанные страницы и время, у started не заполняются
+	Pages    int
+	Duration time.Duration
+	Err      error
+}
+
+type autovacuum struct {
+	config AutovacuumConfig
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+/*
+Запускает фоновый вакуум.
+
+Раз в Interval горутина проверяет статистику всех таблиц
+и запускает ConcurrentVacuum для тех, что превысили пороги.
+Таблицы обрабатываются по одной, и ошибка в одной не мешает
+остальным: она передается в OnEvent, а таблица будет проверена
+снова на следующем круге.
+*/
+func (m *TableManager) StartAutovacuum(config AutovacuumConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.autovacuum != nil {
+		return ErrAutovacuumAlreadyRunning()
+	}
+
+	worker := &autovacuum{
+		config: config.withDefaults(),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	m.autovacuum = worker
+
+	go m.runAutovacuum(worker)
+
+	return nil
+}
+
+// останавливает фоновый вакуум и ждет, пока он завершится.
+// прерванный вакуум оставляет таблицу целой: каждая страница
+// обрабатывается в своей транзакции
+func (m *TableManager) StopAutovacuum() {
+	m.mu.Lock()
+	worker := m.autovacuum
+	m.autovacuum = nil
+	m.mu.Unlock()
+
+	if worker == nil {
+		return
+	}
+
+	close(worker.stop)
+	<-worker.done
+}
+
+func (m *TableManager) runAutovacuum(worker *autovacuum) {
+	defer close(worker.done)
+
+	ticker := time.NewTicker(worker.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-worker.stop:
+			return
+		case <-ticker.C:
+		}
+
+		for _, tableName := range m.tableNames() {
+			if !m.autovacuumTable(worker, tableName) {
+				return
+			}
+		}
+	}
+}
+
+// возвращает false, если вакуум остановлен
+func (m *TableManager) autovacuumTable(worker *autovacuum, tableName string) bool {
+	_, stats, err := m.ShouldVacuum(tableName)
+	if err != nil {
+		worker.config.OnEvent(AutovacuumEvent{
+			Kind:      AutovacuumFailed,
+			TableName: tableName,
+			Err:       err,
+		})
+		return true
+	}
+
+	if stats.DeadTuples < worker.config.DeadTuples ||
+		stats.BloatRatio() <= worker.config.BloatRatio {
+		return true
+	}
+
+	worker.config.OnEvent(AutovacuumEvent{
+		Kind:      AutovacuumStarted,
+		TableName: tableName,
+		Stats:     stats,
+	})
+
+	var (
+		startedAt = time.Now()
+		pages     = 0
+	)
+	for pageOffset := page.FirstDataPageOffset(m.pageSize); ; pageOffset += int64(m.pageSize) {
+		if pages > 0 && pages%worker.config.CostLimit == 0 {
+			select {
+			case <-worker.stop:
+				return false
+			case <-time.After(worker.config.CostDelay):
+			}
+		}
+
+		select {
+		case <-worker.stop:
+			return false
+		default:
+		}
+
+		processed, err := m.vacuumPage(tableName, pageOffset)
+		if err != nil {
+			worker.config.OnEvent(AutovacuumEvent{
+				Kind:      AutovacuumFailed,
+				TableName: tableName,
+				Stats:     stats,
+				Pages:     pages,
+				Duration:  time.Since(startedAt),
+				Err:       fmt.Errorf("TableManager.vacuumPage: %w", err),
+			})
+			return true
+		}
+
+		if !processed {
+			break
+		}
+		pages++
+	}
+
+	worker.config.OnEvent(AutovacuumEvent{
+		Kind:      AutovacuumFinished,
+		TableName: tableName,
+		Stats:     stats,
+		Pages:     pages,
+		Duration:  time.Since(startedAt),
+	})
+
+	return true
+}
+
+func (m *TableManager) tableNames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.NameToTable))
+	for tableName := range m.NameToTable {
+		names = append(names, tableName)
+	}
+	slices.Sort(names)
+
+	return names
+}
@@ -0,0 +1,92 @@
+package table
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_Autovacuum(t *testing.T) {
+	const recordsNum = 10
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+	defer tableManager.StopAutovacuum()
+
+	events := make(chan AutovacuumEvent, 16)
+	config := AutovacuumConfig{
+		Interval:   10 * time.Millisecond,
+		DeadTuples: 3,
+		CostLimit:  1,
+		CostDelay:  time.Millisecond,
+		OnEvent: func(event AutovacuumEvent) {
+			events <- event
+		},
+	}
+
+	waitEvent := func(t *testing.T) AutovacuumEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "autovacuum event timeout")
+			return AutovacuumEvent{}
+		}
+	}
+
+	t.Run("таблица ниже порогов не вакуумируется", func(t *testing.T) {
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Lt("id", 2)))
+		require.NoError(t, tableManager.StartAutovacuum(config))
+
+		time.Sleep(5 * config.Interval)
+		tableManager.StopAutovacuum()
+
+		assert.Equal(t, 0, len(events))
+		assert.Equal(t, recordsNum, countTuples(t, tableManager, tableName))
+	})
+
+	t.Run("вакуум после превышения порогов", func(t *testing.T) {
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Lt("id", 6)))
+		require.NoError(t, tableManager.StartAutovacuum(config))
+
+		started := waitEvent(t)
+		assert.Equal(t, AutovacuumStarted, started.Kind)
+		assert.Equal(t, tableName, started.TableName)
+		assert.Equal(t, TableStats{LiveTuples: 4, DeadTuples: 6}, TableStats{
+			LiveTuples: started.Stats.LiveTuples,
+			DeadTuples: started.Stats.DeadTuples,
+		})
+
+		finished := waitEvent(t)
+		assert.Equal(t, AutovacuumFinished, finished.Kind)
+		assert.Equal(t, tableName, finished.TableName)
+		assert.NoError(t, finished.Err)
+		assert.Equal(t, tableManager.NameToTable[tableName].NumPages, finished.Pages)
+
+		assert.Equal(t, recordsNum-6, countTuples(t, tableManager, tableName))
+
+		_, stats, err := tableManager.ShouldVacuum(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, TableStats{LiveTuples: recordsNum - 6}, stats)
+
+		records, err := tableManager.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{6, 7, 8, 9}, getRecordIDs(t, records))
+	})
+
+	t.Run("повторный запуск", func(t *testing.T) {
+		assert.EqualError(
+			t,
+			tableManager.StartAutovacuum(config),
+			ErrAutovacuumAlreadyRunning().Error(),
+		)
+
+		tableManager.StopAutovacuum()
+		tableManager.StopAutovacuum()
+
+		require.NoError(t, tableManager.StartAutovacuum(config))
+		tableManager.StopAutovacuum()
+	})
+}
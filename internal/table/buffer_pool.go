@@ -0,0 +1,280 @@
+package table
+
+import (
+	"cmp"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+)
+
+// сколько страниц держит пул по умолчанию (8 MB при страницах по 8 KB)
+const defaultBufferPoolSize = 1024
+
+/*
+Пул страниц файлов данных и индексов, общий для всех операций.
+
+Страница читается с диска один раз и дальше берется из памяти.
+Когда пул заполнен, вытесняется давно не использованная страница.
+
+Коммит кладет измененные страницы в пул и помечает грязными: на диске
+они окажутся при вытеснении или при сбросе пула. До этого изменения
+защищены журналом предзаписи, поэтому журнал очищается только после
+сброса пула.
+*/
+type bufferPool struct {
+	mu       sync.Mutex
+	capacity int
+	// размер страниц всех файлов базы
+	pageSize int
+	frames   map[pageKey]*list.Element
+	// в начале недавно использованные страницы
+	lru   *list.List
+	files map[string]*pooledFile
+}
+
+type pageKey struct {
+	path       string
+	pageOffset int64
+}
+
+type frame struct {
+	key   pageKey
+	image []byte
+	// страница изменена и еще не записана в файл
+	dirty bool
+}
+
+type pooledFile struct {
+	descriptor *os.File
+	// размер файла с учетом грязных страниц
+	size int64
+	// в файл писали после последнего fsync
+	unsynced bool
+}
+
+func newBufferPool(capacity int, pageSize int) *bufferPool {
+	return &bufferPool{
+		capacity: capacity,
+		pageSize: pageSize,
+		frames:   make(map[pageKey]*list.Element, capacity),
+		lru:      list.New(),
+		files:    make(map[string]*pooledFile),
+	}
+}
+
+// информация о файле с размером, учитывающим грязные страницы
+func (p *bufferPool) stat(path string) (os.FileInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.file(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := file.descriptor.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("File.Stat: %w", err)
+	}
+
+	return &pagedFileInfo{FileInfo: fileInfo, size: file.size}, nil
+}
+
+// вызывает read с образом страницы. образ нельзя изменять
+// и сохранять: после возврата из read его может занять другая страница
+func (p *bufferPool) read(path string, pageOffset int64, read func(image []byte)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := pageKey{path: path, pageOffset: pageOffset}
+	if element, exists := p.frames[key]; exists {
+		p.lru.MoveToFront(element)
+		read(element.Value.(*frame).image)
+		return nil
+	}
+
+	file, err := p.file(path)
+	if err != nil {
+		return err
+	}
+
+	pageFrame, err := p.allocate(key)
+	if err != nil {
+		return err
+	}
+
+	clear(pageFrame.image)
+	if _, err := file.descriptor.ReadAt(pageFrame.image, pageOffset); err != nil && !errors.Is(err, io.EOF) {
+		p.remove(key)
+		return fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	read(pageFrame.image)
+
+	return nil
+}
+
+// кладет в пул измененную страницу
+func (p *bufferPool) write(path string, pageOffset int64, image []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.file(path)
+	if err != nil {
+		return err
+	}
+
+	key := pageKey{path: path, pageOffset: pageOffset}
+
+	var pageFrame *frame
+	if element, exists := p.frames[key]; exists {
+		p.lru.MoveToFront(element)
+		pageFrame = element.Value.(*frame)
+	} else if pageFrame, err = p.allocate(key); err != nil {
+		return err
+	}
+
+	copy(pageFrame.image, image)
+	pageFrame.dirty = true
+
+	file.size = max(file.size, pageOffset+int64(p.pageSize))
+
+	return nil
+}
+
+// записывает грязные страницы в файлы и сбрасывает файлы на диск
+func (p *bufferPool) flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dirty := make([]*frame, 0)
+	for _, element := range p.frames {
+		if pageFrame := element.Value.(*frame); pageFrame.dirty {
+			dirty = append(dirty, pageFrame)
+		}
+	}
+
+	// пишем файлы последовательно
+	slices.SortFunc(dirty, func(a, b *frame) int {
+		return cmp.Or(
+			cmp.Compare(a.key.path, b.key.path),
+			cmp.Compare(a.key.pageOffset, b.key.pageOffset),
+		)
+	})
+
+	for _, pageFrame := range dirty {
+		if err := p.writeBack(pageFrame); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range p.files {
+		if !file.unsynced {
+			continue
+		}
+
+		if err := file.descriptor.Sync(); err != nil {
+			return fmt.Errorf("File.Sync: %w", err)
+		}
+		file.unsynced = false
+	}
+
+	return nil
+}
+
+// забывает страницы файла, например после того как вакуум подменил
+// файл. грязные страницы теряются, поэтому пул нужно сбросить заранее
+func (p *bufferPool) invalidate(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key := range p.frames {
+		if key.path == path {
+			p.remove(key)
+		}
+	}
+
+	if file, exists := p.files[path]; exists {
+		file.descriptor.Close()
+		delete(p.files, path)
+	}
+}
+
+func (p *bufferPool) file(path string) (*pooledFile, error) {
+	if file, exists := p.files[path]; exists {
+		return file, nil
+	}
+
+	descriptor, err := os.OpenFile(path, os.O_RDWR, consts.PosixAccessRight)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile: %w", err)
+	}
+
+	fileInfo, err := descriptor.Stat()
+	if err != nil {
+		descriptor.Close()
+		return nil, fmt.Errorf("File.Stat: %w", err)
+	}
+
+	file := &pooledFile{
+		descriptor: descriptor,
+		size:       fileInfo.Size(),
+	}
+	p.files[path] = file
+
+	return file, nil
+}
+
+// занимает место под страницу, при необходимости вытесняя
+// давно не использованную. образ вытесненной страницы переиспользуется
+func (p *bufferPool) allocate(key pageKey) (*frame, error) {
+	var image []byte
+
+	if p.lru.Len() >= p.capacity {
+		victim := p.lru.Back().Value.(*frame)
+		if victim.dirty {
+			if err := p.writeBack(victim); err != nil {
+				return nil, err
+			}
+		}
+
+		image = victim.image
+		p.remove(victim.key)
+	} else {
+		image = make([]byte, p.pageSize)
+	}
+
+	pageFrame := &frame{key: key, image: image}
+	p.frames[key] = p.lru.PushFront(pageFrame)
+
+	return pageFrame, nil
+}
+
+func (p *bufferPool) writeBack(pageFrame *frame) error {
+	file, err := p.file(pageFrame.key.path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.descriptor.WriteAt(pageFrame.image, pageFrame.key.pageOffset); err != nil {
+		return fmt.Errorf("File.WriteAt: %w", err)
+	}
+
+	pageFrame.dirty = false
+	file.unsynced = true
+
+	return nil
+}
+
+func (p *bufferPool) remove(key pageKey) {
+	if element, exists := p.frames[key]; exists {
+		p.lru.Remove(element)
+		delete(p.frames, key)
+	}
+}
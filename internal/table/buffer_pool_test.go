@@ -0,0 +1,120 @@
+package table
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool(t *testing.T) {
+	descriptor, err := os.CreateTemp("./", "test_pool")
+	require.NoError(t, err)
+	defer os.Remove(descriptor.Name())
+	defer descriptor.Close()
+
+	path := descriptor.Name()
+	pageImage := func(fill byte) []byte {
+		return bytes.Repeat([]byte{fill}, page.DefaultPageSize)
+	}
+	readPage := func(t *testing.T, pool *bufferPool, pageOffset int64) []byte {
+		image := make([]byte, page.DefaultPageSize)
+		require.NoError(t, pool.read(path, pageOffset, func(pooled []byte) {
+			copy(image, pooled)
+		}))
+		return image
+	}
+	readFromDisk := func(t *testing.T, pageOffset int64) []byte {
+		image := make([]byte, page.DefaultPageSize)
+		_, err := descriptor.ReadAt(image, pageOffset)
+		require.NoError(t, err)
+		return image
+	}
+
+	t.Run("вытеснение грязных страниц", func(t *testing.T) {
+		pool := newBufferPool(2, page.DefaultPageSize)
+
+		for i := 0; i < 4; i++ {
+			require.NoError(t, pool.write(path, int64(i)*page.DefaultPageSize, pageImage(byte(i+1))))
+		}
+		assert.Equal(t, 2, pool.lru.Len())
+
+		fileInfo, err := pool.stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4*page.DefaultPageSize), fileInfo.Size())
+
+		// вытесненные страницы записаны в файл
+		assert.Equal(t, pageImage(1), readFromDisk(t, 0))
+		assert.Equal(t, pageImage(2), readFromDisk(t, page.DefaultPageSize))
+
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, pageImage(byte(i+1)), readPage(t, pool, int64(i)*page.DefaultPageSize))
+		}
+	})
+
+	t.Run("сброс пула", func(t *testing.T) {
+		pool := newBufferPool(8, page.DefaultPageSize)
+
+		require.NoError(t, pool.write(path, page.DefaultPageSize, pageImage(9)))
+		assert.Equal(t, pageImage(2), readFromDisk(t, page.DefaultPageSize))
+
+		require.NoError(t, pool.flush())
+		assert.Equal(t, pageImage(9), readFromDisk(t, page.DefaultPageSize))
+
+		pool.invalidate(path)
+		assert.Equal(t, 0, pool.lru.Len())
+		assert.Equal(t, pageImage(9), readPage(t, pool, page.DefaultPageSize))
+	})
+}
+
+func TestTableManager_BufferPool(t *testing.T) {
+	const recordsNum = 300
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, 0, "id")
+	defer clear()
+
+	// пул меньше таблицы: страницы вытесняются
+	tableManager.pool = newBufferPool(2, page.DefaultPageSize)
+
+	expectedIDs := make([]int32, 0, recordsNum)
+	for i := 0; i < recordsNum; i++ {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(i),
+			"name":   strings.Repeat("x", 100),
+			"group":  int32(i % 10),
+			"active": true,
+		}))
+		expectedIDs = append(expectedIDs, int32(i))
+	}
+
+	records, err := tableManager.GetAllRecords(tableName)
+	require.NoError(t, err)
+	assert.Equal(t, expectedIDs, getRecordIDs(t, records))
+	assert.Greater(t, tableManager.NameToTable[tableName].NumPages, 2)
+
+	// до сброса изменения защищены журналом
+	walRecords, err := tableManager.wal.ReadAll()
+	require.NoError(t, err)
+	assert.NotEqual(t, 0, len(walRecords))
+
+	require.NoError(t, tableManager.Flush())
+
+	walRecords, err = tableManager.wal.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(walRecords))
+
+	table := tableManager.NameToTable[tableName]
+	reloaded, err := InitTableManager("./", &schema.SchemaManager{
+		IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+	})
+	require.NoError(t, err)
+
+	records, err = reloaded.GetAllRecords(tableName)
+	require.NoError(t, err)
+	assert.Equal(t, expectedIDs, getRecordIDs(t, records))
+}
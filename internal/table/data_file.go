@@ -0,0 +1,675 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/artem-vildanov/small-db/internal/schema"
+)
+
+// шаги обновления файла данных до следующей версии формата.
+// шаг должен выдерживать повтор: версия в метаданных меняется
+// только после всех шагов, и после сбоя обновление начнется заново
+var dataFileUpgrades = map[uint16]func(m *TableManager, table *Table, schemaID string) error{
+	page.RawRecordsFormatVersion: (*TableManager).addTupleHeaders,
+	page.LegacyFormatVersion:     (*TableManager).addDataFileHeader,
+	page.FileHeaderFormatVersion: (*TableManager).addNullBitmaps,
+	page.NullBitmapFormatVersion: (*TableManager).addOverflowChecksums,
+}
+
+// обновляет файл данных до текущей версии формата и проверяет,
+// что он записан по схеме таблицы
+func (m *TableManager) openDataFile(table *Table, schemaID string) error {
+	if table.FormatVersion > page.CurrentFormatVersion {
+		return page.ErrUnsupportedFormatVersion(table.FormatVersion)
+	}
+
+	if table.FormatVersion < page.CurrentFormatVersion {
+		if table.Schema == nil {
+			return ErrTableSchemaNotFound(table.Name, schemaID)
+		}
+
+		for version := table.FormatVersion; version < page.CurrentFormatVersion; version++ {
+			upgrade, exists := dataFileUpgrades[version]
+			if !exists {
+				return page.ErrUnsupportedFormatVersion(version)
+			}
+
+			if err := upgrade(m, table, schemaID); err != nil {
+				return fmt.Errorf("upgrade from version %d: %w", version, err)
+			}
+		}
+
+		// строки переехали, поэтому карта свободного места
+		// и индексы строятся заново
+		if err := m.rebuildDataFileState(table); err != nil {
+			return fmt.Errorf("TableManager.rebuildDataFileState: %w", err)
+		}
+
+		table.FormatVersion = page.CurrentFormatVersion
+		if err := m.atomicUpdateMetadata(table); err != nil {
+			return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
+		}
+	}
+
+	descriptor, err := m.openFile(table.Path)
+	if err != nil {
+		return fmt.Errorf("TableManager.openFile: %w", err)
+	}
+	defer descriptor.Close()
+
+	header, err := page.ReadFileHeader(descriptor)
+	if err != nil {
+		page.SetCorruptedPageTable(err, table.Name)
+		return fmt.Errorf("page.ReadFileHeader: %w", err)
+	}
+
+	if header.Version != page.CurrentFormatVersion {
+		return page.ErrUnsupportedFormatVersion(header.Version)
+	}
+
+	if int(header.PageSize) != m.pageSize {
+		return ErrTablePageSizeMismatch(table.Name, int(header.PageSize), m.pageSize)
+	}
+
+	if header.SchemaID != schemaID {
+		return ErrDataFileSchemaMismatch(table.Name, header.SchemaID)
+	}
+
+	// полный вакуум прервался рядом с подменой файла данных:
+	// карта свободного места и индексы могут указывать на строки
+	// другого файла, а число страниц и статистика - не совпадать с ним
+	if table.RebuildPending {
+		if err := m.rebuildDataFileState(table); err != nil {
+			return fmt.Errorf("TableManager.rebuildDataFileState: %w", err)
+		}
+
+		table.RebuildPending = false
+		if err := m.atomicUpdateMetadata(table); err != nil {
+			return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// версия 0 -> 1: перед каждой записью появляется заголовок версии строки.
+// записи получают номер frozenTxID и видны всем транзакциям, а удаленные
+// записи становятся удаленными версиями, которые уберет вакуум
+func (m *TableManager) addTupleHeaders(table *Table, schemaID string) error {
+	// номера транзакций начинаются после номера записей из файла
+	table.TxIDHorizon = max(table.TxIDHorizon, frozenTxID)
+	m.lastTxID = max(m.lastTxID, table.TxIDHorizon)
+
+	descriptor, err := m.openFile(table.Path)
+	if err != nil {
+		return fmt.Errorf("TableManager.openFile: %w", err)
+	}
+	defer descriptor.Close()
+
+	fileInfo, err := descriptor.Stat()
+	if err != nil {
+		return fmt.Errorf("File.Stat: %w", err)
+	}
+
+	pagesNum := int(fileInfo.Size() / int64(m.pageSize))
+	if pagesNum == 0 {
+		return nil
+	}
+
+	firstPage := make([]byte, m.pageSize)
+	if _, err := descriptor.ReadAt(firstPage, 0); err != nil {
+		return fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	// файл мог быть переписан до сбоя, который не дал обновить метаданные:
+	// тогда в начале файла его заголовок или страница с контрольной суммой,
+	// а записи без заголовков версий хранились на страницах без сумм
+	if string(firstPage[:len(page.FileHeaderMagic)]) == page.FileHeaderMagic {
+		return nil
+	}
+	if _, err := page.DeserializePageVersion(firstPage, page.FileHeaderFormatVersion); err == nil {
+		return nil
+	}
+
+	tmpDescriptor, err := os.CreateTemp(m.tableDirPath, table.Name+".data.tmp")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer tmpDescriptor.Close()
+
+	var (
+		bufferPage       = page.NewEmptyPage(m.pageSize)
+		bufferPageOffset = int64(0)
+	)
+
+	flush := func() error {
+		if _, err := tmpDescriptor.WriteAt(bufferPage.Serialize(), bufferPageOffset); err != nil {
+			return fmt.Errorf("File.WriteAt: %w", err)
+		}
+
+		bufferPage = page.NewEmptyPage(m.pageSize)
+		bufferPageOffset += int64(m.pageSize)
+
+		return nil
+	}
+
+	for i := 0; i < pagesNum; i++ {
+		pageOffset := int64(i * m.pageSize)
+
+		oldPage, err := page.ReadPageAtVersion(descriptor, pageOffset, m.pageSize, page.RawRecordsFormatVersion)
+		if err != nil {
+			return fmt.Errorf("page.ReadPageAtVersion: %w", err)
+		}
+
+		for _, ptr := range oldPage.Pointers {
+			tuple := newTuple(frozenTxID, oldPage.GetDataByPointer(ptr))
+
+			// удаленные записи оставались на странице со своим указателем
+			if ptr.Status == page.StatusDeleted {
+				setTupleXmax(tuple, frozenTxID)
+			}
+
+			if len(tuple) > maxTupleSize(m.pageSize) {
+				return ErrRecordTooLarge(table.Name, len(tuple))
+			}
+
+			if _, err := bufferPage.Insert(tuple); err == nil {
+				continue
+			}
+
+			if err := flush(); err != nil {
+				return err
+			}
+
+			if _, err := bufferPage.Insert(tuple); err != nil {
+				return fmt.Errorf("Page.Insert: %w", err)
+			}
+		}
+	}
+
+	if len(bufferPage.Pointers) != 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := tmpDescriptor.Sync(); err != nil {
+		return fmt.Errorf("File.Sync: %w", err)
+	}
+
+	if err := os.Rename(tmpDescriptor.Name(), table.Path); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	return nil
+}
+
+// версия 1 -> 2: перед страницами появляется заголовок файла
+func (m *TableManager) addDataFileHeader(table *Table, schemaID string) error {
+	descriptor, err := m.openFile(table.Path)
+	if err != nil {
+		return fmt.Errorf("TableManager.openFile: %w", err)
+	}
+	defer descriptor.Close()
+
+	magic := make([]byte, len(page.FileHeaderMagic))
+	if _, err := descriptor.ReadAt(magic, 0); err != nil && err != io.EOF {
+		return fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	// файл мог быть переписан до сбоя, который не дал обновить метаданные
+	if string(magic) == page.FileHeaderMagic {
+		return nil
+	}
+
+	if err := m.prependFileHeader(table, descriptor, schemaID); err != nil {
+		return fmt.Errorf("TableManager.prependFileHeader: %w", err)
+	}
+
+	return nil
+}
+
+// версия 2 -> 3: в начале каждой записи появляется битовая карта
+// null-значений. до ее появления колонки не могли быть null,
+// поэтому карта пустая
+func (m *TableManager) addNullBitmaps(table *Table, schemaID string) error {
+	descriptor, err := m.openFile(table.Path)
+	if err != nil {
+		return fmt.Errorf("TableManager.openFile: %w", err)
+	}
+	defer descriptor.Close()
+
+	header, err := page.ReadFileHeader(descriptor)
+	if err != nil {
+		return fmt.Errorf("page.ReadFileHeader: %w", err)
+	}
+
+	// файл мог быть переписан до сбоя, который не дал обновить метаданные
+	if header.Version >= page.NullBitmapFormatVersion {
+		return nil
+	}
+
+	fileInfo, err := descriptor.Stat()
+	if err != nil {
+		return fmt.Errorf("File.Stat: %w", err)
+	}
+
+	fileHeader := page.NewFileHeader(schemaID, m.pageSize)
+	fileHeader.Version = page.NullBitmapFormatVersion
+
+	serializedHeader, err := fileHeader.Serialize()
+	if err != nil {
+		return fmt.Errorf("FileHeader.Serialize: %w", err)
+	}
+
+	tmpDescriptor, err := os.CreateTemp(m.tableDirPath, table.Name+".data.tmp")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer tmpDescriptor.Close()
+
+	if _, err := tmpDescriptor.WriteAt(serializedHeader, 0); err != nil {
+		return fmt.Errorf("File.WriteAt: %w", err)
+	}
+
+	var (
+		emptyBitmap      = make([]byte, nullBitmapSize(len(table.Schema.Columns)))
+		bufferPage       = page.NewEmptyPage(m.pageSize)
+		bufferPageOffset = page.FirstDataPageOffset(m.pageSize)
+		pagesNum         = page.DataPagesNum(fileInfo.Size(), m.pageSize)
+	)
+
+	flush := func() error {
+		if _, err := tmpDescriptor.WriteAt(bufferPage.Serialize(), bufferPageOffset); err != nil {
+			return fmt.Errorf("File.WriteAt: %w", err)
+		}
+
+		bufferPage = page.NewEmptyPage(m.pageSize)
+		bufferPageOffset += int64(m.pageSize)
+
+		return nil
+	}
+
+	for i := 0; i < pagesNum; i++ {
+		pageOffset := page.FirstDataPageOffset(m.pageSize) + int64(i*m.pageSize)
+
+		oldPage, err := page.ReadPageAt(descriptor, pageOffset, m.pageSize)
+		if err != nil {
+			return fmt.Errorf("page.ReadPageAt: %w", err)
+		}
+
+		for _, ptr := range oldPage.Pointers {
+			if ptr.Status == page.StatusDeleted {
+				continue
+			}
+
+			data := oldPage.GetDataByPointer(ptr)
+
+			tuple := make([]byte, 0, len(data)+len(emptyBitmap))
+			tuple = append(tuple, data[:tupleHeaderSize]...)
+			tuple = append(tuple, emptyBitmap...)
+			tuple = append(tuple, data[tupleHeaderSize:]...)
+
+			if len(tuple) > maxTupleSize(m.pageSize) {
+				return ErrRecordTooLarge(table.Name, len(tuple))
+			}
+
+			if _, err := bufferPage.Insert(tuple); err == nil {
+				continue
+			}
+
+			if err := flush(); err != nil {
+				return err
+			}
+
+			if _, err := bufferPage.Insert(tuple); err != nil {
+				return fmt.Errorf("Page.Insert: %w", err)
+			}
+		}
+	}
+
+	if len(bufferPage.Pointers) != 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := tmpDescriptor.Sync(); err != nil {
+		return fmt.Errorf("File.Sync: %w", err)
+	}
+
+	if err := os.Rename(tmpDescriptor.Name(), table.Path); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	return nil
+}
+
+// версия 3 -> 4: у страниц переполнения появляется контрольная сумма,
+// и часть значения на странице становится короче. значения переписываются
+// в новый файл переполнения, а указатели на них в записях заменяются.
+// карта свободного места получает суммы, когда строится заново после обновления
+func (m *TableManager) addOverflowChecksums(table *Table, schemaID string) error {
+	var (
+		overflowPath = m.getOverflowFilePath(table.Name)
+		// старый файл переполнения хранится, пока не подменен
+		// файл данных: после сбоя значения читаются из него
+		legacyOverflowPath = overflowPath + ".legacy"
+	)
+
+	descriptor, err := m.openFile(table.Path)
+	if err != nil {
+		return fmt.Errorf("TableManager.openFile: %w", err)
+	}
+	defer descriptor.Close()
+
+	header, err := page.ReadFileHeader(descriptor)
+	if err != nil {
+		return fmt.Errorf("page.ReadFileHeader: %w", err)
+	}
+
+	// файл мог быть переписан до сбоя, который не дал обновить метаданные
+	if header.Version >= page.OverflowChecksumFormatVersion {
+		if err := os.Remove(legacyOverflowPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("os.Remove: %w", err)
+		}
+		return nil
+	}
+
+	m.pool.invalidate(overflowPath)
+	if _, err := os.Stat(legacyOverflowPath); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(overflowPath, legacyOverflowPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("os.Rename: %w", err)
+		}
+	}
+
+	// у таблицы, которая не выносила значения, файла переполнения нет
+	var (
+		legacyOverflow io.ReaderAt
+		overflow       *overflowFile
+		tmpOverflow    *os.File
+	)
+	legacyDescriptor, err := os.Open(legacyOverflowPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	if err == nil {
+		defer legacyDescriptor.Close()
+		legacyOverflow = legacyDescriptor
+
+		tmpOverflow, err = os.CreateTemp(m.tableDirPath, table.Name+".toast.tmp")
+		if err != nil {
+			return fmt.Errorf("os.CreateTemp: %w", err)
+		}
+		defer tmpOverflow.Close()
+		defer m.pool.invalidate(tmpOverflow.Name())
+
+		// значения пишутся через pager в память и попадают в файл
+		// целыми страницами после того, как переписаны все записи
+		overflowPages, err := newPager(m.pool).file(tmpOverflow.Name())
+		if err != nil {
+			return fmt.Errorf("pager.file: %w", err)
+		}
+		overflow = &overflowFile{file: overflowPages, pageSize: m.pageSize}
+	}
+
+	fileInfo, err := descriptor.Stat()
+	if err != nil {
+		return fmt.Errorf("File.Stat: %w", err)
+	}
+
+	fileHeader := page.NewFileHeader(schemaID, m.pageSize)
+	fileHeader.Version = page.OverflowChecksumFormatVersion
+
+	serializedHeader, err := fileHeader.Serialize()
+	if err != nil {
+		return fmt.Errorf("FileHeader.Serialize: %w", err)
+	}
+
+	tmpDescriptor, err := os.CreateTemp(m.tableDirPath, table.Name+".data.tmp")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer tmpDescriptor.Close()
+
+	if _, err := tmpDescriptor.WriteAt(serializedHeader, 0); err != nil {
+		return fmt.Errorf("File.WriteAt: %w", err)
+	}
+
+	var (
+		bufferPage       = page.NewEmptyPage(m.pageSize)
+		bufferPageOffset = page.FirstDataPageOffset(m.pageSize)
+		pagesNum         = page.DataPagesNum(fileInfo.Size(), m.pageSize)
+	)
+
+	flush := func() error {
+		if _, err := tmpDescriptor.WriteAt(bufferPage.Serialize(), bufferPageOffset); err != nil {
+			return fmt.Errorf("File.WriteAt: %w", err)
+		}
+
+		bufferPage = page.NewEmptyPage(m.pageSize)
+		bufferPageOffset += int64(m.pageSize)
+
+		return nil
+	}
+
+	for i := 0; i < pagesNum; i++ {
+		pageOffset := page.FirstDataPageOffset(m.pageSize) + int64(i*m.pageSize)
+
+		oldPage, err := page.ReadPageAt(descriptor, pageOffset, m.pageSize)
+		if err != nil {
+			return fmt.Errorf("page.ReadPageAt: %w", err)
+		}
+
+		for _, ptr := range oldPage.Pointers {
+			if ptr.Status == page.StatusDeleted {
+				continue
+			}
+
+			// указатель на значение одной длины в обоих форматах,
+			// поэтому заменяется на месте
+			tuple := slices.Clone(oldPage.GetDataByPointer(ptr))
+			if err := splitRecord(table.Schema, tuple[tupleHeaderSize:], func(_ *schema.Column, value []byte, external bool) error {
+				if !external {
+					return nil
+				}
+
+				assembled, err := readLegacyOverflowValue(legacyOverflow, value)
+				if err != nil {
+					return fmt.Errorf("readLegacyOverflowValue: %w", err)
+				}
+
+				pointer, err := overflow.write(assembled)
+				if err != nil {
+					return fmt.Errorf("overflowFile.write: %w", err)
+				}
+
+				copy(value, pointer)
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			if _, err := bufferPage.Insert(tuple); err == nil {
+				continue
+			}
+
+			if err := flush(); err != nil {
+				return err
+			}
+
+			if _, err := bufferPage.Insert(tuple); err != nil {
+				return fmt.Errorf("Page.Insert: %w", err)
+			}
+		}
+	}
+
+	if len(bufferPage.Pointers) != 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := tmpDescriptor.Sync(); err != nil {
+		return fmt.Errorf("File.Sync: %w", err)
+	}
+
+	// файл переполнения подменяется раньше файла данных: пока в файле
+	// данных старые указатели, повтор читает значения из старого файла
+	if overflow != nil {
+		for _, pageOffset := range overflow.file.dirtyOffsets() {
+			if _, err := tmpOverflow.WriteAt(overflow.file.dirty[pageOffset], pageOffset); err != nil {
+				return fmt.Errorf("File.WriteAt: %w", err)
+			}
+		}
+
+		if err := tmpOverflow.Sync(); err != nil {
+			return fmt.Errorf("File.Sync: %w", err)
+		}
+
+		if err := os.Rename(tmpOverflow.Name(), overflowPath); err != nil {
+			return fmt.Errorf("os.Rename: %w", err)
+		}
+
+		if err := m.syncTableDir(); err != nil {
+			return fmt.Errorf("TableManager.syncTableDir: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmpDescriptor.Name(), table.Path); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	if err := m.syncTableDir(); err != nil {
+		return fmt.Errorf("TableManager.syncTableDir: %w", err)
+	}
+
+	if err := os.Remove(legacyOverflowPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+
+	return nil
+}
+
+// после обновления файла данных пересчитывает число его страниц,
+// карту свободного места и индексы
+func (m *TableManager) rebuildDataFileState(table *Table) error {
+	m.pool.invalidate(table.Path)
+
+	fileInfo, err := os.Stat(table.Path)
+	if err != nil {
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+	table.NumPages = page.DataPagesNum(fileInfo.Size(), m.pageSize)
+
+	if err := m.buildFreeSpaceMap(table); err != nil {
+		return fmt.Errorf("TableManager.buildFreeSpaceMap: %w", err)
+	}
+
+	if err := m.rebuildIndexes(table); err != nil {
+		return fmt.Errorf("TableManager.rebuildIndexes: %w", err)
+	}
+
+	if err := m.countTableStats(table); err != nil {
+		return fmt.Errorf("TableManager.countTableStats: %w", err)
+	}
+
+	return nil
+}
+
+// пересчитывает по файлу данных живые и удаленные версии строк
+func (m *TableManager) countTableStats(table *Table) error {
+	dataFile, err := newPager(m.pool).file(table.Path)
+	if err != nil {
+		return fmt.Errorf("pager.file: %w", err)
+	}
+
+	iter, err := page.NewPagesIter(dataFile)
+	if err != nil {
+		return fmt.Errorf("NewPagesIter: %w", err)
+	}
+
+	stats := TableStats{}
+	for iter.Next() {
+		tablePage, err := iter.GetPage()
+		if err != nil {
+			return fmt.Errorf("pagesIterator.GetPage: %w", err)
+		}
+
+		for _, pointer := range tablePage.Pointers {
+			if pointer.Status != page.StatusActive {
+				continue
+			}
+
+			if isLive(readTupleHeader(tablePage.GetDataByPointer(pointer))) {
+				stats.LiveTuples++
+				continue
+			}
+
+			stats.DeadTuples++
+			stats.DeadBytes += int64(pointer.Size) + page.ItemPointerSize
+		}
+	}
+
+	table.Stats = stats
+
+	return nil
+}
+
+// переписывает файл данных во временный с заголовком в начале
+// и контрольными суммами страниц и подменяет им старый
+func (m *TableManager) prependFileHeader(table *Table, descriptor *os.File, schemaID string) error {
+	header := page.NewFileHeader(schemaID, m.pageSize)
+	header.Version = page.FileHeaderFormatVersion
+
+	fileHeader, err := header.Serialize()
+	if err != nil {
+		return fmt.Errorf("FileHeader.Serialize: %w", err)
+	}
+
+	tmpDescriptor, err := os.CreateTemp(m.tableDirPath, table.Name+".data.tmp")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer tmpDescriptor.Close()
+
+	if _, err := tmpDescriptor.Write(fileHeader); err != nil {
+		return fmt.Errorf("File.Write: %w", err)
+	}
+
+	fileInfo, err := descriptor.Stat()
+	if err != nil {
+		return fmt.Errorf("File.Stat: %w", err)
+	}
+
+	// страницы без заголовка записаны без контрольных сумм,
+	// поэтому каждая переписывается со своей суммой
+	pagesNum := int(fileInfo.Size() / int64(m.pageSize))
+	for i := 0; i < pagesNum; i++ {
+		pageOffset := int64(i * m.pageSize)
+
+		oldPage, err := page.ReadPageAtVersion(descriptor, pageOffset, m.pageSize, page.LegacyFormatVersion)
+		if err != nil {
+			return fmt.Errorf("page.ReadPageAtVersion: %w", err)
+		}
+
+		if _, err := tmpDescriptor.Write(oldPage.Serialize()); err != nil {
+			return fmt.Errorf("File.Write: %w", err)
+		}
+	}
+
+	if err := tmpDescriptor.Sync(); err != nil {
+		return fmt.Errorf("File.Sync: %w", err)
+	}
+
+	if err := os.Rename(tmpDescriptor.Name(), table.Path); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	return nil
+}
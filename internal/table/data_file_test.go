@@ -0,0 +1,633 @@
+package table
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_DataFileHeader(t *testing.T) {
+	const recordsNum = 500
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	_, err := tableManager.CreateIndex(tableName, "by_group", []string{"group"})
+	require.NoError(t, err)
+	require.NoError(t, tableManager.Flush())
+
+	table := tableManager.NameToTable[tableName]
+	numPages := table.NumPages
+
+	reload := func() (*TableManager, error) {
+		return InitTableManager("./", &schema.SchemaManager{
+			IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+		})
+	}
+
+	t.Run("файл без заголовка обновляется при загрузке", func(t *testing.T) {
+		// приводим файлы таблицы к первой версии формата: записи
+		// с заголовками версий, но без заголовка файла и контрольных сумм
+		downgradeToFileHeaderFormat(t, table)
+
+		data, err := os.ReadFile(table.Path)
+		require.NoError(t, err)
+
+		// в первой версии страницы записывались без контрольных сумм
+		data = data[page.FirstDataPageOffset(page.DefaultPageSize):]
+		for pageOffset := 0; pageOffset < len(data); pageOffset += page.DefaultPageSize {
+			binary.BigEndian.PutUint32(data[pageOffset+pageChecksumOffset:], 0)
+		}
+		require.NoError(t, os.WriteFile(table.Path, data, consts.PosixAccessRight))
+
+		metadata, err := json.Marshal(&TableMetadata{
+			SchemaID:    table.Schema.ID,
+			NumPages:    numPages,
+			Indexes:     table.Indexes,
+			TxIDHorizon: table.TxIDHorizon,
+			Stats:       table.Stats,
+			CreatedAt:   table.CreatedAt,
+		})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(
+			tableManager.getMetadataFilePath(tableName),
+			metadata,
+			consts.PosixAccessRight,
+		))
+
+		reloaded, err := reload()
+		require.NoError(t, err)
+
+		reloadedTable := reloaded.NameToTable[tableName]
+		assert.Equal(t, page.CurrentFormatVersion, reloadedTable.FormatVersion)
+		assert.Equal(t, numPages, reloadedTable.NumPages)
+
+		descriptor := openFile(t, table.Path)
+		header, err := page.ReadFileHeader(descriptor)
+		require.NoError(t, err)
+		assert.Equal(t, table.Schema.ID, header.SchemaID)
+
+		// страницы переписаны с контрольными суммами
+		for pageNum := 0; pageNum < numPages; pageNum++ {
+			pageOffset := page.FirstDataPageOffset(page.DefaultPageSize) + int64(pageNum*page.DefaultPageSize)
+			_, err := page.ReadPageAt(descriptor, pageOffset, page.DefaultPageSize)
+			require.NoError(t, err)
+		}
+
+		marshalledMetadata, err := os.ReadFile(tableManager.getMetadataFilePath(tableName))
+		require.NoError(t, err)
+
+		var gotMetadata TableMetadata
+		require.NoError(t, json.Unmarshal(marshalledMetadata, &gotMetadata))
+		assert.Equal(t, page.CurrentFormatVersion, gotMetadata.FormatVersion)
+
+		records, err := reloaded.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum, len(records))
+
+		// индексы перестроены под новые смещения строк
+		record, err := reloaded.GetByPrimaryKey(tableName, map[string]any{"id": int32(42)})
+		require.NoError(t, err)
+		id, err := record.GetInt32FieldValue("id")
+		require.NoError(t, err)
+		assert.Equal(t, int32(42), id)
+
+		records, err = reloaded.FindByPredicate(tableName, Eq("group", 3))
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum/10, len(records))
+
+		// вставка находит место по перестроенной карте
+		require.NoError(t, reloaded.Insert(tableName, map[string]any{
+			"id":     int32(recordsNum),
+			"name":   "name_new",
+			"group":  int32(0),
+			"active": true,
+		}))
+		require.NoError(t, reloaded.Flush())
+
+		records, err = reloaded.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum+1, len(records))
+	})
+
+	t.Run("записи без битовой карты null-значений обновляются при загрузке", func(t *testing.T) {
+		downgradeToFileHeaderFormat(t, table)
+		setMetadataFormatVersion(t, tableManager, tableName, page.FileHeaderFormatVersion)
+
+		reloaded, err := reload()
+		require.NoError(t, err)
+
+		reloadedTable := reloaded.NameToTable[tableName]
+		assert.Equal(t, page.CurrentFormatVersion, reloadedTable.FormatVersion)
+
+		header, err := page.ReadFileHeader(openFile(t, table.Path))
+		require.NoError(t, err)
+		assert.Equal(t, page.CurrentFormatVersion, header.Version)
+
+		records, err := reloaded.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum+1, len(records))
+
+		record, err := reloaded.GetByPrimaryKey(tableName, map[string]any{"id": int32(42)})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "name_0042", name)
+
+		records, err = reloaded.FindByPredicate(tableName, Eq("group", 3))
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum/10, len(records))
+	})
+
+	t.Run("заголовок другой схемы", func(t *testing.T) {
+		fileHeader, err := page.NewFileHeader("other_schema", page.DefaultPageSize).Serialize()
+		require.NoError(t, err)
+
+		descriptor := openFile(t, table.Path)
+		_, err = descriptor.WriteAt(fileHeader, 0)
+		require.NoError(t, err)
+
+		_, err = reload()
+		assert.EqualError(
+			t,
+			err,
+			"TableManager.openDataFile: data file of table predicate_table was written with schema other_schema",
+		)
+	})
+}
+
+func TestTableManager_AddTupleHeaders(t *testing.T) {
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, 0, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+
+	rows := make([]map[string]any, 0, 1000)
+	for i := 0; i < cap(rows); i++ {
+		rows = append(rows, map[string]any{
+			"id":     int32(i),
+			"name":   fmt.Sprintf("name_%04d", i),
+			"group":  int32(i % 10),
+			"active": true,
+		})
+	}
+	deleted := map[int32]bool{3: true, 500: true, 999: true}
+
+	writeRawRecordsDataFile(t, table, rows, deleted)
+
+	require.NoError(t, tableManager.addTupleHeaders(table, table.Schema.ID))
+
+	data, err := os.ReadFile(table.Path)
+	require.NoError(t, err)
+
+	checkTuples := func(t *testing.T, data []byte) {
+		gotIDs := make([]int32, 0, len(rows))
+		for pageOffset := 0; pageOffset < len(data); pageOffset += page.DefaultPageSize {
+			// страницы переписаны с контрольными суммами
+			tablePage, err := page.DeserializePage(data[pageOffset : pageOffset+page.DefaultPageSize])
+			require.NoError(t, err)
+
+			for _, ptr := range tablePage.Pointers {
+				require.Equal(t, page.StatusActive, ptr.Status)
+
+				tuple := tablePage.GetDataByPointer(ptr)
+				record, err := DeserializeRecordBySchema(
+					table.Schema,
+					append(make([]byte, nullBitmapSize(len(table.Schema.Columns))), tupleRecordData(tuple)...),
+					nil,
+				)
+				require.NoError(t, err)
+
+				id, err := record.GetInt32FieldValue("id")
+				require.NoError(t, err)
+				gotIDs = append(gotIDs, id)
+
+				// удаленные записи становятся удаленными версиями
+				expectedXmax := uint64(0)
+				if deleted[id] {
+					expectedXmax = frozenTxID
+				}
+				assert.Equal(t, tupleHeader{Xmin: frozenTxID, Xmax: expectedXmax}, readTupleHeader(tuple))
+			}
+		}
+
+		assert.Equal(t, len(rows), len(gotIDs))
+	}
+
+	t.Run("записи получают заголовки версий", func(t *testing.T) {
+		checkTuples(t, data)
+
+		assert.Equal(t, frozenTxID, table.TxIDHorizon)
+		assert.LessOrEqual(t, frozenTxID, tableManager.lastTxID)
+	})
+
+	t.Run("повтор после сбоя не меняет файл", func(t *testing.T) {
+		require.NoError(t, tableManager.addTupleHeaders(table, table.Schema.ID))
+
+		repeated, err := os.ReadFile(table.Path)
+		require.NoError(t, err)
+		assert.Equal(t, data, repeated)
+	})
+}
+
+func TestTableManager_UpgradeRawRecordsDataFile(t *testing.T) {
+	const recordsNum = 1000
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, 0, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+
+	rows := make([]map[string]any, 0, recordsNum)
+	for i := 0; i < recordsNum; i++ {
+		rows = append(rows, map[string]any{
+			"id":     int32(i),
+			"name":   fmt.Sprintf("name_%04d", i),
+			"group":  int32(i % 10),
+			"active": true,
+		})
+	}
+	deleted := map[int32]bool{3: true, 500: true, 999: true}
+
+	writeRawRecordsDataFile(t, table, rows, deleted)
+
+	fileInfo, err := os.Stat(table.Path)
+	require.NoError(t, err)
+
+	// метаданные таблицы до появления версий формата и номеров транзакций
+	metadata, err := json.Marshal(map[string]any{
+		"schemaId":  table.Schema.ID,
+		"numPages":  int(fileInfo.Size() / page.DefaultPageSize),
+		"createdAt": table.CreatedAt,
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(
+		tableManager.getMetadataFilePath(tableName),
+		metadata,
+		consts.PosixAccessRight,
+	))
+
+	reloaded, err := InitTableManager("./", &schema.SchemaManager{
+		IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+	})
+	require.NoError(t, err)
+
+	reloadedTable := reloaded.NameToTable[tableName]
+	liveNum := recordsNum - len(deleted)
+
+	t.Run("файл обновляется до текущей версии", func(t *testing.T) {
+		assert.Equal(t, page.CurrentFormatVersion, reloadedTable.FormatVersion)
+		assert.LessOrEqual(t, frozenTxID, reloadedTable.TxIDHorizon)
+
+		header, err := page.ReadFileHeader(openFile(t, table.Path))
+		require.NoError(t, err)
+		assert.Equal(t, page.CurrentFormatVersion, header.Version)
+
+		marshalledMetadata, err := os.ReadFile(reloaded.getMetadataFilePath(tableName))
+		require.NoError(t, err)
+
+		var gotMetadata TableMetadata
+		require.NoError(t, json.Unmarshal(marshalledMetadata, &gotMetadata))
+		assert.Equal(t, page.CurrentFormatVersion, gotMetadata.FormatVersion)
+		assert.Equal(t, reloadedTable.TxIDHorizon, gotMetadata.TxIDHorizon)
+	})
+
+	t.Run("удаленные записи не видны", func(t *testing.T) {
+		records, err := reloaded.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, liveNum, len(records))
+
+		record, err := reloaded.GetByPrimaryKey(tableName, map[string]any{"id": int32(42)})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "name_0042", name)
+
+		records, err = reloaded.FindByPredicate(tableName, Eq("id", 500))
+		require.NoError(t, err)
+		assert.Empty(t, records)
+
+		records, err = reloaded.FindByPredicate(tableName, Eq("group", 3))
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum/10-1, len(records))
+	})
+
+	t.Run("удаленные записи убирает вакуум", func(t *testing.T) {
+		_, stats, err := reloaded.ShouldVacuum(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, int64(liveNum), stats.LiveTuples)
+		assert.Equal(t, int64(len(deleted)), stats.DeadTuples)
+
+		require.NoError(t, reloaded.ConcurrentVacuum(tableName))
+		assert.Equal(t, liveNum, countTuples(t, reloaded, tableName))
+
+		_, stats, err = reloaded.ShouldVacuum(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, TableStats{LiveTuples: int64(liveNum)}, stats)
+	})
+
+	t.Run("запись после обновления", func(t *testing.T) {
+		require.NoError(t, reloaded.Insert(tableName, map[string]any{
+			"id":     int32(500),
+			"name":   "name_new",
+			"group":  int32(0),
+			"active": false,
+		}))
+
+		records, err := reloaded.FindByPredicate(tableName, Eq("id", 500))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{500}, getRecordIDs(t, records))
+
+		records, err = reloaded.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, liveNum+1, len(records))
+
+		require.NoError(t, reloaded.Flush())
+	})
+}
+
+// записывает файл данных в формате до появления заголовков версий:
+// записи без заголовков и битовых карт null-значений, страницы без
+// заголовка файла и контрольных сумм. удаленные записи остаются на
+// странице с указателем в статусе StatusDeleted
+func TestTableManager_UpgradeOverflowChecksums(t *testing.T) {
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, 3, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+
+	var (
+		longName           = strings.Repeat("0123456789", 3_000)
+		deletedName        = strings.Repeat("9876543210", 3_000)
+		overflowPath       = tableManager.getOverflowFilePath(tableName)
+		legacyOverflowPath = overflowPath + ".legacy"
+	)
+
+	for id, name := range map[int32]string{10: longName, 11: deletedName} {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     id,
+			"name":   name,
+			"group":  int32(0),
+			"active": true,
+		}))
+	}
+	// удаленная версия еще ссылается на свое значение
+	require.NoError(t, tableManager.DeleteByPredicate(tableName, Eq("id", 11)))
+	require.NoError(t, tableManager.Flush())
+
+	reload := func(t *testing.T) *TableManager {
+		reloaded, err := InitTableManager("./", &schema.SchemaManager{
+			IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+		})
+		require.NoError(t, err)
+		return reloaded
+	}
+
+	assertUpgraded := func(t *testing.T, reloaded *TableManager) {
+		assert.Equal(t, page.CurrentFormatVersion, reloaded.NameToTable[tableName].FormatVersion)
+
+		header, err := page.ReadFileHeader(openFile(t, table.Path))
+		require.NoError(t, err)
+		assert.Equal(t, page.CurrentFormatVersion, header.Version)
+
+		_, err = os.Stat(legacyOverflowPath)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		record, err := reloaded.GetByPrimaryKey(tableName, map[string]any{"id": int32(10)})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, longName, name)
+
+		records, err := reloaded.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 1, 2, 10}, getRecordIDs(t, records))
+	}
+
+	t.Run("значения переписываются со страницами с контрольными суммами", func(t *testing.T) {
+		downgradeToNullBitmapFormat(t, tableManager, table)
+		setMetadataFormatVersion(t, tableManager, tableName, page.NullBitmapFormatVersion)
+
+		reloaded := reload(t)
+		assertUpgraded(t, reloaded)
+
+		// страницы удаленного значения возвращаются в список свободных
+		require.NoError(t, reloaded.FullVacuum(tableName))
+		require.NoError(t, reloaded.Insert(tableName, map[string]any{
+			"id":     int32(12),
+			"name":   deletedName,
+			"group":  int32(0),
+			"active": true,
+		}))
+		require.NoError(t, reloaded.Flush())
+
+		record, err := reloaded.GetByPrimaryKey(tableName, map[string]any{"id": int32(12)})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, deletedName, name)
+
+		require.NoError(t, reloaded.DeleteByPredicate(tableName, Eq("id", 12)))
+		require.NoError(t, reloaded.FullVacuum(tableName))
+		require.NoError(t, reloaded.Flush())
+	})
+
+	t.Run("повтор после сбоя читает значения из старого файла", func(t *testing.T) {
+		downgradeToNullBitmapFormat(t, tableManager, table)
+		setMetadataFormatVersion(t, tableManager, tableName, page.NullBitmapFormatVersion)
+
+		// сбой после подмены файла переполнения, но до подмены файла данных
+		require.NoError(t, os.Rename(overflowPath, legacyOverflowPath))
+		require.NoError(t, os.WriteFile(overflowPath, make([]byte, page.DefaultPageSize), consts.PosixAccessRight))
+
+		assertUpgraded(t, reload(t))
+	})
+}
+
+// переписывает файлы таблицы в третьей версии формата:
+// страницы переполнения без контрольных сумм
+func downgradeToNullBitmapFormat(t *testing.T, tableManager *TableManager, table *Table) {
+	overflow := openFile(t, tableManager.getOverflowFilePath(table.Name))
+	descriptor := openFile(t, table.Path)
+
+	fileInfo, err := descriptor.Stat()
+	require.NoError(t, err)
+
+	header := page.NewFileHeader(table.Schema.ID, page.DefaultPageSize)
+	header.Version = page.NullBitmapFormatVersion
+
+	serializedHeader, err := header.Serialize()
+	require.NoError(t, err)
+
+	var (
+		downgraded = serializedHeader
+		// первая страница - пустой список свободных
+		legacyOverflow = make([]byte, page.DefaultPageSize)
+		chunkSize      = page.DefaultPageSize - legacyOverflowPageHeaderSize
+	)
+
+	for pageOffset := page.FirstDataPageOffset(page.DefaultPageSize); pageOffset < fileInfo.Size(); pageOffset += page.DefaultPageSize {
+		tablePage, err := page.ReadPageAt(descriptor, pageOffset, page.DefaultPageSize)
+		require.NoError(t, err)
+
+		for _, ptr := range tablePage.Pointers {
+			if ptr.Status == page.StatusDeleted {
+				continue
+			}
+
+			// указатель заменяется прямо на странице
+			tuple := tablePage.GetDataByPointer(ptr)
+			require.NoError(t, splitRecord(table.Schema, tuple[tupleHeaderSize:], func(_ *schema.Column, value []byte, external bool) error {
+				if !external {
+					return nil
+				}
+
+				assembled, err := readOverflowValue(overflow, value)
+				require.NoError(t, err)
+
+				binary.BigEndian.PutUint64(value, uint64(len(legacyOverflow)))
+				for start := 0; start < len(assembled); start += chunkSize {
+					end := min(start+chunkSize, len(assembled))
+
+					var next int64
+					if end < len(assembled) {
+						next = int64(len(legacyOverflow) + page.DefaultPageSize)
+					}
+
+					legacyPage := make([]byte, page.DefaultPageSize)
+					binary.BigEndian.PutUint64(legacyPage[0:], uint64(next))
+					binary.BigEndian.PutUint16(legacyPage[8:], uint16(end-start))
+					copy(legacyPage[legacyOverflowPageHeaderSize:], assembled[start:end])
+
+					legacyOverflow = append(legacyOverflow, legacyPage...)
+				}
+
+				return nil
+			}))
+		}
+
+		downgraded = append(downgraded, tablePage.Serialize()...)
+	}
+
+	require.NoError(t, os.WriteFile(table.Path, downgraded, consts.PosixAccessRight))
+	require.NoError(t, os.WriteFile(tableManager.getOverflowFilePath(table.Name), legacyOverflow, consts.PosixAccessRight))
+}
+
+func writeRawRecordsDataFile(t *testing.T, table *Table, rows []map[string]any, deleted map[int32]bool) {
+	bitmapSize := nullBitmapSize(len(table.Schema.Columns))
+
+	var (
+		data         []byte
+		tablePage    = page.NewEmptyPage(page.DefaultPageSize)
+		deletedSlots []int
+	)
+
+	flush := func() {
+		// слоты помечаются после вставки, иначе вставка заняла бы их
+		for _, slot := range deletedSlots {
+			tablePage.Pointers[slot].Status = page.StatusDeleted
+		}
+
+		serialized := tablePage.Serialize()
+		binary.BigEndian.PutUint32(serialized[pageChecksumOffset:], 0)
+
+		data = append(data, serialized...)
+		tablePage = page.NewEmptyPage(page.DefaultPageSize)
+		deletedSlots = nil
+	}
+
+	for _, row := range rows {
+		record, err := NewRecordInSchema(table.Schema, row)
+		require.NoError(t, err)
+
+		raw := record.Serialize()[bitmapSize:]
+		if !tablePage.FreeSpaceMoreThanRequired(len(raw) + page.ItemPointerSize) {
+			flush()
+		}
+
+		slot, err := tablePage.Insert(raw)
+		require.NoError(t, err)
+
+		if deleted[row["id"].(int32)] {
+			deletedSlots = append(deletedSlots, slot)
+		}
+	}
+	flush()
+
+	require.NoError(t, os.WriteFile(table.Path, data, consts.PosixAccessRight))
+}
+
+// переписывает файл данных во второй версии формата:
+// с заголовком, но без битовых карт null-значений в записях
+func downgradeToFileHeaderFormat(t *testing.T, table *Table) {
+	descriptor := openFile(t, table.Path)
+
+	fileInfo, err := descriptor.Stat()
+	require.NoError(t, err)
+
+	header := page.NewFileHeader(table.Schema.ID, page.DefaultPageSize)
+	header.Version = page.FileHeaderFormatVersion
+
+	serializedHeader, err := header.Serialize()
+	require.NoError(t, err)
+
+	downgraded := serializedHeader
+	bitmapSize := nullBitmapSize(len(table.Schema.Columns))
+
+	for pageOffset := page.FirstDataPageOffset(page.DefaultPageSize); pageOffset < fileInfo.Size(); pageOffset += page.DefaultPageSize {
+		tablePage, err := page.ReadPageAt(descriptor, pageOffset, page.DefaultPageSize)
+		require.NoError(t, err)
+
+		downgradedPage := page.NewEmptyPage(page.DefaultPageSize)
+		for _, ptr := range tablePage.Pointers {
+			if ptr.Status == page.StatusDeleted {
+				continue
+			}
+
+			data := tablePage.GetDataByPointer(ptr)
+			tuple := append(append([]byte{}, data[:tupleHeaderSize]...), data[tupleHeaderSize+bitmapSize:]...)
+
+			_, err := downgradedPage.Insert(tuple)
+			require.NoError(t, err)
+		}
+
+		downgraded = append(downgraded, downgradedPage.Serialize()...)
+	}
+
+	require.NoError(t, os.WriteFile(table.Path, downgraded, consts.PosixAccessRight))
+}
+
+func setMetadataFormatVersion(t *testing.T, tableManager *TableManager, tableName string, version uint16) {
+	path := tableManager.getMetadataFilePath(tableName)
+
+	marshalledMetadata, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var metadata TableMetadata
+	require.NoError(t, json.Unmarshal(marshalledMetadata, &metadata))
+	metadata.FormatVersion = version
+
+	marshalledMetadata, err = json.Marshal(&metadata)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, marshalledMetadata, consts.PosixAccessRight))
+}
+
+// смещение контрольной суммы в заголовке страницы
+const pageChecksumOffset = 6
+
+func openFile(t *testing.T, path string) *os.File {
+	descriptor, err := os.OpenFile(path, os.O_RDWR, consts.PosixAccessRight)
+	require.NoError(t, err)
+	t.Cleanup(func() { descriptor.Close() })
+
+	return descriptor
+}
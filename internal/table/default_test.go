@@ -0,0 +1,71 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_Defaults(t *testing.T) {
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, 0, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+	table.Schema.NameToColumn["name"].Default = &schema.Default{Generator: schema.UUIDGenerator}
+	table.Schema.NameToColumn["group"].Default = &schema.Default{Value: int32(7)}
+	table.Schema.NameToColumn["group"].Nullable = true
+
+	t.Run("непереданные значения заполняются по умолчанию", func(t *testing.T) {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(1),
+			"active": true,
+		}))
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(2),
+			"active": true,
+		}))
+
+		record1, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(1)})
+		require.NoError(t, err)
+		record2, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(2)})
+		require.NoError(t, err)
+
+		group, err := record1.GetInt32FieldValue("group")
+		require.NoError(t, err)
+		assert.Equal(t, int32(7), group)
+
+		// генератор вызывается для каждой записи
+		name1, err := record1.GetStringFieldValue("name")
+		require.NoError(t, err)
+		name2, err := record2.GetStringFieldValue("name")
+		require.NoError(t, err)
+
+		assert.NoError(t, uuid.Validate(name1))
+		assert.NotEqual(t, name1, name2)
+	})
+
+	t.Run("переданные значения не заменяются", func(t *testing.T) {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(3),
+			"name":   "name_3",
+			"group":  nil,
+			"active": false,
+		}))
+
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(3)})
+		require.NoError(t, err)
+
+		nameToValue, err := record.IntoNameToValue()
+		require.NoError(t, err)
+		assert.Equal(t, "name_3", nameToValue["name"])
+		assert.Nil(t, nameToValue["group"])
+	})
+
+	t.Run("колонка без значения по умолчанию", func(t *testing.T) {
+		err := tableManager.Insert(tableName, map[string]any{"id": int32(4)})
+		assert.ErrorContains(t, err, ErrFieldNotProvided("active").Error())
+	})
+}
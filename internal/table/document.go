@@ -0,0 +1,111 @@
+package table
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/artem-vildanov/small-db/internal/index"
+	"github.com/artem-vildanov/small-db/internal/jsondoc"
+	"github.com/artem-vildanov/small-db/internal/schema"
+)
+
+/*
+К значениям внутри документов json обращаются по пути:
+имя колонки и ключи объектов или номера элементов массивов
+через точку, например payload.user.id или payload.items.0.
+
+Путь можно использовать в предикатах и в колонках индекса.
+*/
+
+// тег типа значения по пути в ключе индекса. значения разных
+// типов не сравниваются между собой, но упорядочены по тегу
+const (
+	documentKeyMissing byte = iota
+	documentKeyNull
+	documentKeyFalse
+	documentKeyTrue
+	documentKeyNumber
+	documentKeyString
+	// массивы и объекты сравниваются только на равенство
+	documentKeyComposite
+)
+
+// колонка и путь внутри нее. у обычной колонки путь nil
+func resolveColumn(tableSchema *schema.Schema, name string) (*schema.Column, []string, error) {
+	if column, exists := tableSchema.NameToColumn[name]; exists {
+		return column, nil, nil
+	}
+
+	columnName, path, isPath := splitColumnPath(name)
+	if !isPath {
+		return nil, nil, ErrNoSuchColumnInSchema(name)
+	}
+
+	column, exists := tableSchema.NameToColumn[columnName]
+	if !exists {
+		return nil, nil, ErrNoSuchColumnInSchema(columnName)
+	}
+
+	if column.Type != schema.JSONType {
+		return nil, nil, ErrPathInNonJSONColumn(name, column.Type)
+	}
+
+	return column, path, nil
+}
+
+func splitColumnPath(name string) (string, []string, bool) {
+	columnName, path, isPath := strings.Cut(name, ".")
+	if !isPath {
+		return name, nil, false
+	}
+
+	return columnName, strings.Split(path, "."), true
+}
+
+// значение по пути в документе колонки. документ null
+// считается документом без значения по этому пути
+func lookupPath(document any, path []string) (any, bool) {
+	if document == nil {
+		return nil, false
+	}
+
+	return jsondoc.Lookup(document, path)
+}
+
+func appendDocumentKey(dst []byte, value any, found bool) ([]byte, error) {
+	if !found {
+		return append(dst, documentKeyMissing), nil
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return append(dst, documentKeyNull), nil
+	case bool:
+		if v {
+			return append(dst, documentKeyTrue), nil
+		}
+		return append(dst, documentKeyFalse), nil
+	// целые и дробные числа сравниваются вместе, поэтому
+	// целые больше 2^53 различаются с потерей точности
+	case int64:
+		return index.AppendFloat64Key(append(dst, documentKeyNumber), float64(v)), nil
+	case float64:
+		// -0 равен 0
+		if v == 0 {
+			v = 0
+		}
+		return index.AppendFloat64Key(append(dst, documentKeyNumber), v), nil
+	case string:
+		return index.AppendStringKey(append(dst, documentKeyString), v), nil
+	default:
+		encoded, err := jsondoc.Encode(v)
+		if err != nil {
+			return nil, fmt.Errorf("jsondoc.Encode: %w", err)
+		}
+		return index.AppendBytesKey(append(dst, documentKeyComposite), encoded), nil
+	}
+}
+
+func ErrPathInNonJSONColumn(path string, columnType schema.ColumnType) error {
+	return fmt.Errorf("path %s points into column of type %s, expected json", path, columnType)
+}
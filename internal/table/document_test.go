@@ -0,0 +1,164 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/jsondoc"
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_JSON(t *testing.T) {
+	tableName, tableManager := initTableWithColumns(t, []*schema.Column{
+		{Name: "id", Type: schema.Int32Type, Size: int(schema.Int32Size)},
+		{Name: "payload", Type: schema.JSONType, Size: -1, Nullable: true},
+		{Name: "name", Type: schema.StringType, Size: -1},
+	}, "id")
+
+	_, err := tableManager.CreateIndex(tableName, "by_user_id", []string{"payload.user.id"})
+	require.NoError(t, err)
+
+	payloads := []any{
+		`{"user": {"id": 5, "name": "anna"}, "tags": ["a", "b"]}`,
+		map[string]any{"user": map[string]any{"id": 7.5}, "tags": []string{"b"}},
+		[]byte(`{"user": {"id": "5"}}`),
+		`{"user": {"id": null}}`,
+		`{"user": {}}`,
+		nil,
+		`[1, 2, 3]`,
+		`{"user": {"id": 10}}`,
+	}
+	for i, payload := range payloads {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":      int32(i),
+			"payload": payload,
+			"name":    "name",
+		}))
+	}
+
+	t.Run("документ читается в виде значений go", func(t *testing.T) {
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(0)})
+		require.NoError(t, err)
+
+		payload, err := record.GetJSONFieldValue("payload")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"user": map[string]any{"id": int64(5), "name": "anna"},
+			"tags": []any{"a", "b"},
+		}, payload)
+
+		nameToValue, err := record.IntoNameToValue()
+		require.NoError(t, err)
+		assert.Equal(t, payload, nameToValue["payload"])
+	})
+
+	testCases := []struct {
+		name        string
+		predicate   *Predicate
+		expectedIDs []int32
+		usesIndex   string
+	}{
+		{
+			name:        "равенство по пути",
+			predicate:   Eq("payload.user.id", 5),
+			expectedIDs: []int32{0},
+			usesIndex:   "by_user_id",
+		},
+		{
+			name:        "строка не равна числу",
+			predicate:   Eq("payload.user.id", "5"),
+			expectedIDs: []int32{2},
+			usesIndex:   "by_user_id",
+		},
+		{
+			name:        "целые и дробные числа сравниваются вместе",
+			predicate:   And(Gt("payload.user.id", 5), Lte("payload.user.id", 10.0)),
+			expectedIDs: []int32{1, 7},
+			usesIndex:   "by_user_id",
+		},
+		{
+			name:        "неравенство включает значения другого типа",
+			predicate:   Ne("payload.user.id", 5),
+			expectedIDs: []int32{1, 2, 7},
+		},
+		{
+			name:        "элемент массива",
+			predicate:   Eq("payload.tags.0", "b"),
+			expectedIDs: []int32{1},
+		},
+		{
+			name:        "нет значения по пути",
+			predicate:   IsNull("payload.user.id"),
+			expectedIDs: []int32{3, 4, 5, 6},
+		},
+		{
+			name:        "вложенный объект",
+			predicate:   Eq("payload.user", map[string]any{"name": "anna", "id": 5}),
+			expectedIDs: []int32{0},
+		},
+		{
+			name:        "документ целиком",
+			predicate:   Eq("payload", `[1, 2, 3]`),
+			expectedIDs: []int32{6},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			table := tableManager.NameToTable[tableName]
+
+			bound, err := tc.predicate.bind(table.Schema)
+			require.NoError(t, err)
+
+			scans := chooseIndexScans(table, bound)
+			if tc.usesIndex != "" {
+				require.Equal(t, 1, len(scans))
+				assert.Equal(t, tc.usesIndex, scans[0].Index.Name)
+			}
+
+			gotRecords, err := tableManager.FindByPredicate(tableName, tc.predicate)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expectedIDs, getRecordIDs(t, gotRecords))
+		})
+	}
+
+	t.Run("путь в условии-замыкании", func(t *testing.T) {
+		records, err := tableManager.FindByCondition(tableName, func(record map[string]any) bool {
+			value, found := LookupPath(record, "payload.user.name")
+			return found && value == "anna"
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0}, getRecordIDs(t, records))
+	})
+
+	t.Run("индекс по пути обновляется", func(t *testing.T) {
+		err := tableManager.UpdateByPredicate(tableName, Eq("id", 4), func(record map[string]any) {
+			record["payload"] = `{"user": {"id": 5}}`
+		})
+		require.NoError(t, err)
+
+		records, err := tableManager.FindByPredicate(tableName, Eq("payload.user.id", 5))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 4}, getRecordIDs(t, records))
+	})
+
+	t.Run("путь в колонке другого типа", func(t *testing.T) {
+		_, err := tableManager.FindByPredicate(tableName, Eq("name.first", "x"))
+		assert.ErrorContains(t, err, ErrPathInNonJSONColumn("name.first", schema.StringType).Error())
+
+		_, err = tableManager.CreateIndex(tableName, "by_name", []string{"name.first"})
+		assert.ErrorContains(t, err, ErrPathInNonJSONColumn("name.first", schema.StringType).Error())
+	})
+
+	t.Run("некорректный документ", func(t *testing.T) {
+		for _, payload := range []any{`{"user": `, `{"a": 1} 2`, []byte(`nul`)} {
+			err := tableManager.Insert(tableName, map[string]any{
+				"id":      int32(100),
+				"payload": payload,
+				"name":    "name",
+			})
+			assert.ErrorIs(t, err, jsondoc.ErrInvalidDocument)
+		}
+	})
+}
@@ -36,4 +36,82 @@ func ErrRecordNotFound() error {
 
 func ErrUniqueConstraintViolation(violatedFields []string) error {
 	return fmt.Errorf("unique constraint violation on fields: %v", violatedFields)
-}
\ No newline at end of file
+}
+
+func ErrTableHasNoPrimaryKey(name string) error {
+	return fmt.Errorf("table %s has no primary key", name)
+}
+
+func ErrIndexWithNameExists(name string) error {
+	return fmt.Errorf("index with name %s already exists", name)
+}
+
+func ErrIndexWithoutColumns(name string) error {
+	return fmt.Errorf("index %s must have at least one column", name)
+}
+
+func ErrIndexPointsToMissingRow(pageOffset int64, pointerIndex int) error {
+	return fmt.Errorf(
+		"index points to missing row: page offset %d, pointer index %d",
+		pageOffset,
+		pointerIndex,
+	)
+}
+
+func ErrInvalidPredicate(operator Operator) error {
+	return fmt.Errorf("invalid predicate with operator %s", operator)
+}
+
+func ErrConcurrentUpdate() error {
+	return fmt.Errorf("could not serialize access due to concurrent update")
+}
+
+func ErrLockTimeout(tableName string) error {
+	return fmt.Errorf("timeout while waiting for write lock on table %s", tableName)
+}
+
+func ErrTxDone() error {
+	return fmt.Errorf("transaction has already been committed or rolled back")
+}
+
+func ErrTxAborted() error {
+	return fmt.Errorf("transaction is aborted, only rollback is allowed")
+}
+
+func ErrAutovacuumAlreadyRunning() error {
+	return fmt.Errorf("autovacuum is already running")
+}
+
+func ErrRecordTooLarge(tableName string, size int) error {
+	return fmt.Errorf("record of %d bytes doesnt fit into page of table %s", size, tableName)
+}
+
+func ErrCorruptedOverflowValue() error {
+	return fmt.Errorf("value in overflow pages is corrupted")
+}
+
+func ErrCorruptedOverflowPage(pageOffset int64, stored, computed uint32) error {
+	return fmt.Errorf(
+		"overflow page at offset %d is corrupted: stored checksum %08x, computed %08x",
+		pageOffset,
+		stored,
+		computed,
+	)
+}
+
+func ErrDataFileSchemaMismatch(tableName, schemaID string) error {
+	return fmt.Errorf("data file of table %s was written with schema %s", tableName, schemaID)
+}
+
+func ErrTableSchemaNotFound(tableName, schemaID string) error {
+	return fmt.Errorf("schema %s of table %s not found", schemaID, tableName)
+}
+
+func ErrTablePageSizeMismatch(tableName string, pageSize, expected int) error {
+	return fmt.Errorf(
+		"table %s has page size %d, database page size is %d",
+		tableName,
+		pageSize,
+		expected,
+	)
+}
@@ -0,0 +1,254 @@
+package table
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/page"
+)
+
+/*
+Карта свободного места таблицы.
+
+Каждой странице файла данных соответствует байт в файле <table>.fsm:
+сколько места на странице осталось под новую строку, в единицах
+по 1/256 размера страницы с округлением вниз. Вставка выбирает страницу по карте
+и читает только ее, а не весь файл данных.
+
+Файл карты делится на страницы размера страницы данных. Страница
+карты начинается с контрольной суммы CRC32C, за ней байты страниц
+данных по порядку. Карта только подсказка, поэтому страница
+с неверной суммой не ошибка: она строится заново по страницам данных.
+
+Карта изменяется через pager вместе со страницами данных, поэтому
+попадает в журнал и переживает сбой. Удаление строки только
+проставляет ей Xmax и места не освобождает: его возвращает вакуум,
+после которого карта строится заново.
+*/
+type freeSpaceMap struct {
+	file     page.File
+	dataFile page.File
+	pageSize int
+	// количество страниц файла данных вместе с заголовком
+	numPages int64
+}
+
+func (m *TableManager) openFreeSpaceMap(
+	pager *pager,
+	table *Table,
+	dataFile page.File,
+) (*freeSpaceMap, error) {
+	fsmPath := m.getFsmFilePath(table.Name)
+
+	fsmFile, err := pager.file(fsmPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// таблица создана до появления карты
+		if err := m.buildFreeSpaceMap(table); err != nil {
+			return nil, fmt.Errorf("TableManager.buildFreeSpaceMap: %w", err)
+		}
+
+		fsmFile, err = pager.file(fsmPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pager.file: %w", err)
+	}
+
+	dataFileInfo, err := dataFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("File.Stat: %w", err)
+	}
+
+	return &freeSpaceMap{
+		file:     fsmFile,
+		dataFile: dataFile,
+		pageSize: m.pageSize,
+		numPages: dataFileInfo.Size() / int64(m.pageSize),
+	}, nil
+}
+
+// размеры в байтах
+const (
+	// Checksum (4) в начале страницы карты
+	fsmPageHeaderSize = 4
+)
+
+// сколько страниц данных описывает одна страница карты
+func fsmEntriesPerPage(pageSize int) int64 {
+	return int64(pageSize - fsmPageHeaderSize)
+}
+
+// смещение первой страницы, на которой по карте есть required байт
+func (fsm *freeSpaceMap) find(required int) (int64, bool, error) {
+	// страница из категории c гарантированно вмещает c * unit байт
+	unit := freeSpaceUnit(fsm.pageSize)
+	category := (required + unit - 1) / unit
+	if category > 255 {
+		return 0, false, nil
+	}
+
+	entriesPerPage := fsmEntriesPerPage(fsm.pageSize)
+	for from := int64(0); from < fsm.numPages; from += entriesPerPage {
+		fsmPage, err := fsm.readPage(from)
+		if err != nil {
+			return 0, false, fmt.Errorf("freeSpaceMap.readPage: %w", err)
+		}
+
+		entries := fsmPage[fsmPageHeaderSize:][:min(entriesPerPage, fsm.numPages-from)]
+		for i, entry := range entries {
+			if int(entry) >= category {
+				return (from + int64(i)) * int64(fsm.pageSize), true, nil
+			}
+		}
+	}
+
+	return 0, false, nil
+}
+
+// запоминает, сколько места осталось на странице
+func (fsm *freeSpaceMap) update(pageOffset int64, freeSpace int) error {
+	pageNum := pageOffset / int64(fsm.pageSize)
+	entriesPerPage := fsmEntriesPerPage(fsm.pageSize)
+
+	fsmPage, err := fsm.readPage(pageNum)
+	if err != nil {
+		return fmt.Errorf("freeSpaceMap.readPage: %w", err)
+	}
+
+	fsmPage[fsmPageHeaderSize+pageNum%entriesPerPage] = freeSpaceCategory(freeSpace, fsm.pageSize)
+	stampFsmPage(fsmPage)
+
+	if _, err := fsm.file.WriteAt(fsmPage, pageNum/entriesPerPage*int64(fsm.pageSize)); err != nil {
+		return fmt.Errorf("File.WriteAt: %w", err)
+	}
+
+	if pageNum >= fsm.numPages {
+		fsm.numPages = pageNum + 1
+	}
+
+	return nil
+}
+
+// страница карты с записью о странице данных pageNum. страницы,
+// которых еще нет в карте, считаются заполненными, а страница
+// с неверной суммой строится заново и записывается вместе с операцией
+func (fsm *freeSpaceMap) readPage(pageNum int64) ([]byte, error) {
+	var (
+		entriesPerPage = fsmEntriesPerPage(fsm.pageSize)
+		fsmPageOffset  = pageNum / entriesPerPage * int64(fsm.pageSize)
+		fsmPage        = make([]byte, fsm.pageSize)
+	)
+
+	read, err := fsm.file.ReadAt(fsmPage, fsmPageOffset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("File.ReadAt: %w", err)
+	}
+	if read == 0 {
+		return fsmPage, nil
+	}
+
+	stored := binary.BigEndian.Uint32(fsmPage)
+	if stored == page.ChecksumExcluding(fsmPage, 0) {
+		return fsmPage, nil
+	}
+
+	clear(fsmPage)
+	from := pageNum - pageNum%entriesPerPage
+	for i := int64(0); i < entriesPerPage && from+i < fsm.numPages; i++ {
+		pageOffset := (from + i) * int64(fsm.pageSize)
+
+		// в заголовке файла места под строки нет
+		if pageOffset < page.FirstDataPageOffset(fsm.pageSize) {
+			continue
+		}
+
+		tablePage, err := page.ReadPageAt(fsm.dataFile, pageOffset, fsm.pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("page.ReadPageAt: %w", err)
+		}
+
+		fsmPage[fsmPageHeaderSize+i] = freeSpaceCategory(tablePage.FreeSpace(), fsm.pageSize)
+	}
+	stampFsmPage(fsmPage)
+
+	if _, err := fsm.file.WriteAt(fsmPage, fsmPageOffset); err != nil {
+		return nil, fmt.Errorf("File.WriteAt: %w", err)
+	}
+
+	return fsmPage, nil
+}
+
+func stampFsmPage(fsmPage []byte) {
+	binary.BigEndian.PutUint32(fsmPage, page.ChecksumExcluding(fsmPage, 0))
+}
+
+// точность карты свободного места в байтах
+func freeSpaceUnit(pageSize int) int {
+	return pageSize / 256
+}
+
+func freeSpaceCategory(freeSpace int, pageSize int) byte {
+	return byte(min(freeSpace/freeSpaceUnit(pageSize), 255))
+}
+
+// строит карту заново по страницам данных,
+// например после того как вакуум переписал файл
+func (m *TableManager) buildFreeSpaceMap(table *Table) error {
+	fsmPath := m.getFsmFilePath(table.Name)
+	m.pool.invalidate(fsmPath)
+
+	fsmDescriptor, err := os.OpenFile(
+		fsmPath,
+		os.O_CREATE|os.O_TRUNC|os.O_RDWR,
+		consts.PosixAccessRight,
+	)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+	defer fsmDescriptor.Close()
+
+	dataFile, err := newPager(m.pool).file(table.Path)
+	if err != nil {
+		return fmt.Errorf("pager.file: %w", err)
+	}
+
+	iter, err := page.NewPagesIter(dataFile)
+	if err != nil {
+		return fmt.Errorf("NewPagesIter: %w", err)
+	}
+
+	// в заголовке файла места под строки нет
+	entries := make([]byte, page.FirstDataPageOffset(m.pageSize)/int64(m.pageSize))
+	for iter.Next() {
+		tablePage, err := iter.GetPage()
+		if err != nil {
+			return fmt.Errorf("pagesIterator.GetPage: %w", err)
+		}
+
+		entries = append(entries, freeSpaceCategory(tablePage.FreeSpace(), m.pageSize))
+	}
+
+	entriesPerPage := int(fsmEntriesPerPage(m.pageSize))
+	for from := 0; from < len(entries); from += entriesPerPage {
+		fsmPage := make([]byte, m.pageSize)
+		copy(fsmPage[fsmPageHeaderSize:], entries[from:min(from+entriesPerPage, len(entries))])
+		stampFsmPage(fsmPage)
+
+		if _, err := fsmDescriptor.Write(fsmPage); err != nil {
+			return fmt.Errorf("File.Write: %w", err)
+		}
+	}
+
+	if err := fsmDescriptor.Sync(); err != nil {
+		return fmt.Errorf("File.Sync: %w", err)
+	}
+
+	return nil
+}
+
+func (m *TableManager) getFsmFilePath(tableName string) string {
+	return fmt.Sprintf("%s%s%s", m.tableDirPath, tableName, consts.FsmExtension)
+}
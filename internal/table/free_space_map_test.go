@@ -0,0 +1,125 @@
+package table
+
+import (
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_FreeSpaceMap(t *testing.T) {
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, 0, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+
+	insert := func(t *testing.T, id int32, nameLen int) {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     id,
+			"name":   strings.Repeat("x", nameLen),
+			"group":  int32(0),
+			"active": true,
+		}))
+	}
+
+	// количество строк на каждой странице
+	pointersPerPage := func(t *testing.T) []int {
+		dataFile, err := newPager(tableManager.pool).file(table.Path)
+		require.NoError(t, err)
+
+		iter, err := page.NewPagesIter(dataFile)
+		require.NoError(t, err)
+
+		counts := make([]int, 0)
+		for iter.Next() {
+			tablePage, err := iter.GetPage()
+			require.NoError(t, err)
+			counts = append(counts, len(tablePage.Pointers))
+		}
+		return counts
+	}
+
+	assertMapMatchesPages := func(t *testing.T) {
+		require.NoError(t, tableManager.Flush())
+
+		fsmPage, err := os.ReadFile(tableManager.getFsmFilePath(tableName))
+		require.NoError(t, err)
+		require.Len(t, fsmPage, page.DefaultPageSize)
+		assert.Equal(t, binary.BigEndian.Uint32(fsmPage), page.ChecksumExcluding(fsmPage, 0))
+
+		entries := fsmPage[fsmPageHeaderSize:]
+		// первая страница файла - заголовок, места под строки на ней нет
+		assert.Equal(t, byte(0), entries[0])
+
+		dataFile, err := newPager(tableManager.pool).file(table.Path)
+		require.NoError(t, err)
+
+		for pageNum := 1; pageNum <= table.NumPages; pageNum++ {
+			tablePage, err := page.ReadPageAt(dataFile, int64(pageNum)*page.DefaultPageSize, page.DefaultPageSize)
+			require.NoError(t, err)
+			assert.Equal(t, freeSpaceCategory(tablePage.FreeSpace(), page.DefaultPageSize), entries[pageNum])
+		}
+	}
+
+	t.Run("строка попадает на страницу со свободным местом", func(t *testing.T) {
+		// на страницу помещаются четыре такие строки
+		for id := int32(0); id < 9; id++ {
+			insert(t, id, 1900)
+		}
+		assert.Equal(t, []int{4, 4, 1}, pointersPerPage(t))
+
+		// на первых страницах еще осталось место под небольшую строку
+		insert(t, 100, 10)
+		assert.Equal(t, []int{5, 4, 1}, pointersPerPage(t))
+
+		// а под большую только на последней
+		insert(t, 101, 1900)
+		assert.Equal(t, []int{5, 4, 2}, pointersPerPage(t))
+
+		assertMapMatchesPages(t)
+	})
+
+	t.Run("вакуум перестраивает карту", func(t *testing.T) {
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Lt("id", 4)))
+		require.NoError(t, tableManager.FullVacuum(tableName))
+
+		assertMapMatchesPages(t)
+	})
+
+	t.Run("карта создается для таблицы без нее", func(t *testing.T) {
+		require.NoError(t, os.Remove(tableManager.getFsmFilePath(tableName)))
+		tableManager.pool.invalidate(tableManager.getFsmFilePath(tableName))
+
+		insert(t, 102, 10)
+
+		assertMapMatchesPages(t)
+
+		records, err := tableManager.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{4, 5, 6, 7, 8, 100, 101, 102}, getRecordIDs(t, records))
+	})
+
+	t.Run("страница карты с неверной суммой строится заново", func(t *testing.T) {
+		require.NoError(t, tableManager.Flush())
+
+		fsmPath := tableManager.getFsmFilePath(tableName)
+		fsmPage, err := os.ReadFile(fsmPath)
+		require.NoError(t, err)
+
+		// сумма не меняется: по карте на всех страницах много места
+		for pageNum := 1; pageNum <= table.NumPages; pageNum++ {
+			fsmPage[fsmPageHeaderSize+pageNum] = 255
+		}
+		require.NoError(t, os.WriteFile(fsmPath, fsmPage, consts.PosixAccessRight))
+		tableManager.pool.invalidate(fsmPath)
+
+		insert(t, 103, 10)
+
+		assertMapMatchesPages(t)
+	})
+}
@@ -0,0 +1,370 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/index"
+	"github.com/artem-vildanov/small-db/internal/jsondoc"
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/google/uuid"
+)
+
+// признак перед значением nullable-колонки в ключе индекса
+const (
+	nullKeyByte    byte = 0
+	notNullKeyByte byte = 1
+)
+
+func (m *TableManager) CreateIndex(
+	tableName string,
+	indexName string,
+	columns []string,
+) (_ *Index, err error) {
+	defer func() { page.SetCorruptedPageTable(err, tableName) }()
+
+	table, lock, err := m.lockTableWriter(tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlockWriter()
+
+	if len(columns) == 0 {
+		return nil, ErrIndexWithoutColumns(indexName)
+	}
+
+	// колонкой индекса может быть путь в документе json
+	for _, column := range columns {
+		if _, _, err := resolveColumn(table.Schema, column); err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.ContainsFunc(table.Indexes, func(idx *Index) bool {
+		return idx.Name == indexName
+	}) {
+		return nil, ErrIndexWithNameExists(indexName)
+	}
+
+	tableIndex := &Index{
+		Name:    indexName,
+		Columns: columns,
+		Path:    m.getIndexFilePath(tableName, indexName),
+	}
+
+	if err := m.buildIndex(table, tableIndex); err != nil {
+		return nil, fmt.Errorf("TableManager.buildIndex: %w", err)
+	}
+
+	lock.files.Lock()
+	defer lock.files.Unlock()
+
+	table.Indexes = append(table.Indexes, tableIndex)
+	if err := m.atomicUpdateMetadata(table); err != nil {
+		return nil, fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
+	}
+
+	return tableIndex, nil
+}
+
+// создает файл индекса и заполняет его строками таблицы
+func (m *TableManager) buildIndex(table *Table, tableIndex *Index) error {
+	indexDescriptor, err := m.createIfNotExists(tableIndex.Path)
+	if err != nil {
+		return fmt.Errorf("TableManager.createIfNotExists: %w", err)
+	}
+	defer indexDescriptor.Close()
+
+	tree, err := index.Create(indexDescriptor, m.pageSize)
+	if err != nil {
+		return fmt.Errorf("index.Create: %w", err)
+	}
+
+	pager := newPager(m.pool)
+
+	dataFile, err := pager.file(table.Path)
+	if err != nil {
+		return fmt.Errorf("pager.file: %w", err)
+	}
+
+	overflow, err := m.overflowReader(pager, table)
+	if err != nil {
+		return fmt.Errorf("TableManager.overflowReader: %w", err)
+	}
+
+	iter, err := page.NewPagesIter(dataFile)
+	if err != nil {
+		return fmt.Errorf("NewPagesIter: %w", err)
+	}
+
+	for iter.Next() {
+		tablePage, err := iter.GetPage()
+		if err != nil {
+			return fmt.Errorf("pagesIterator.GetPage: %w", err)
+		}
+
+		for pointerIndex, pointer := range tablePage.Pointers {
+			if pointer.Status != page.StatusActive {
+				continue
+			}
+
+			record, err := DeserializeRecordBySchema(
+				table.Schema,
+				tupleRecordData(tablePage.GetDataByPointer(pointer)),
+				overflow,
+			)
+			if err != nil {
+				return fmt.Errorf("DeserializeRecordBySchema: %w", err)
+			}
+
+			key, err := encodeIndexKey(tableIndex, record)
+			if err != nil {
+				return fmt.Errorf("encodeIndexKey: %w", err)
+			}
+
+			if err := tree.Insert(key, index.Location{
+				PageOffset:   iter.GetPageOffset(),
+				PointerIndex: pointerIndex,
+			}); err != nil {
+				return fmt.Errorf("BTree.Insert: %w", err)
+			}
+		}
+	}
+
+	// файл индекса пишется мимо журнала, поэтому до того, как на него
+	// сошлются метаданные, он сам и запись о нем в каталоге сбрасываются на диск
+	if err := indexDescriptor.Sync(); err != nil {
+		return fmt.Errorf("File.Sync: %w", err)
+	}
+
+	if err := m.syncTableDir(); err != nil {
+		return fmt.Errorf("TableManager.syncTableDir: %w", err)
+	}
+
+	return nil
+}
+
+// пересоздает все индексы таблицы,
+// например после того как вакуум переместил строки
+func (m *TableManager) rebuildIndexes(table *Table) error {
+	for _, tableIndex := range table.Indexes {
+		m.pool.invalidate(tableIndex.Path)
+
+		if err := os.Remove(tableIndex.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("os.Remove: %w", err)
+		}
+
+		if err := m.buildIndex(table, tableIndex); err != nil {
+			return fmt.Errorf("TableManager.buildIndex: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (m *TableManager) addToIndexes(
+	pager *pager,
+	table *Table,
+	record *Record,
+	location index.Location,
+) error {
+	return m.doWithIndexes(pager, table, record, func(tree *index.BTree, key []byte) error {
+		if err := tree.Insert(key, location); err != nil {
+			return fmt.Errorf("BTree.Insert: %w", err)
+		}
+		return nil
+	})
+}
+
+func (m *TableManager) doWithIndexes(
+	pager *pager,
+	table *Table,
+	record *Record,
+	do func(tree *index.BTree, key []byte) error,
+) error {
+	for _, tableIndex := range table.Indexes {
+		key, err := encodeIndexKey(tableIndex, record)
+		if err != nil {
+			return fmt.Errorf("encodeIndexKey: %w", err)
+		}
+
+		if err := m.withIndex(pager, tableIndex, func(tree *index.BTree) error {
+			return do(tree, key)
+		}); err != nil {
+			return fmt.Errorf("index %s: %w", tableIndex.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// индекс читается и изменяется через pager операции
+func (m *TableManager) withIndex(
+	pager *pager,
+	tableIndex *Index,
+	do func(tree *index.BTree) error,
+) error {
+	indexFile, err := pager.file(tableIndex.Path)
+	if err != nil {
+		return fmt.Errorf("pager.file: %w", err)
+	}
+
+	tree, err := index.Open(indexFile, m.pageSize)
+	if err != nil {
+		return fmt.Errorf("index.Open: %w", err)
+	}
+
+	return do(tree)
+}
+
+// ключ индекса - конкатенация закодированных значений колонок индекса.
+// длинный ключ индекс хранит укороченным, см. index.BoundKey
+func encodeIndexKey(tableIndex *Index, record *Record) ([]byte, error) {
+	key := make([]byte, 0)
+	for _, columnName := range tableIndex.Columns {
+		var path []string
+
+		field, exists := record.ColumnNameToField[columnName]
+		if !exists {
+			var rootName string
+			rootName, path, _ = splitColumnPath(columnName)
+
+			field, exists = record.ColumnNameToField[rootName]
+			if !exists || path == nil {
+				return nil, ErrNoSuchColumnInSchema(columnName)
+			}
+		}
+
+		var (
+			value any
+			err   error
+		)
+		if !field.Null {
+			value, err = deserializeValue(field.Column, field.Value)
+			if err != nil {
+				return nil, fmt.Errorf("deserializeValue: %w", err)
+			}
+		}
+
+		if path != nil {
+			pathValue, found := lookupPath(value, path)
+
+			key, err = appendDocumentKey(key, pathValue, found)
+			if err != nil {
+				return nil, fmt.Errorf("appendDocumentKey: %w", err)
+			}
+			continue
+		}
+
+		key, err = appendColumnKey(key, field.Column, value)
+		if err != nil {
+			return nil, fmt.Errorf("appendColumnKey: %w", err)
+		}
+	}
+
+	return index.BoundKey(key), nil
+}
+
+// кодирует значение колонки так, чтобы побайтовое сравнение
+// ключей совпадало со сравнением значений. у nullable-колонки
+// перед значением байт-признак, и null меньше любого значения
+func appendColumnKey(dst []byte, column *schema.Column, value any) ([]byte, error) {
+	if column.Nullable {
+		if value == nil {
+			return append(dst, nullKeyByte), nil
+		}
+		dst = append(dst, notNullKeyByte)
+	}
+
+	switch column.Type {
+	case schema.Int32Type:
+		v, ok := value.(int32)
+		if !ok {
+			return nil, ErrFailedToCast(column.Type)
+		}
+		return index.AppendInt32Key(dst, v), nil
+	case schema.StringType:
+		v, ok := value.(string)
+		if !ok {
+			return nil, ErrFailedToCast(column.Type)
+		}
+		return index.AppendStringKey(dst, v), nil
+	case schema.BoolType:
+		v, ok := value.(bool)
+		if !ok {
+			return nil, ErrFailedToCast(column.Type)
+		}
+		return index.AppendBoolKey(dst, v), nil
+	case schema.Int64Type:
+		v, ok := value.(int64)
+		if !ok {
+			return nil, ErrFailedToCast(column.Type)
+		}
+		return index.AppendInt64Key(dst, v), nil
+	case schema.Float64Type:
+		v, ok := value.(float64)
+		if !ok {
+			return nil, ErrFailedToCast(column.Type)
+		}
+		return index.AppendFloat64Key(dst, v), nil
+	case schema.TimestampType:
+		v, ok := value.(time.Time)
+		if !ok {
+			return nil, ErrFailedToCast(column.Type)
+		}
+		return index.AppendInt64Key(dst, v.UnixNano()), nil
+	case schema.BytesType:
+		v, ok := value.([]byte)
+		if !ok {
+			return nil, ErrFailedToCast(column.Type)
+		}
+		return index.AppendBytesKey(dst, v), nil
+	case schema.UUIDType:
+		v, ok := value.(uuid.UUID)
+		if !ok {
+			return nil, ErrFailedToCast(column.Type)
+		}
+		return index.AppendFixedKey(dst, v[:]), nil
+	case schema.CharType:
+		// ключ - значение, дополненное до длины колонки,
+		// как оно хранится в записи
+		serialized, err := serializeChar(value, column.Size)
+		if err != nil {
+			return nil, err
+		}
+		return index.AppendFixedKey(dst, serialized), nil
+	case schema.DecimalType:
+		serialized, err := serializeDecimal(value, column)
+		if err != nil {
+			return nil, err
+		}
+		return index.AppendFixedKey(dst, serialized), nil
+	case schema.JSONType:
+		// документы сравниваются только на равенство
+		encoded, err := jsondoc.Encode(value)
+		if err != nil {
+			return nil, fmt.Errorf("jsondoc.Encode: %w", err)
+		}
+		return index.AppendBytesKey(dst, encoded), nil
+	case schema.Int32ArrayType, schema.StringArrayType, schema.BoolArrayType:
+		return appendArrayKey(dst, column, value)
+	case schema.EnumType:
+		// метки упорядочены по объявлению, а не по алфавиту
+		serialized, err := serializeEnum(value, column)
+		if err != nil {
+			return nil, err
+		}
+		return index.AppendFixedKey(dst, serialized), nil
+	default:
+		return nil, ErrUnexpectedType(column.Type)
+	}
+}
+
+func (m *TableManager) getIndexFilePath(tableName, indexName string) string {
+	return fmt.Sprintf("%s%s.%s%s", m.tableDirPath, tableName, indexName, consts.IndexExtension)
+}
@@ -0,0 +1,185 @@
+package table
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/artem-vildanov/small-db/internal/wal"
+)
+
+// при таком размере журнала коммит сбрасывает пул страниц
+// на диск и очищает журнал
+const walCheckpointSize = 16 * 1024 * 1024
+
+/*
+Коммит операции:
+
+0. граница номеров транзакций таблиц сдвигается за txID
+1. образы измененных страниц и запись о коммите дописываются в журнал
+2. страницы переносятся в пул страниц
+3. обновляются метаданные таблиц: количество страниц и статистика
+4. если журнал вырос, пул сбрасывается на диск и журнал очищается
+
+Сбой до конца шага 1 не оставляет следов в файлах.
+Сбой после шага 1 исправляется при следующем InitTableManager.
+*/
+func (m *TableManager) commit(
+	pager *pager,
+	txID uint64,
+	tables []*Table,
+	stats map[string]*TableStats,
+) error {
+	if !pager.hasChanges() {
+		return nil
+	}
+
+	m.commitMu.Lock()
+	defer m.commitMu.Unlock()
+
+	for _, table := range tables {
+		if err := m.reserveTxID(table, txID); err != nil {
+			return fmt.Errorf("TableManager.reserveTxID: %w", err)
+		}
+	}
+
+	if err := pager.logChanges(m.wal, txID); err != nil {
+		return fmt.Errorf("pager.logChanges: %w", err)
+	}
+
+	if err := pager.applyChanges(); err != nil {
+		return fmt.Errorf("pager.applyChanges: %w", err)
+	}
+
+	for _, table := range tables {
+		numPages, err := pager.dataPagesNum(table.Path)
+		if err != nil {
+			return fmt.Errorf("pager.dataPagesNum: %w", err)
+		}
+
+		delta, statsChanged := stats[table.Name]
+		if numPages == table.NumPages && !statsChanged {
+			continue
+		}
+
+		table.NumPages = numPages
+		if statsChanged {
+			table.Stats.add(*delta)
+		}
+
+		if err := m.atomicUpdateMetadata(table); err != nil {
+			return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
+		}
+	}
+
+	walSize, err := m.wal.Size()
+	if err != nil {
+		return fmt.Errorf("Log.Size: %w", err)
+	}
+
+	if walSize < walCheckpointSize {
+		return nil
+	}
+
+	if err := m.checkpoint(); err != nil {
+		return fmt.Errorf("TableManager.checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// сбрасывает на диск страницы, измененные выполненными операциями
+func (m *TableManager) Flush() error {
+	m.commitMu.Lock()
+	defer m.commitMu.Unlock()
+
+	return m.checkpoint()
+}
+
+// записывает грязные страницы пула в файлы и очищает журнал:
+// все, что в нем было, уже на диске. вызывается под commitMu
+func (m *TableManager) checkpoint() error {
+	if err := m.pool.flush(); err != nil {
+		return fmt.Errorf("bufferPool.flush: %w", err)
+	}
+
+	if err := m.wal.Reset(); err != nil {
+		return fmt.Errorf("Log.Reset: %w", err)
+	}
+
+	return nil
+}
+
+// повторно записывает в файлы страницы выполненных операций из журнала.
+// возвращает имена восстановленных файлов
+func (m *TableManager) recover() (map[string]bool, error) {
+	records, err := m.wal.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Log.ReadAll: %w", err)
+	}
+
+	recoveredFiles := make(map[string]bool)
+	descriptors := make(map[string]*os.File)
+	defer func() {
+		for _, descriptor := range descriptors {
+			descriptor.Close()
+		}
+	}()
+
+	for _, record := range wal.CommittedPageImages(records) {
+		descriptor, exists := descriptors[record.File]
+		if !exists {
+			descriptor, err = m.openFile(m.tableDirPath + record.File)
+			if err != nil {
+				return nil, fmt.Errorf("TableManager.openFile: %w", err)
+			}
+			descriptors[record.File] = descriptor
+		}
+
+		if _, err := descriptor.WriteAt(record.Image, record.Offset); err != nil {
+			return nil, fmt.Errorf("File.WriteAt: %w", err)
+		}
+
+		recoveredFiles[record.File] = true
+	}
+
+	for _, descriptor := range descriptors {
+		if err := descriptor.Sync(); err != nil {
+			return nil, fmt.Errorf("File.Sync: %w", err)
+		}
+	}
+
+	return recoveredFiles, nil
+}
+
+// сверяет количество страниц восстановленных таблиц
+// с метаданными и очищает журнал
+func (m *TableManager) finishRecovery(recoveredFiles map[string]bool) error {
+	for _, table := range m.NameToTable {
+		if !recoveredFiles[filepath.Base(table.Path)] {
+			continue
+		}
+
+		fileInfo, err := os.Stat(table.Path)
+		if err != nil {
+			return fmt.Errorf("os.Stat: %w", err)
+		}
+
+		numPages := page.DataPagesNum(fileInfo.Size(), m.pageSize)
+		if numPages == table.NumPages {
+			continue
+		}
+
+		table.NumPages = numPages
+		if err := m.atomicUpdateMetadata(table); err != nil {
+			return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
+		}
+	}
+
+	if err := m.wal.Reset(); err != nil {
+		return fmt.Errorf("Log.Reset: %w", err)
+	}
+
+	return nil
+}
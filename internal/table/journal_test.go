@@ -0,0 +1,133 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/artem-vildanov/small-db/internal/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_Recovery(t *testing.T) {
+	const recordsNum = 10
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+	// перезапуск: дальше работаем с новым экземпляром,
+	// пул страниц старого уже не соответствует файлам
+	reload := func(t *testing.T) *TableManager {
+		reloaded, err := InitTableManager("./", &schema.SchemaManager{
+			IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+		})
+		require.NoError(t, err)
+
+		tableManager = reloaded
+		table = reloaded.NameToTable[tableName]
+		return reloaded
+	}
+
+	// операция успела записать изменения в журнал, но не в файлы
+	insertWithoutApply := func(t *testing.T, id int32) {
+		tx := tableManager.Begin()
+		defer tx.Rollback()
+
+		require.NoError(t, tx.Insert(tableName, map[string]any{
+			"id":     id,
+			"name":   "recovered",
+			"group":  int32(0),
+			"active": true,
+		}))
+
+		require.NoError(t, tableManager.reserveTxID(table, tx.snapshot.txID))
+		require.NoError(t, tx.pager.logChanges(tableManager.wal, tx.snapshot.txID))
+	}
+
+	t.Run("выполненная операция восстанавливается из журнала", func(t *testing.T) {
+		insertWithoutApply(t, 100)
+
+		records, err := tableManager.FindByPredicate(tableName, Eq("id", 100))
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(records))
+
+		reloaded := reload(t)
+
+		record, err := reloaded.GetByPrimaryKey(tableName, map[string]any{"id": 100})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "recovered", name)
+
+		records, err = reloaded.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum+1, len(records))
+
+		walRecords, err := reloaded.wal.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(walRecords))
+	})
+
+	t.Run("повторное восстановление ничего не меняет", func(t *testing.T) {
+		insertWithoutApply(t, 200)
+
+		// сбой во время восстановления: журнал еще не очищен
+		walRecords, err := tableManager.wal.ReadAll()
+		require.NoError(t, err)
+
+		reload(t)
+		require.NoError(t, tableManager.wal.Append(walRecords...))
+		reloaded := reload(t)
+
+		records, err := reloaded.FindByPredicate(tableName, Eq("id", 200))
+		require.NoError(t, err)
+		assert.Equal(t, 1, len(records))
+	})
+
+	t.Run("незавершенная операция отбрасывается", func(t *testing.T) {
+		pager := newPager(tableManager.pool)
+		defer pager.close()
+
+		dataFile, err := pager.file(table.Path)
+		require.NoError(t, err)
+
+		// затираем первую страницу, но коммит не попал в журнал
+		_, err = dataFile.WriteAt(make([]byte, 100), 0)
+		require.NoError(t, err)
+
+		require.NoError(t, tableManager.wal.Append(
+			wal.NewPageImageRecord(tableManager.lastTxID+1, "predicate_table.data", 0, dataFile.dirty[0]),
+		))
+
+		reloaded := reload(t)
+
+		records, err := reloaded.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum+2, len(records))
+	})
+}
+
+func TestTableManager_AtomicUpdate(t *testing.T) {
+	const recordsNum = 10
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	// первая строка успешно обновляется, на второй нарушается
+	// уникальность ключа. ни одна строка не должна измениться
+	err := tableManager.UpdateByPredicate(tableName, Lt("id", 5), func(r map[string]any) {
+		r["id"] = int32(1000)
+	})
+	assert.ErrorContains(t, err, "unique constraint violation on fields: [id]")
+
+	records, err := tableManager.GetAllRecords(tableName)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, getRecordIDs(t, records))
+
+	records, err = tableManager.FindByPredicate(tableName, Eq("id", 1000))
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(records))
+
+	assert.Equal(t, 1, tableManager.NameToTable[tableName].NumPages)
+}
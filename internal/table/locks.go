@@ -0,0 +1,82 @@
+package table
+
+import (
+	"sync"
+	"time"
+)
+
+// сколько транзакция ждет блокировку таблицы на запись.
+// взаимная блокировка двух транзакций разрешается по таймауту
+const defaultLockTimeout = 5 * time.Second
+
+/*
+Блокировки таблицы.
+
+Писатель держит writer от первого изменения таблицы до конца
+транзакции, поэтому страницы таблицы и ее индексов в один момент
+изменяет только одна транзакция. Читатели writer не берут: нужные
+им версии строк они находят по снимку.
+
+files защищает файлы таблицы от чтения в момент, когда коммит
+переносит в них страницы, или вакуум подменяет файл данных.
+*/
+type tableLock struct {
+	writer chan struct{}
+	files  sync.RWMutex
+}
+
+func newTableLock() *tableLock {
+	return &tableLock{
+		writer: make(chan struct{}, 1),
+	}
+}
+
+func (l *tableLock) lockWriter(timeout time.Duration) bool {
+	select {
+	case l.writer <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case l.writer <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (l *tableLock) unlockWriter() {
+	<-l.writer
+}
+
+// таблица и ее блокировки
+func (m *TableManager) getTable(tableName string) (*Table, *tableLock, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	table, exists := m.NameToTable[tableName]
+	if !exists {
+		return nil, nil, ErrTableWithNameDoesntExist(tableName)
+	}
+
+	return table, m.tableLocks[tableName], nil
+}
+
+// блокирует таблицу на запись для операций вне транзакций,
+// например вакуума и создания индекса
+func (m *TableManager) lockTableWriter(tableName string) (*Table, *tableLock, error) {
+	table, lock, err := m.getTable(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !lock.lockWriter(m.lockTimeout) {
+		return nil, nil, ErrLockTimeout(tableName)
+	}
+
+	return table, lock, nil
+}
@@ -0,0 +1,211 @@
+package table
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_Concurrency(t *testing.T) {
+	const (
+		workersNum       = 8
+		insertsPerWorker = 20
+		initialRecords   = 10
+	)
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, initialRecords, "id")
+	defer clear()
+
+	sumGroups := func(t *testing.T, records []*Record) int32 {
+		sum := int32(0)
+		for _, record := range records {
+			group, err := record.GetInt32FieldValue("group")
+			require.NoError(t, err)
+			sum += group
+		}
+		return sum
+	}
+
+	t.Run("параллельные вставки", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, workersNum*(insertsPerWorker+1))
+
+		for worker := 0; worker < workersNum; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for i := 0; i < insertsPerWorker; i++ {
+					id := int32(1000 + worker*insertsPerWorker + i)
+					errs <- tableManager.Insert(tableName, map[string]any{
+						"id":     id,
+						"name":   fmt.Sprintf("name_%04d", id),
+						"group":  int32(0),
+						"active": true,
+					})
+				}
+			}(worker)
+		}
+
+		// читатели работают одновременно с писателями
+		for reader := 0; reader < workersNum; reader++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := tableManager.FindByPredicate(tableName, Gte("id", 1000))
+				errs <- err
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		records, err := tableManager.FindByPredicate(tableName, Gte("id", 1000))
+		require.NoError(t, err)
+		assert.Equal(t, workersNum*insertsPerWorker, len(records))
+
+		// уникальность соблюдается и при параллельных вставках
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":     int32(1000),
+			"name":   "duplicate",
+			"group":  int32(0),
+			"active": true,
+		})
+		assert.ErrorContains(t, err, "unique constraint violation on fields: [id]")
+	})
+
+	t.Run("переводы между строками", func(t *testing.T) {
+		records, err := tableManager.GetAllRecords(tableName)
+		require.NoError(t, err)
+		expectedSum := sumGroups(t, records)
+
+		transfer := func(from, to int32) error {
+			for {
+				tx := tableManager.Begin()
+				err := tx.UpdateByPredicate(tableName, Eq("id", from), func(r map[string]any) {
+					r["group"] = r["group"].(int32) - 1
+				})
+				if err == nil {
+					err = tx.UpdateByPredicate(tableName, Eq("id", to), func(r map[string]any) {
+						r["group"] = r["group"].(int32) + 1
+					})
+				}
+				if err == nil {
+					return tx.Commit()
+				}
+
+				tx.Rollback()
+				// строку изменила транзакция, начатая позже снимка
+				if !strings.Contains(err.Error(), ErrConcurrentUpdate().Error()) {
+					return err
+				}
+			}
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, workersNum*insertsPerWorker)
+
+		for worker := 0; worker < workersNum; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for i := 0; i < insertsPerWorker/2; i++ {
+					from := int32((worker + i) % initialRecords)
+					to := int32((worker + i + 1) % initialRecords)
+					errs <- transfer(from, to)
+				}
+			}(worker)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// каждое чтение видит согласованный снимок
+				records, err := tableManager.GetAllRecords(tableName)
+				if err == nil && sumGroups(t, records) != expectedSum {
+					err = fmt.Errorf("sum of groups changed")
+				}
+				errs <- err
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		records, err = tableManager.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, expectedSum, sumGroups(t, records))
+		assert.Equal(t, initialRecords+workersNum*insertsPerWorker, len(records))
+	})
+
+	t.Run("таймаут блокировки", func(t *testing.T) {
+		tableManager.lockTimeout = 50 * time.Millisecond
+		defer func() { tableManager.lockTimeout = defaultLockTimeout }()
+
+		tx := tableManager.Begin()
+		require.NoError(t, tx.DeleteByPredicate(tableName, Eq("id", 0)))
+
+		err := tableManager.DeleteByPredicate(tableName, Eq("id", 1))
+		assert.EqualError(t, err, fmt.Sprintf(
+			"timeout while waiting for write lock on table %s",
+			tableName,
+		))
+
+		// вакуум тоже ждет писателя
+		assert.Error(t, tableManager.FullVacuum(tableName))
+
+		// чтение не блокируется
+		records, err := tableManager.FindByPredicate(tableName, Lt("id", 2))
+		require.NoError(t, err)
+		assert.Equal(t, []int32{0, 1}, getRecordIDs(t, records))
+
+		require.NoError(t, tx.Rollback())
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Eq("id", 1)))
+	})
+
+	t.Run("вакуум во время чтения", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, workersNum+1)
+
+		for reader := 0; reader < workersNum; reader++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tx := tableManager.Begin()
+				defer tx.Rollback()
+
+				for i := 0; i < 5; i++ {
+					records, err := tx.FindByPredicate(tableName, Lt("id", initialRecords))
+					if err == nil && len(records) != initialRecords-1 {
+						err = fmt.Errorf("unexpected records count %d", len(records))
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- tableManager.FullVacuum(tableName)
+		}()
+
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+	})
+}
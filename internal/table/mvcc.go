@@ -0,0 +1,198 @@
+package table
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+)
+
+/*
+Многоверсионность (MVCC).
+
+Каждая версия строки начинается с заголовка:
+
+Xmin (8) - транзакция, создавшая версию
+Xmax (8) - транзакция, удалившая версию, 0 если версия не удалена
+
+Обновление создает новую версию и проставляет Xmax старой. Изменения
+попадают в файлы только при коммите, поэтому транзакции в заголовках
+на диске всегда выполнены. Транзакция видит версию, если создавшая ее
+транзакция выполнена до снимка, а удалившая - нет. Так долгое чтение
+видит согласованное состояние, пока другие транзакции пишут.
+
+Старые версии остаются в индексах и удаляются вакуумом,
+когда их не видит ни одна транзакция.
+*/
+
+// Xmin (8) + Xmax (8)
+const tupleHeaderSize = 8 + 8
+
+// номера транзакций выдаются с запасом: в метаданных таблицы хранится
+// граница, до которой номера могли попасть в ее файл, и при достижении
+// границы она сдвигается на этот шаг
+const txIDHorizonStep = 1024
+
+// номер, которым помечаются записи, сохраненные до появления
+// заголовков версий. номера транзакций начинаются после него
+const frozenTxID uint64 = 1
+
+type tupleHeader struct {
+	Xmin uint64
+	Xmax uint64
+}
+
+func newTuple(xmin uint64, record []byte) []byte {
+	tuple := make([]byte, tupleHeaderSize, tupleHeaderSize+len(record))
+	binary.BigEndian.PutUint64(tuple[0:], xmin)
+
+	return append(tuple, record...)
+}
+
+func readTupleHeader(tuple []byte) tupleHeader {
+	return tupleHeader{
+		Xmin: binary.BigEndian.Uint64(tuple[0:]),
+		Xmax: binary.BigEndian.Uint64(tuple[8:]),
+	}
+}
+
+// помечает версию удаленной транзакцией txID
+func setTupleXmax(tuple []byte, txID uint64) {
+	binary.BigEndian.PutUint64(tuple[8:], txID)
+}
+
+func tupleRecordData(tuple []byte) []byte {
+	return tuple[tupleHeaderSize:]
+}
+
+// версия не удалена. с такими версиями
+// проверяется уникальность первичного ключа
+func isLive(header tupleHeader) bool {
+	return header.Xmax == 0
+}
+
+// снимок состояния, которое видит транзакция
+type snapshot struct {
+	txID uint64
+	// транзакции с номером не меньше xmax начались после снимка
+	xmax uint64
+	// транзакции, которые выполнялись в момент снимка
+	active map[uint64]bool
+}
+
+// изменения транзакции txID видны в снимке
+func (s *snapshot) sees(txID uint64) bool {
+	if txID == s.txID {
+		return true
+	}
+
+	return txID < s.xmax && !s.active[txID]
+}
+
+func (s *snapshot) visible(header tupleHeader) bool {
+	return s.sees(header.Xmin) && (header.Xmax == 0 || !s.sees(header.Xmax))
+}
+
+func (m *TableManager) beginSnapshot() *snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastTxID++
+
+	active := make(map[uint64]bool, len(m.activeSnapshots))
+	for txID := range m.activeSnapshots {
+		active[txID] = true
+	}
+
+	txSnapshot := &snapshot{
+		txID:   m.lastTxID,
+		xmax:   m.lastTxID,
+		active: active,
+	}
+	m.activeSnapshots[txSnapshot.txID] = txSnapshot
+
+	return txSnapshot
+}
+
+func (m *TableManager) endSnapshot(txSnapshot *snapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.activeSnapshots, txSnapshot.txID)
+}
+
+// версия удалена, и ни одна транзакция ее уже не видит
+func (m *TableManager) isDead(header tupleHeader) bool {
+	if header.Xmax == 0 {
+		return false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, txSnapshot := range m.activeSnapshots {
+		if !txSnapshot.sees(header.Xmax) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// сдвигает границу номеров транзакций таблицы до того,
+// как версии с номером txID попадут в ее файл
+func (m *TableManager) reserveTxID(table *Table, txID uint64) error {
+	if txID <= table.TxIDHorizon {
+		return nil
+	}
+
+	table.TxIDHorizon = txID + txIDHorizonStep
+	if err := m.atomicUpdateMetadata(table); err != nil {
+		return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
+	}
+
+	return nil
+}
+
+// читает заголовок версии строки. exists = false,
+// если слот указателя освобожден
+func readTupleHeaderAt(
+	descriptor page.File,
+	pageOffset int64,
+	pointerIndex int,
+) (header tupleHeader, exists bool, err error) {
+	tupleOffset, exists, err := tupleOffsetAt(descriptor, pageOffset, pointerIndex)
+	if err != nil || !exists {
+		return tupleHeader{}, false, err
+	}
+
+	serializedHeader := make([]byte, tupleHeaderSize)
+	if _, err := descriptor.ReadAt(serializedHeader, tupleOffset); err != nil {
+		return tupleHeader{}, false, fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	return readTupleHeader(serializedHeader), true, nil
+}
+
+// смещение версии строки в файле по текущему указателю на странице
+func tupleOffsetAt(
+	descriptor page.File,
+	pageOffset int64,
+	pointerIndex int,
+) (offset int64, exists bool, err error) {
+	pointerOffset := pageOffset +
+		page.PageHeaderSize +
+		int64(pointerIndex)*page.ItemPointerSize
+
+	// Offset (2) + Size (2) + Status (1)
+	serializedPointer := make([]byte, page.ItemPointerSize)
+	if _, err := descriptor.ReadAt(serializedPointer, pointerOffset); err != nil {
+		return 0, false, fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	if serializedPointer[4] != page.StatusActive {
+		return 0, false, nil
+	}
+
+	return pageOffset + int64(binary.BigEndian.Uint16(serializedPointer)), true, nil
+}
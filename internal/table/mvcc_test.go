@@ -0,0 +1,134 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_Snapshots(t *testing.T) {
+	const recordsNum = 10
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	allIDs := []int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	t.Run("чтение видит снимок на момент начала транзакции", func(t *testing.T) {
+		reader := tableManager.Begin()
+		defer reader.Rollback()
+
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", 1), func(r map[string]any) {
+			r["name"] = "updated"
+		}))
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Eq("id", 2)))
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(100),
+			"name":   "inserted",
+			"group":  int32(0),
+			"active": true,
+		}))
+
+		records, err := reader.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, allIDs, getRecordIDs(t, records))
+
+		record, err := reader.GetByPrimaryKey(tableName, map[string]any{"id": 1})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "name_0001", name)
+
+		records, err = tableManager.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 1, 3, 4, 5, 6, 7, 8, 9, 100}, getRecordIDs(t, records))
+	})
+
+	t.Run("конфликт при изменении строки, измененной после снимка", func(t *testing.T) {
+		tx := tableManager.Begin()
+		defer tx.Rollback()
+
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", 3), func(r map[string]any) {
+			r["name"] = "first"
+		}))
+
+		err := tx.UpdateByPredicate(tableName, Eq("id", 3), func(r map[string]any) {
+			r["name"] = "second"
+		})
+		assert.ErrorContains(t, err, "could not serialize access due to concurrent update")
+
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": 3})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "first", name)
+	})
+
+	t.Run("вакуум сохраняет версии, которые видит транзакция", func(t *testing.T) {
+		require.NoError(t, tableManager.FullVacuum(tableName))
+		assert.Equal(t, 10, countTuples(t, tableManager, tableName))
+
+		reader := tableManager.Begin()
+
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Lt("id", 5)))
+		require.NoError(t, tableManager.FullVacuum(tableName))
+		assert.Equal(t, 10, countTuples(t, tableManager, tableName))
+
+		records, err := reader.FindByPredicate(tableName, Lt("id", 5))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 1, 3, 4}, getRecordIDs(t, records))
+
+		require.NoError(t, reader.Rollback())
+
+		require.NoError(t, tableManager.FullVacuum(tableName))
+		assert.Equal(t, 6, countTuples(t, tableManager, tableName))
+
+		records, err = tableManager.FindByPredicate(tableName, Lt("id", 5))
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(records))
+	})
+
+	t.Run("номера транзакций растут после перезапуска", func(t *testing.T) {
+		table := tableManager.NameToTable[tableName]
+
+		reloaded, err := InitTableManager("./", &schema.SchemaManager{
+			IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+		})
+		require.NoError(t, err)
+
+		tx := reloaded.Begin()
+		defer tx.Rollback()
+		assert.Greater(t, tx.snapshot.txID, tableManager.lastTxID)
+
+		records, err := tx.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, 6, len(records))
+	})
+}
+
+// количество версий строк в файле таблицы
+func countTuples(t *testing.T, tableManager *TableManager, tableName string) int {
+	// страницы читаются через пул: часть изменений может быть еще не на диске
+	dataFile, err := newPager(tableManager.pool).file(tableManager.NameToTable[tableName].Path)
+	require.NoError(t, err)
+
+	iter, err := page.NewPagesIter(dataFile)
+	require.NoError(t, err)
+
+	var count int
+	for iter.Next() {
+		tablePage, err := iter.GetPage()
+		require.NoError(t, err)
+
+		for _, pointer := range tablePage.Pointers {
+			if pointer.Status == page.StatusActive {
+				count++
+			}
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,128 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_Nullable(t *testing.T) {
+	const recordsNum = 20
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+	table.Schema.NameToColumn["group"].Nullable = true
+
+	_, err := tableManager.CreateIndex(tableName, "by_group", []string{"group"})
+	require.NoError(t, err)
+
+	t.Run("вставка null и пропущенного значения", func(t *testing.T) {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(100),
+			"name":   "null_group",
+			"group":  nil,
+			"active": true,
+		}))
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(101),
+			"name":   "missing_group",
+			"active": false,
+		}))
+
+		for _, id := range []int32{100, 101} {
+			record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": id})
+			require.NoError(t, err)
+
+			nameToValue, err := record.IntoNameToValue()
+			require.NoError(t, err)
+			assert.Contains(t, nameToValue, "group")
+			assert.Nil(t, nameToValue["group"])
+
+			_, err = record.GetInt32FieldValue("group")
+			assert.EqualError(t, err, ErrFieldIsNull("group").Error())
+		}
+	})
+
+	t.Run("null в колонке без null", func(t *testing.T) {
+		err := tableManager.Insert(tableName, map[string]any{
+			"id":     int32(102),
+			"name":   nil,
+			"group":  int32(0),
+			"active": true,
+		})
+		assert.ErrorContains(t, err, ErrNotNullViolation("name").Error())
+	})
+
+	t.Run("IS NULL и IS NOT NULL", func(t *testing.T) {
+		records, err := tableManager.FindByPredicate(tableName, IsNull("group"))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{100, 101}, getRecordIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, IsNotNull("group"))
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum, len(records))
+	})
+
+	t.Run("сравнения не выполняются для null", func(t *testing.T) {
+		// в диапазон индекса попадают и ключи null
+		records, err := tableManager.FindByPredicate(tableName, Lt("group", int32(1)))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 10}, getRecordIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Ne("group", int32(0)))
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum-2, len(records))
+	})
+
+	t.Run("обновление в null и обратно", func(t *testing.T) {
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", int32(5)), func(record map[string]any) {
+			record["group"] = nil
+		}))
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", int32(100)), func(record map[string]any) {
+			record["group"] = int32(7)
+		}))
+
+		records, err := tableManager.FindByPredicate(tableName, IsNull("group"))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{5, 101}, getRecordIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Eq("group", int32(7)))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{7, 17, 100}, getRecordIDs(t, records))
+	})
+}
+
+func TestRecord_SerializeNull(t *testing.T) {
+	tableSchema := &schema.Schema{
+		Columns: []*schema.Column{
+			{Name: "id", Type: schema.Int32Type, Size: int(schema.Int32Size)},
+			{Name: "name", Type: schema.StringType, Size: schema.DynamicMemoTypeColumnSize, Nullable: true},
+			{Name: "active", Type: schema.BoolType, Size: int(schema.BoolSize), Nullable: true},
+		},
+	}
+	tableSchema.NameToColumn = make(map[string]*schema.Column, len(tableSchema.Columns))
+	for _, column := range tableSchema.Columns {
+		tableSchema.NameToColumn[column.Name] = column
+	}
+
+	record, err := NewRecordInSchema(tableSchema, map[string]any{
+		"id":   int32(1),
+		"name": nil,
+	})
+	require.NoError(t, err)
+
+	deserialized, err := DeserializeRecordBySchema(tableSchema, record.Serialize(), nil)
+	require.NoError(t, err)
+
+	nameToValue, err := deserialized.IntoNameToValue()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id":     int32(1),
+		"name":   nil,
+		"active": nil,
+	}, nameToValue)
+}
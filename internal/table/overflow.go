@@ -0,0 +1,437 @@
+package table
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/artem-vildanov/small-db/internal/schema"
+)
+
+// размеры в байтах
+const (
+	// Next (8) + ChunkSize (2) + Checksum (4)
+	overflowPageHeaderSize = 8 + 2 + 4
+	overflowChecksumOffset = 8 + 2
+
+	// Next (8) + ChunkSize (2), до версии формата
+	// page.OverflowChecksumFormatVersion
+	legacyOverflowPageHeaderSize = 8 + 2
+
+	// PageOffset (8) + Size (4)
+	overflowPointerSize = 8 + 4
+)
+
+// записи длиннее порога выносят самые большие значения
+// в страницы переполнения, пока не станут короче
+func overflowThreshold(pageSize int) int {
+	return pageSize / 4
+}
+
+func overflowChunkSize(pageSize int) int {
+	return pageSize - overflowPageHeaderSize
+}
+
+// самая длинная запись, которая помещается на пустую страницу
+func maxTupleSize(pageSize int) int {
+	return pageSize - page.PageHeaderSize - page.ItemPointerSize
+}
+
+// старший бит префикса длины значения: вместо значения
+// в записи хранится указатель на страницы переполнения
+const externalValueFlag = 0x8000
+
+/*
+Страницы переполнения таблицы (файл <table>.toast).
+
+Значение, которое не помещается в запись, делится на части по
+overflowChunkSize байт, и каждая часть занимает свою страницу.
+Страницы значения связаны в цепочку: в заголовке страницы смещение
+следующей (0 у последней), размер части и контрольная сумма CRC32C
+заголовка и части. Остаток страницы за частью не читается и суммой
+не покрывается. В записи вместо значения остается указатель:
+смещение первой страницы и длина значения.
+
+Первая страница файла хранит начало списка свободных страниц.
+Вакуум возвращает в него страницы значений удаленных строк,
+и новые значения занимают их раньше, чем конец файла.
+
+Файл изменяется через pager вместе со страницами данных,
+поэтому значения попадают в журнал и откатываются вместе
+со строкой.
+*/
+type overflowFile struct {
+	file     *pagedFile
+	pageSize int
+}
+
+func (m *TableManager) openOverflow(pager *pager, table *Table) (*overflowFile, error) {
+	overflowPath := m.getOverflowFilePath(table.Name)
+
+	file, err := pager.file(overflowPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// файл создается, когда таблица впервые выносит значение
+		descriptor, openErr := os.OpenFile(
+			overflowPath,
+			os.O_CREATE|os.O_RDWR,
+			consts.PosixAccessRight,
+		)
+		if openErr != nil {
+			return nil, fmt.Errorf("os.OpenFile: %w", openErr)
+		}
+		descriptor.Close()
+
+		file, err = pager.file(overflowPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pager.file: %w", err)
+	}
+
+	return &overflowFile{file: file, pageSize: m.pageSize}, nil
+}
+
+// файл переполнения для чтения значений. у таблицы, которая еще
+// не выносила значения, файла нет, и возвращается nil
+func (m *TableManager) overflowReader(pager *pager, table *Table) (io.ReaderAt, error) {
+	file, err := pager.file(m.getOverflowFilePath(table.Name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pager.file: %w", err)
+	}
+
+	return file, nil
+}
+
+// выносит значения записи в страницы переполнения, если она
+// длиннее overflowThreshold, и возвращает сериализованную запись.
+// сама запись не меняется: по ней еще строятся ключи индексов
+func (m *TableManager) serializeRecord(
+	pager *pager,
+	table *Table,
+	record *Record,
+) ([]byte, error) {
+	serialized := record.Serialize()
+	if len(serialized) <= overflowThreshold(m.pageSize) {
+		return serialized, nil
+	}
+
+	overflow, err := m.openOverflow(pager, table)
+	if err != nil {
+		return nil, fmt.Errorf("TableManager.openOverflow: %w", err)
+	}
+
+	toasted := &Record{
+		Fields:            slices.Clone(record.Fields),
+		ColumnNameToField: record.ColumnNameToField,
+	}
+
+	// сначала выносятся самые длинные значения
+	byLength := make([]int, 0, len(toasted.Fields))
+	for i, field := range toasted.Fields {
+		isDynamicMemoType := field.Column.Size == schema.DynamicMemoTypeColumnSize
+		if isDynamicMemoType && len(field.Value) > overflowPointerSize {
+			byLength = append(byLength, i)
+		}
+	}
+	slices.SortStableFunc(byLength, func(a, b int) int {
+		return len(toasted.Fields[b].Value) - len(toasted.Fields[a].Value)
+	})
+
+	size := len(serialized)
+	for _, i := range byLength {
+		if size <= overflowThreshold(m.pageSize) {
+			break
+		}
+
+		field := toasted.Fields[i]
+
+		pointer, err := overflow.write(field.Value)
+		if err != nil {
+			return nil, fmt.Errorf("overflowFile.write: %w", err)
+		}
+
+		toasted.Fields[i] = &Field{
+			Column:          field.Column,
+			Value:           field.Value,
+			overflowPointer: pointer,
+		}
+		size -= len(field.Value) - len(pointer)
+	}
+
+	serialized = toasted.Serialize()
+	if newTupleSize := tupleHeaderSize + len(serialized); newTupleSize > maxTupleSize(m.pageSize) {
+		return nil, ErrRecordTooLarge(table.Name, newTupleSize)
+	}
+
+	return serialized, nil
+}
+
+// возвращает в список свободных страницы вынесенных значений
+func (m *TableManager) freeOverflowValues(
+	pager *pager,
+	table *Table,
+	pointers [][]byte,
+) error {
+	if len(pointers) == 0 {
+		return nil
+	}
+
+	overflow, err := m.openOverflow(pager, table)
+	if err != nil {
+		return fmt.Errorf("TableManager.openOverflow: %w", err)
+	}
+
+	for _, pointer := range pointers {
+		if err := overflow.free(pointer); err != nil {
+			return fmt.Errorf("overflowFile.free: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// записывает значение в цепочку страниц и возвращает указатель на нее
+func (o *overflowFile) write(value []byte) ([]byte, error) {
+	// страницы пишутся с конца значения: так смещение
+	// следующей страницы уже известно
+	var (
+		next      int64
+		chunkSize = overflowChunkSize(o.pageSize)
+	)
+	for end := len(value); end > 0; {
+		start := end - (end-1)%chunkSize - 1
+
+		pageOffset, err := o.allocate()
+		if err != nil {
+			return nil, fmt.Errorf("overflowFile.allocate: %w", err)
+		}
+
+		overflowPage := make([]byte, o.pageSize)
+		copy(overflowPage, serializeOverflowPage(next, value[start:end]))
+
+		if _, err := o.file.WriteAt(overflowPage, pageOffset); err != nil {
+			return nil, fmt.Errorf("File.WriteAt: %w", err)
+		}
+
+		next = pageOffset
+		end = start
+	}
+
+	pointer := make([]byte, overflowPointerSize)
+	binary.BigEndian.PutUint64(pointer[0:], uint64(next))
+	binary.BigEndian.PutUint32(pointer[8:], uint32(len(value)))
+
+	return pointer, nil
+}
+
+// собирает значение из цепочки страниц
+func readOverflowValue(overflow io.ReaderAt, pointer []byte) ([]byte, error) {
+	return readOverflowChain(overflow, pointer, readOverflowPage)
+}
+
+// собирает значение из цепочки страниц без контрольных сумм,
+// записанной до версии формата page.OverflowChecksumFormatVersion
+func readLegacyOverflowValue(overflow io.ReaderAt, pointer []byte) ([]byte, error) {
+	return readOverflowChain(overflow, pointer, readLegacyOverflowPage)
+}
+
+// читает страницу переполнения и возвращает смещение следующей
+// страницы и часть значения не длиннее maxChunkSize
+type overflowPageReader func(overflow io.ReaderAt, pageOffset int64, maxChunkSize int) (int64, []byte, error)
+
+func readOverflowChain(overflow io.ReaderAt, pointer []byte, readPage overflowPageReader) ([]byte, error) {
+	if overflow == nil || len(pointer) != overflowPointerSize {
+		return nil, ErrCorruptedOverflowValue()
+	}
+
+	var (
+		pageOffset = int64(binary.BigEndian.Uint64(pointer[0:]))
+		size       = int(binary.BigEndian.Uint32(pointer[8:]))
+		value      = make([]byte, 0, size)
+	)
+
+	for len(value) < size {
+		if pageOffset == 0 {
+			return nil, ErrCorruptedOverflowValue()
+		}
+
+		// размер страниц здесь неизвестен, поэтому часть
+		// проверяется по самой большой странице
+		next, chunk, err := readPage(overflow, pageOffset, min(overflowChunkSize(page.MaxPageSize), size-len(value)))
+		if err != nil {
+			return nil, fmt.Errorf("overflowPageReader: %w", err)
+		}
+
+		if len(chunk) == 0 {
+			return nil, ErrCorruptedOverflowValue()
+		}
+
+		value = append(value, chunk...)
+		pageOffset = next
+	}
+
+	return value, nil
+}
+
+// заголовок и часть значения страницы с контрольной суммой
+func serializeOverflowPage(next int64, chunk []byte) []byte {
+	serialized := make([]byte, overflowPageHeaderSize+len(chunk))
+	binary.BigEndian.PutUint64(serialized[0:], uint64(next))
+	binary.BigEndian.PutUint16(serialized[8:], uint16(len(chunk)))
+	copy(serialized[overflowPageHeaderSize:], chunk)
+
+	binary.BigEndian.PutUint32(
+		serialized[overflowChecksumOffset:],
+		page.ChecksumExcluding(serialized, overflowChecksumOffset),
+	)
+
+	return serialized
+}
+
+func readOverflowPage(overflow io.ReaderAt, pageOffset int64, maxChunkSize int) (int64, []byte, error) {
+	header := make([]byte, overflowPageHeaderSize)
+	if _, err := overflow.ReadAt(header, pageOffset); err != nil {
+		return 0, nil, fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	chunkSize := int(binary.BigEndian.Uint16(header[8:]))
+	if chunkSize > maxChunkSize {
+		return 0, nil, ErrCorruptedOverflowValue()
+	}
+
+	serialized := make([]byte, overflowPageHeaderSize+chunkSize)
+	copy(serialized, header)
+	if chunkSize != 0 {
+		if _, err := overflow.ReadAt(serialized[overflowPageHeaderSize:], pageOffset+overflowPageHeaderSize); err != nil {
+			return 0, nil, fmt.Errorf("File.ReadAt: %w", err)
+		}
+	}
+
+	stored := binary.BigEndian.Uint32(serialized[overflowChecksumOffset:])
+	if computed := page.ChecksumExcluding(serialized, overflowChecksumOffset); stored != computed {
+		return 0, nil, ErrCorruptedOverflowPage(pageOffset, stored, computed)
+	}
+
+	return int64(binary.BigEndian.Uint64(header[0:])), serialized[overflowPageHeaderSize:], nil
+}
+
+func readLegacyOverflowPage(overflow io.ReaderAt, pageOffset int64, maxChunkSize int) (int64, []byte, error) {
+	header := make([]byte, legacyOverflowPageHeaderSize)
+	if _, err := overflow.ReadAt(header, pageOffset); err != nil {
+		return 0, nil, fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	chunkSize := int(binary.BigEndian.Uint16(header[8:]))
+	if chunkSize > maxChunkSize {
+		return 0, nil, ErrCorruptedOverflowValue()
+	}
+
+	chunk := make([]byte, chunkSize)
+	if _, err := overflow.ReadAt(chunk, pageOffset+legacyOverflowPageHeaderSize); err != nil {
+		return 0, nil, fmt.Errorf("File.ReadAt: %w", err)
+	}
+
+	return int64(binary.BigEndian.Uint64(header[0:])), chunk, nil
+}
+
+// возвращает страницы значения в список свободных
+func (o *overflowFile) free(pointer []byte) error {
+	if len(pointer) != overflowPointerSize {
+		return ErrCorruptedOverflowValue()
+	}
+
+	freeHead, err := o.readNext(0)
+	if err != nil {
+		return fmt.Errorf("overflowFile.readNext: %w", err)
+	}
+
+	for pageOffset := int64(binary.BigEndian.Uint64(pointer)); pageOffset != 0; {
+		next, err := o.readNext(pageOffset)
+		if err != nil {
+			return fmt.Errorf("overflowFile.readNext: %w", err)
+		}
+
+		if err := o.writeNext(pageOffset, freeHead); err != nil {
+			return fmt.Errorf("overflowFile.writeNext: %w", err)
+		}
+
+		freeHead = pageOffset
+		pageOffset = next
+	}
+
+	if err := o.writeNext(0, freeHead); err != nil {
+		return fmt.Errorf("overflowFile.writeNext: %w", err)
+	}
+
+	return nil
+}
+
+// смещение страницы под новую часть значения: свободной
+// или новой в конце файла
+func (o *overflowFile) allocate() (int64, error) {
+	// первая страница файла занята списком свободных
+	if o.file.size == 0 {
+		freeListPage := make([]byte, o.pageSize)
+		copy(freeListPage, serializeOverflowPage(0, nil))
+
+		if _, err := o.file.WriteAt(freeListPage, 0); err != nil {
+			return 0, fmt.Errorf("File.WriteAt: %w", err)
+		}
+	}
+
+	freeHead, err := o.readNext(0)
+	if err != nil {
+		return 0, fmt.Errorf("overflowFile.readNext: %w", err)
+	}
+
+	if freeHead == 0 {
+		return o.file.size, nil
+	}
+
+	next, err := o.readNext(freeHead)
+	if err != nil {
+		return 0, fmt.Errorf("overflowFile.readNext: %w", err)
+	}
+
+	if err := o.writeNext(0, next); err != nil {
+		return 0, fmt.Errorf("overflowFile.writeNext: %w", err)
+	}
+
+	return freeHead, nil
+}
+
+// у первой страницы файла это начало списка свободных страниц,
+// у остальных - следующая страница цепочки
+func (o *overflowFile) readNext(pageOffset int64) (int64, error) {
+	if pageOffset >= o.file.size {
+		return 0, nil
+	}
+
+	next, _, err := readOverflowPage(o.file, pageOffset, overflowChunkSize(o.pageSize))
+	if err != nil {
+		return 0, fmt.Errorf("readOverflowPage: %w", err)
+	}
+
+	return next, nil
+}
+
+// записывает смещение следующей страницы списка свободных.
+// часть значения на странице после этого не хранится
+func (o *overflowFile) writeNext(pageOffset int64, next int64) error {
+	if _, err := o.file.WriteAt(serializeOverflowPage(next, nil), pageOffset); err != nil {
+		return fmt.Errorf("File.WriteAt: %w", err)
+	}
+
+	return nil
+}
+
+func (m *TableManager) getOverflowFilePath(tableName string) string {
+	return fmt.Sprintf("%s%s%s", m.tableDirPath, tableName, consts.OverflowExtension)
+}
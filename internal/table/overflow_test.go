@@ -0,0 +1,145 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_Overflow(t *testing.T) {
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, 3, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+
+	getName := func(t *testing.T, id int32) string {
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": id})
+		require.NoError(t, err)
+
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		return name
+	}
+
+	overflowPages := func(t *testing.T) int64 {
+		fileInfo, err := tableManager.pool.stat(tableManager.getOverflowFilePath(tableName))
+		require.NoError(t, err)
+		return fileInfo.Size() / page.DefaultPageSize
+	}
+
+	// значение на несколько страниц и длиннее, чем помещается в префикс длины
+	longName := strings.Repeat("0123456789", 10_000)
+
+	t.Run("длинное значение выносится в страницы переполнения", func(t *testing.T) {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(10),
+			"name":   longName,
+			"group":  int32(0),
+			"active": true,
+		}))
+
+		assert.Equal(t, longName, getName(t, 10))
+		assert.Equal(t, 1, table.NumPages)
+
+		// первая страница файла - список свободных
+		chunks := int64((len(longName) + overflowChunkSize(page.DefaultPageSize) - 1) / overflowChunkSize(page.DefaultPageSize))
+		assert.Equal(t, chunks+1, overflowPages(t))
+
+		records, err := tableManager.FindByPredicate(tableName, Eq("name", longName))
+		require.NoError(t, err)
+		assert.Equal(t, []int32{10}, getRecordIDs(t, records))
+	})
+
+	t.Run("откат не оставляет значений", func(t *testing.T) {
+		pagesBefore := overflowPages(t)
+
+		tx := tableManager.Begin()
+		require.NoError(t, tx.Insert(tableName, map[string]any{
+			"id":     int32(11),
+			"name":   longName,
+			"group":  int32(0),
+			"active": true,
+		}))
+		require.NoError(t, tx.Rollback())
+
+		assert.Equal(t, pagesBefore, overflowPages(t))
+
+		_, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(11)})
+		assert.EqualError(t, err, ErrRecordNotFound().Error())
+	})
+
+	t.Run("вакуум освобождает страницы значений", func(t *testing.T) {
+		// пока новая версия не записана, старая занимает свои страницы
+		var pagesBefore int64
+
+		for i := 0; i < 4; i++ {
+			updatedName := strings.Repeat(string(rune('a'+i)), len(longName))
+			require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", 10), func(r map[string]any) {
+				r["name"] = updatedName
+			}))
+			require.NoError(t, tableManager.ConcurrentVacuum(tableName))
+			assert.Equal(t, updatedName, getName(t, 10))
+
+			// новые версии занимают страницы удаленных
+			if i == 0 {
+				pagesBefore = overflowPages(t)
+			}
+			assert.Equal(t, pagesBefore, overflowPages(t))
+		}
+
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", 10), func(r map[string]any) {
+			r["name"] = longName
+		}))
+		require.NoError(t, tableManager.FullVacuum(tableName))
+		assert.Equal(t, pagesBefore, overflowPages(t))
+		assert.Equal(t, longName, getName(t, 10))
+
+		records, err := tableManager.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 1, 2, 10}, getRecordIDs(t, records))
+	})
+
+	t.Run("поврежденная страница значения", func(t *testing.T) {
+		require.NoError(t, tableManager.Flush())
+
+		overflowPath := tableManager.getOverflowFilePath(tableName)
+		original, err := os.ReadFile(overflowPath)
+		require.NoError(t, err)
+
+		// портим первый байт части значения на каждой странице с частью
+		corrupted := bytes.Clone(original)
+		for pageOffset := page.DefaultPageSize; pageOffset < len(corrupted); pageOffset += page.DefaultPageSize {
+			if binary.BigEndian.Uint16(corrupted[pageOffset+8:]) != 0 {
+				corrupted[pageOffset+overflowPageHeaderSize] ^= 0xFF
+			}
+		}
+		require.NoError(t, os.WriteFile(overflowPath, corrupted, consts.PosixAccessRight))
+		tableManager.pool.invalidate(overflowPath)
+
+		_, err = tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(10)})
+		assert.ErrorContains(t, err, "overflow page at offset")
+		assert.ErrorContains(t, err, "is corrupted")
+
+		require.NoError(t, os.WriteFile(overflowPath, original, consts.PosixAccessRight))
+		tableManager.pool.invalidate(overflowPath)
+
+		assert.Equal(t, longName, getName(t, 10))
+	})
+
+	t.Run("запись, которая не помещается даже без значений", func(t *testing.T) {
+		_, err := tableManager.serializeRecord(newPager(tableManager.pool), table, &Record{
+			Fields: []*Field{{
+				Column: table.Schema.NameToColumn["id"],
+				Value:  make([]byte, maxTupleSize(page.DefaultPageSize)),
+			}},
+		})
+		assert.EqualError(t, err, ErrRecordTooLarge(tableName, tupleHeaderSize+nullBitmapSize(1)+maxTupleSize(page.DefaultPageSize)).Error())
+	})
+}
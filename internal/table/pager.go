@@ -0,0 +1,269 @@
+package table
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+	"github.com/artem-vildanov/small-db/internal/wal"
+)
+
+/*
+Страницы файлов таблиц, измененные операцией.
+
+Операция (вставка, обновление, удаление) читает и пишет файлы данных
+и индексов через pager. Неизмененные страницы читаются из общего пула,
+измененные держатся в памяти операции и попадают в пул только при
+коммите, после того как их образы записаны в журнал предзаписи.
+Если операция прервалась до коммита, другие операции ее изменений
+не видят.
+*/
+type pager struct {
+	pool  *bufferPool
+	files map[string]*pagedFile
+}
+
+func newPager(pool *bufferPool) *pager {
+	return &pager{
+		pool:  pool,
+		files: make(map[string]*pagedFile),
+	}
+}
+
+// файл в представлении операции: при чтении видны ее изменения
+func (p *pager) file(path string) (*pagedFile, error) {
+	if file, exists := p.files[path]; exists {
+		return file, nil
+	}
+
+	fileInfo, err := p.pool.stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("bufferPool.stat: %w", err)
+	}
+
+	file := &pagedFile{
+		pool:          p.pool,
+		path:          path,
+		dirty:         make(map[int64][]byte),
+		committedSize: fileInfo.Size(),
+		size:          fileInfo.Size(),
+	}
+	p.files[path] = file
+
+	return file, nil
+}
+
+func (p *pager) hasChanges() bool {
+	for _, file := range p.files {
+		if len(file.dirty) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// записывает образы измененных страниц и запись о коммите в журнал.
+// после этого изменения переживут сбой
+func (p *pager) logChanges(log *wal.Log, txID uint64) error {
+	records := make([]*wal.Record, 0)
+
+	for _, path := range p.paths() {
+		file := p.files[path]
+		for _, pageOffset := range file.dirtyOffsets() {
+			records = append(records, wal.NewPageImageRecord(
+				txID,
+				filepath.Base(path),
+				pageOffset,
+				file.dirty[pageOffset],
+			))
+		}
+	}
+
+	records = append(records, wal.NewCommitRecord(txID))
+
+	if err := log.Append(records...); err != nil {
+		return fmt.Errorf("Log.Append: %w", err)
+	}
+
+	return nil
+}
+
+// переносит измененные страницы в пул, откуда их видят другие операции
+func (p *pager) applyChanges() error {
+	for _, path := range p.paths() {
+		file := p.files[path]
+		if len(file.dirty) == 0 {
+			continue
+		}
+
+		for _, pageOffset := range file.dirtyOffsets() {
+			if err := p.pool.write(path, pageOffset, file.dirty[pageOffset]); err != nil {
+				return fmt.Errorf("bufferPool.write: %w", err)
+			}
+		}
+
+		file.dirty = make(map[int64][]byte)
+		file.committedSize = file.size
+	}
+
+	return nil
+}
+
+// количество страниц данных в файле с учетом изменений операции
+func (p *pager) dataPagesNum(path string) (int, error) {
+	file, err := p.file(path)
+	if err != nil {
+		return 0, fmt.Errorf("pager.file: %w", err)
+	}
+
+	return page.DataPagesNum(file.size, p.pool.pageSize), nil
+}
+
+// забывает файлы без изменений. при следующем обращении
+// их размер будет прочитан заново
+func (p *pager) closeClean() {
+	for path, file := range p.files {
+		if len(file.dirty) == 0 {
+			delete(p.files, path)
+		}
+	}
+}
+
+func (p *pager) close() {
+	clear(p.files)
+}
+
+func (p *pager) paths() []string {
+	paths := make([]string, 0, len(p.files))
+	for path := range p.files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	return paths
+}
+
+// реализует page.File поверх пула страниц
+// и страниц, измененных операцией
+type pagedFile struct {
+	pool *bufferPool
+	path string
+	// смещение страницы -> образ страницы
+	dirty map[int64][]byte
+	// размер файла без изменений операции
+	committedSize int64
+	// размер файла с учетом изменений
+	size int64
+}
+
+func (f *pagedFile) ReadAt(buffer []byte, offset int64) (int, error) {
+	read := 0
+	pageSize := int64(f.pool.pageSize)
+	for read < len(buffer) {
+		current := offset + int64(read)
+		if current >= f.size {
+			return read, io.EOF
+		}
+
+		pageOffset := current - current%pageSize
+
+		if image, exists := f.dirty[pageOffset]; exists {
+			read += copy(buffer[read:], image[current-pageOffset:])
+			continue
+		}
+
+		// страница добавлена операцией, но еще не записана
+		if pageOffset >= f.committedSize {
+			tail := min(len(buffer)-read, int(pageOffset+pageSize-current))
+			clear(buffer[read : read+tail])
+			read += tail
+			continue
+		}
+
+		// страницу из пула копируем сразу в буфер, без промежуточной копии
+
+		if err := f.pool.read(f.path, pageOffset, func(image []byte) {
+			read += copy(buffer[read:], image[current-pageOffset:])
+		}); err != nil {
+			return read, fmt.Errorf("bufferPool.read: %w", err)
+		}
+	}
+
+	return read, nil
+}
+
+func (f *pagedFile) WriteAt(data []byte, offset int64) (int, error) {
+	pageSize := int64(f.pool.pageSize)
+	written := 0
+	for written < len(data) {
+		current := offset + int64(written)
+		pageOffset := current - current%pageSize
+
+		image, err := f.readPage(pageOffset)
+		if err != nil {
+			return written, err
+		}
+
+		written += copy(image[current-pageOffset:], data[written:])
+		f.dirty[pageOffset] = image
+
+		if end := offset + int64(written); end > f.size {
+			f.size = end
+		}
+	}
+
+	return written, nil
+}
+
+func (f *pagedFile) Stat() (os.FileInfo, error) {
+	fileInfo, err := f.pool.stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pagedFileInfo{FileInfo: fileInfo, size: f.size}, nil
+}
+
+// образ страницы, который можно изменять: либо уже измененный
+// операцией, либо копия страницы из пула
+func (f *pagedFile) readPage(pageOffset int64) ([]byte, error) {
+	if image, exists := f.dirty[pageOffset]; exists {
+		return image, nil
+	}
+
+	image := make([]byte, f.pool.pageSize)
+	if pageOffset >= f.committedSize {
+		return image, nil
+	}
+
+	if err := f.pool.read(f.path, pageOffset, func(pooled []byte) {
+		copy(image, pooled)
+	}); err != nil {
+		return nil, fmt.Errorf("bufferPool.read: %w", err)
+	}
+
+	return image, nil
+}
+
+func (f *pagedFile) dirtyOffsets() []int64 {
+	offsets := make([]int64, 0, len(f.dirty))
+	for pageOffset := range f.dirty {
+		offsets = append(offsets, pageOffset)
+	}
+	slices.Sort(offsets)
+
+	return offsets
+}
+
+type pagedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (i *pagedFileInfo) Size() int64 {
+	return i.size
+}
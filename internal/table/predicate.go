@@ -0,0 +1,357 @@
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/artem-vildanov/small-db/internal/jsondoc"
+	"github.com/artem-vildanov/small-db/internal/schema"
+)
+
+type Operator string
+
+const (
+	OpEq  Operator = "="
+	OpNe  Operator = "!="
+	OpLt  Operator = "<"
+	OpLte Operator = "<="
+	OpGt  Operator = ">"
+	OpGte Operator = ">="
+
+	OpIsNull    Operator = "IS NULL"
+	OpIsNotNull Operator = "IS NOT NULL"
+
+	// условия на элементы колонки-массива
+	OpContains Operator = "CONTAINS"
+	OpAny      Operator = "ANY"
+
+	OpAnd Operator = "AND"
+	OpOr  Operator = "OR"
+
+	// произвольное условие в виде замыкания.
+	// такое условие нельзя выполнить через индекс
+	OpFunc Operator = "FUNC"
+)
+
+/*
+Предикат для поиска строк.
+
+В отличие от замыкания, структуру предиката можно разобрать:
+условия на равенство и диапазон по индексированным колонкам
+выполняются через индекс, без полного обхода таблицы.
+
+Колонкой может быть путь в документе json, например payload.user.id.
+Строки, в документе которых нет значения по пути, ведут себя как null.
+*/
+type Predicate struct {
+	Column   string
+	Operator Operator
+	Value    any
+	// операнды для AND и OR
+	Operands []*Predicate
+	// условие для OpFunc
+	Match func(record map[string]any) bool
+}
+
+func Eq(column string, value any) *Predicate {
+	return &Predicate{Column: column, Operator: OpEq, Value: value}
+}
+
+func Ne(column string, value any) *Predicate {
+	return &Predicate{Column: column, Operator: OpNe, Value: value}
+}
+
+func Lt(column string, value any) *Predicate {
+	return &Predicate{Column: column, Operator: OpLt, Value: value}
+}
+
+func Lte(column string, value any) *Predicate {
+	return &Predicate{Column: column, Operator: OpLte, Value: value}
+}
+
+func Gt(column string, value any) *Predicate {
+	return &Predicate{Column: column, Operator: OpGt, Value: value}
+}
+
+func Gte(column string, value any) *Predicate {
+	return &Predicate{Column: column, Operator: OpGte, Value: value}
+}
+
+// сравнения с null не выполняются ни для одной строки,
+// null-значения ищутся только этими условиями
+func IsNull(column string) *Predicate {
+	return &Predicate{Column: column, Operator: OpIsNull}
+}
+
+func IsNotNull(column string) *Predicate {
+	return &Predicate{Column: column, Operator: OpIsNotNull}
+}
+
+// массив содержит все значения
+func Contains(column string, values ...any) *Predicate {
+	return &Predicate{Column: column, Operator: OpContains, Value: values}
+}
+
+// массив содержит хотя бы одно из значений
+func Any(column string, values ...any) *Predicate {
+	return &Predicate{Column: column, Operator: OpAny, Value: values}
+}
+
+func And(operands ...*Predicate) *Predicate {
+	return &Predicate{Operator: OpAnd, Operands: operands}
+}
+
+func Or(operands ...*Predicate) *Predicate {
+	return &Predicate{Operator: OpOr, Operands: operands}
+}
+
+func Func(match func(record map[string]any) bool) *Predicate {
+	return &Predicate{Operator: OpFunc, Match: match}
+}
+
+func (p *Predicate) isComparison() bool {
+	switch p.Operator {
+	case OpEq, OpNe, OpLt, OpLte, OpGt, OpGte:
+		return true
+	default:
+		return false
+	}
+}
+
+// предикат, проверенный по схеме таблицы.
+// значение для сравнения заранее закодировано в ключ
+type boundPredicate struct {
+	*Predicate
+	column *schema.Column
+	// путь в документе json, если условие на значение внутри него
+	path     []string
+	valueKey []byte
+	// ключи значений для условий на элементы массива
+	elementKeys [][]byte
+	operands    []*boundPredicate
+}
+
+func (p *Predicate) bind(tableSchema *schema.Schema) (*boundPredicate, error) {
+	bound := &boundPredicate{Predicate: p}
+
+	switch {
+	case p.isComparison():
+		column, path, err := resolveColumn(tableSchema, p.Column)
+		if err != nil {
+			return nil, err
+		}
+
+		valueKey, err := bindValueKey(column, path, p.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		bound.column = column
+		bound.path = path
+		bound.valueKey = valueKey
+	case p.Operator == OpIsNull || p.Operator == OpIsNotNull:
+		column, path, err := resolveColumn(tableSchema, p.Column)
+		if err != nil {
+			return nil, err
+		}
+
+		bound.column = column
+		bound.path = path
+	case p.Operator == OpContains || p.Operator == OpAny:
+		column, path, err := resolveColumn(tableSchema, p.Column)
+		if err != nil {
+			return nil, err
+		}
+
+		element, isArray := elementColumn(column)
+		if !isArray || path != nil {
+			return nil, ErrNotArrayColumn(p.Column, column.Type)
+		}
+
+		values, ok := schema.ArrayElements(p.Value)
+		if !ok {
+			return nil, ErrInvalidPredicate(p.Operator)
+		}
+
+		for _, raw := range values {
+			value, err := normalizeValue(element, raw)
+			if err != nil {
+				return nil, fmt.Errorf("normalizeValue: %w", err)
+			}
+
+			key, err := appendColumnKey(nil, element, value)
+			if err != nil {
+				return nil, fmt.Errorf("appendColumnKey: %w", err)
+			}
+
+			bound.elementKeys = append(bound.elementKeys, key)
+		}
+
+		bound.column = column
+	case p.Operator == OpAnd || p.Operator == OpOr:
+		for _, operand := range p.Operands {
+			boundOperand, err := operand.bind(tableSchema)
+			if err != nil {
+				return nil, err
+			}
+
+			bound.operands = append(bound.operands, boundOperand)
+		}
+	case p.Operator == OpFunc:
+		if p.Match == nil {
+			return nil, ErrInvalidPredicate(p.Operator)
+		}
+	default:
+		return nil, ErrInvalidPredicate(p.Operator)
+	}
+
+	return bound, nil
+}
+
+func (p *boundPredicate) match(record map[string]any) (bool, error) {
+	switch p.Operator {
+	case OpAnd:
+		for _, operand := range p.operands {
+			matched, err := operand.match(record)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	case OpOr:
+		for _, operand := range p.operands {
+			matched, err := operand.match(record)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	case OpFunc:
+		return p.Match(record), nil
+	}
+
+	value := record[p.column.Name]
+	if p.path != nil {
+		value, _ = lookupPath(value, p.path)
+	}
+
+	switch p.Operator {
+	case OpIsNull:
+		return value == nil, nil
+	case OpIsNotNull:
+		return value != nil, nil
+	}
+
+	if value == nil {
+		return false, nil
+	}
+
+	if p.Operator == OpContains || p.Operator == OpAny {
+		return p.matchElements(value)
+	}
+
+	var (
+		key []byte
+		err error
+	)
+	if p.path != nil {
+		key, err = appendDocumentKey(nil, value, true)
+	} else {
+		key, err = appendColumnKey(nil, p.column, value)
+	}
+	if err != nil {
+		return false, fmt.Errorf("appendKey: %w", err)
+	}
+
+	// значения разных типов в документах не сравниваются
+	if p.path != nil && key[0] != p.valueKey[0] {
+		return p.Operator == OpNe, nil
+	}
+
+	cmp := bytes.Compare(key, p.valueKey)
+
+	switch p.Operator {
+	case OpEq:
+		return cmp == 0, nil
+	case OpNe:
+		return cmp != 0, nil
+	case OpLt:
+		return cmp < 0, nil
+	case OpLte:
+		return cmp <= 0, nil
+	case OpGt:
+		return cmp > 0, nil
+	case OpGte:
+		return cmp >= 0, nil
+	default:
+		return false, ErrInvalidPredicate(p.Operator)
+	}
+}
+
+func (p *boundPredicate) matchElements(array any) (bool, error) {
+	keys, err := arrayElementKeys(p.column, array)
+	if err != nil {
+		return false, fmt.Errorf("arrayElementKeys: %w", err)
+	}
+
+	for _, valueKey := range p.elementKeys {
+		found := slices.ContainsFunc(keys, func(key []byte) bool {
+			return bytes.Equal(key, valueKey)
+		})
+
+		// для CONTAINS нужны все значения, для ANY - хотя бы одно
+		if found == (p.Operator == OpAny) {
+			return found, nil
+		}
+	}
+
+	return p.Operator == OpContains, nil
+}
+
+// ключ значения, с которым сравнивается колонка или значение
+// по пути в документе
+func bindValueKey(column *schema.Column, path []string, raw any) ([]byte, error) {
+	if path != nil {
+		value, err := jsondoc.FromGo(raw)
+		if err != nil {
+			return nil, fmt.Errorf("jsondoc.FromGo: %w", err)
+		}
+
+		return appendDocumentKey(nil, value, true)
+	}
+
+	value, err := normalizeValue(column, raw)
+	if err != nil {
+		return nil, fmt.Errorf("normalizeValue: %w", err)
+	}
+
+	valueKey, err := appendColumnKey(nil, column, value)
+	if err != nil {
+		return nil, fmt.Errorf("appendColumnKey: %w", err)
+	}
+
+	return valueKey, nil
+}
+
+// значение по пути в документе json для условий FindByCondition.
+// путь начинается с имени колонки, как в предикатах
+func LookupPath(record map[string]any, path string) (any, bool) {
+	columnName, documentPath, isPath := splitColumnPath(path)
+	value, exists := record[columnName]
+	if !exists || !isPath {
+		return value, exists
+	}
+
+	return lookupPath(value, documentPath)
+}
+
+// приводит значение к типу колонки, например int к int32
+func normalizeValue(column *schema.Column, raw any) (any, error) {
+	serialized, err := serializeValue(column, raw)
+	if err != nil {
+		return nil, fmt.Errorf("serializeValue: %w", err)
+	}
+
+	return deserializeValue(column, serialized)
+}
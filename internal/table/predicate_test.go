@@ -0,0 +1,281 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_FindByPredicate(t *testing.T) {
+	const recordsNum = 500
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum)
+	defer clear()
+
+	_, err := tableManager.CreateIndex(tableName, "by_id", []string{"id"})
+	require.NoError(t, err)
+
+	_, err = tableManager.CreateIndex(tableName, "by_group_name", []string{"group", "name"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		predicate   *Predicate
+		expectedIDs []int32
+		usesIndex   string
+	}{
+		{
+			name:        "равенство по индексу",
+			predicate:   Eq("id", 42),
+			expectedIDs: []int32{42},
+			usesIndex:   "by_id",
+		},
+		{
+			name:        "диапазон по индексу",
+			predicate:   And(Gte("id", 10), Lt("id", 15)),
+			expectedIDs: []int32{10, 11, 12, 13, 14},
+			usesIndex:   "by_id",
+		},
+		{
+			name:        "строгие границы",
+			predicate:   And(Gt("id", 497), Lte("id", 1000)),
+			expectedIDs: []int32{498, 499},
+			usesIndex:   "by_id",
+		},
+		{
+			name:        "префикс составного индекса",
+			predicate:   And(Eq("group", 3), Lt("name", "name_0023")),
+			expectedIDs: []int32{3, 13},
+			usesIndex:   "by_group_name",
+		},
+		{
+			name:        "OR по индексам",
+			predicate:   Or(Eq("id", 1), Eq("id", 7), Eq("id", 1)),
+			expectedIDs: []int32{1, 7},
+		},
+		{
+			name:        "условие без индекса",
+			predicate:   And(Eq("active", true), Lt("id", 5)),
+			expectedIDs: []int32{0, 2, 4},
+			usesIndex:   "by_id",
+		},
+		{
+			name:        "полный обход",
+			predicate:   And(Eq("active", false), Ne("name", "name_0001"), Lt("name", "name_0006")),
+			expectedIDs: []int32{3, 5},
+		},
+		{
+			name: "замыкание",
+			predicate: Func(func(r map[string]any) bool {
+				return r["id"].(int32)%100 == 0
+			}),
+			expectedIDs: []int32{0, 100, 200, 300, 400},
+		},
+		{
+			name:        "ничего не найдено",
+			predicate:   Eq("id", -1),
+			expectedIDs: []int32{},
+			usesIndex:   "by_id",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			table := tableManager.NameToTable[tableName]
+
+			bound, err := tc.predicate.bind(table.Schema)
+			require.NoError(t, err)
+
+			scans := chooseIndexScans(table, bound)
+			if tc.usesIndex != "" {
+				require.Equal(t, 1, len(scans))
+				assert.Equal(t, tc.usesIndex, scans[0].Index.Name)
+			}
+
+			gotRecords, err := tableManager.FindByPredicate(tableName, tc.predicate)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedIDs, getRecordIDs(t, gotRecords))
+		})
+	}
+
+	t.Run("несуществующая колонка", func(t *testing.T) {
+		_, err := tableManager.FindByPredicate(tableName, Eq("unknown", 1))
+		assert.EqualError(t, err, "doByCondition: Predicate.bind: no column with name unknown in schema")
+	})
+
+	t.Run("неподходящий тип значения", func(t *testing.T) {
+		_, err := tableManager.FindByPredicate(tableName, Eq("id", "1"))
+		assert.EqualError(
+			t,
+			err,
+			"doByCondition: Predicate.bind: normalizeValue: serializeValue: failed to serialize int32 value",
+		)
+	})
+}
+
+func TestTableManager_IndexMaintenance(t *testing.T) {
+	const recordsNum = 100
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum)
+	defer clear()
+
+	_, err := tableManager.CreateIndex(tableName, "by_id", []string{"id"})
+	require.NoError(t, err)
+
+	require.NoError(t, tableManager.DeleteByPredicate(tableName, Lt("id", 50)))
+
+	require.NoError(t, tableManager.UpdateByPredicate(
+		tableName,
+		Gte("id", 90),
+		func(r map[string]any) {
+			r["id"] = r["id"].(int32) + 1000
+		},
+	))
+
+	require.NoError(t, tableManager.Insert(tableName, map[string]any{
+		"id":     int32(5),
+		"name":   "name_new",
+		"group":  int32(0),
+		"active": true,
+	}))
+
+	assertFound := func(t *testing.T, manager *TableManager) {
+		gotRecords, err := manager.FindByPredicate(tableName, Lt("id", 60))
+		require.NoError(t, err)
+		assert.Equal(t, []int32{50, 51, 52, 53, 54, 55, 56, 57, 58, 59, 5}, getRecordIDs(t, gotRecords))
+
+		gotRecords, err = manager.FindByPredicate(tableName, Gte("id", 1000))
+		require.NoError(t, err)
+		assert.Equal(t, 10, len(gotRecords))
+
+		gotRecords, err = manager.FindByPredicate(tableName, Eq("id", 95))
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(gotRecords))
+	}
+
+	t.Run("индекс обновляется при изменениях", func(t *testing.T) {
+		assertFound(t, tableManager)
+	})
+
+	t.Run("индекс загружается с диска", func(t *testing.T) {
+		table := tableManager.NameToTable[tableName]
+		reloaded, err := InitTableManager("./", &schema.SchemaManager{
+			IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, len(reloaded.NameToTable[tableName].Indexes))
+		assert.Equal(t, table.Indexes[0], reloaded.NameToTable[tableName].Indexes[0])
+
+		assertFound(t, reloaded)
+	})
+
+	t.Run("индекс перестраивается после вакуума", func(t *testing.T) {
+		require.NoError(t, tableManager.FullVacuum(tableName))
+		assertFound(t, tableManager)
+	})
+
+	t.Run("индекс с таким именем уже есть", func(t *testing.T) {
+		_, err := tableManager.CreateIndex(tableName, "by_id", []string{"name"})
+		assert.EqualError(t, err, "index with name by_id already exists")
+	})
+
+	t.Run("индекс по несуществующей колонке", func(t *testing.T) {
+		_, err := tableManager.CreateIndex(tableName, "by_unknown", []string{"unknown"})
+		assert.EqualError(t, err, "no column with name unknown in schema")
+	})
+}
+
+func getRecordIDs(t *testing.T, records []*Record) []int32 {
+	ids := make([]int32, 0, len(records))
+	for _, record := range records {
+		id, err := record.GetInt32FieldValue("id")
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// таблица со строками id = 0..n-1, group = id % 10
+func initTableWithSequentialRecords(t *testing.T, numRecords int, primaryKeys ...string) (
+	tableName string,
+	tableManager *TableManager,
+	clear func(),
+) {
+	tableName = "predicate_table"
+
+	columns := []*schema.Column{
+		{
+			Name: "id",
+			Type: schema.Int32Type,
+			Size: int(schema.Int32Size),
+		},
+		{
+			Name: "name",
+			Type: schema.StringType,
+			Size: schema.DynamicMemoTypeColumnSize,
+		},
+		{
+			Name: "group",
+			Type: schema.Int32Type,
+			Size: int(schema.Int32Size),
+		},
+		{
+			Name: "active",
+			Type: schema.BoolType,
+			Size: int(schema.BoolSize),
+		},
+	}
+
+	tableSchema := &schema.Schema{
+		ID:           "predicate_schema",
+		Hash:         "hashhash",
+		Columns:      columns,
+		PrimaryKeys:  primaryKeys,
+		NameToColumn: make(map[string]*schema.Column, len(columns)),
+	}
+	for _, column := range columns {
+		tableSchema.NameToColumn[column.Name] = column
+	}
+
+	tableManager, err := InitTableManager("./", &schema.SchemaManager{
+		IdToSchema: map[string]*schema.Schema{tableSchema.ID: tableSchema},
+	})
+	require.NoError(t, err)
+
+	_, err = tableManager.CreateNewTable(tableName, tableSchema)
+	require.NoError(t, err)
+
+	clear = func() {
+		// журнал не должен ссылаться на удаленные файлы
+		require.NoError(t, tableManager.Flush())
+		for _, tableIndex := range tableManager.NameToTable[tableName].Indexes {
+			require.NoError(t, os.Remove(tableIndex.Path))
+		}
+		require.NoError(t, os.Remove("./"+tableName+consts.DataExtension))
+		require.NoError(t, os.Remove("./"+tableName+consts.FsmExtension))
+		// файл переполнения появляется, только если строки выносили значения
+		if err := os.Remove("./" + tableName + consts.OverflowExtension); !errors.Is(err, os.ErrNotExist) {
+			require.NoError(t, err)
+		}
+		require.NoError(t, os.Remove("./"+tableName+consts.JsonExtension))
+	}
+
+	for i := 0; i < numRecords; i++ {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(i),
+			"name":   fmt.Sprintf("name_%04d", i),
+			"group":  int32(i % 10),
+			"active": i%2 == 0,
+		}))
+	}
+
+	return
+}
@@ -1,10 +1,19 @@
 package table
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
 
+	"github.com/artem-vildanov/small-db/internal/decimal"
+	"github.com/artem-vildanov/small-db/internal/jsondoc"
 	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/google/uuid"
 )
 
 const (
@@ -15,6 +24,14 @@ const (
 
 	FalseByte byte = 0
 	TrueByte  byte = 1
+
+	CharPaddingByte byte = ' '
+)
+
+// границы значений timestamp
+var (
+	minTimestamp = time.Unix(0, math.MinInt64)
+	maxTimestamp = time.Unix(0, math.MaxInt64)
 )
 
 type Record struct {
@@ -36,10 +53,16 @@ func (r *Record) addFields(fields ...*Field) {
 	}
 }
 
+// у null-значений в результате nil
 func (r *Record) IntoNameToValue() (map[string]any, error) {
 	nameToValue := make(map[string]any, len(r.Fields))
 	for _, field := range r.Fields {
-		v, err := deserializeValue(field.Column.Type, field.Value)
+		if field.Null {
+			nameToValue[field.Column.Name] = nil
+			continue
+		}
+
+		v, err := deserializeValue(field.Column, field.Value)
 		if err != nil {
 			return nil, fmt.Errorf("deserializeValue: %w", err)
 		}
@@ -51,57 +74,80 @@ func (r *Record) IntoNameToValue() (map[string]any, error) {
 }
 
 func (r *Record) GetInt32FieldValue(fieldName string) (int32, error) {
-	field, exists := r.ColumnNameToField[fieldName]
-	if !exists {
-		return 0, ErrNoSuchColumnInSchema(fieldName)
-	}
+	return getFieldValue[int32](r, fieldName)
+}
 
-	deserialized, err := deserializeValue(field.Column.Type, field.Value)
-	if err != nil {
-		return 0, fmt.Errorf("deserializeValue: %w", err)
-	}
+func (r *Record) GetStringFieldValue(fieldName string) (string, error) {
+	return getFieldValue[string](r, fieldName)
+}
 
-	casted, ok := deserialized.(int32)
-	if !ok {
-		return 0, ErrFailedToCast(field.Column.Type)
-	}
+func (r *Record) GetBoolFieldValue(fieldName string) (bool, error) {
+	return getFieldValue[bool](r, fieldName)
+}
 
-	return casted, nil
+func (r *Record) GetInt64FieldValue(fieldName string) (int64, error) {
+	return getFieldValue[int64](r, fieldName)
 }
 
-func (r *Record) GetStringFieldValue(fieldName string) (string, error) {
-	field, exists := r.ColumnNameToField[fieldName]
-	if !exists {
-		return "", ErrNoSuchColumnInSchema(fieldName)
-	}
+func (r *Record) GetFloat64FieldValue(fieldName string) (float64, error) {
+	return getFieldValue[float64](r, fieldName)
+}
 
-	deserialized, err := deserializeValue(field.Column.Type, field.Value)
-	if err != nil {
-		return "", fmt.Errorf("deserializeValue: %w", err)
-	}
+func (r *Record) GetBytesFieldValue(fieldName string) ([]byte, error) {
+	return getFieldValue[[]byte](r, fieldName)
+}
 
-	casted, ok := deserialized.(string)
-	if !ok {
-		return "", ErrFailedToCast(field.Column.Type)
-	}
+func (r *Record) GetUUIDFieldValue(fieldName string) (uuid.UUID, error) {
+	return getFieldValue[uuid.UUID](r, fieldName)
+}
 
-	return casted, nil
+func (r *Record) GetDecimalFieldValue(fieldName string) (decimal.Decimal, error) {
+	return getFieldValue[decimal.Decimal](r, fieldName)
 }
 
-func (r *Record) GetBoolFieldValue(fieldName string) (bool, error) {
+// документ из значений nil, bool, int64, float64, string,
+// []any и map[string]any
+func (r *Record) GetJSONFieldValue(fieldName string) (any, error) {
+	return getFieldValue[any](r, fieldName)
+}
+
+func (r *Record) GetInt32ArrayFieldValue(fieldName string) ([]int32, error) {
+	return getFieldValue[[]int32](r, fieldName)
+}
+
+func (r *Record) GetStringArrayFieldValue(fieldName string) ([]string, error) {
+	return getFieldValue[[]string](r, fieldName)
+}
+
+func (r *Record) GetBoolArrayFieldValue(fieldName string) ([]bool, error) {
+	return getFieldValue[[]bool](r, fieldName)
+}
+
+// время возвращается в UTC
+func (r *Record) GetTimestampFieldValue(fieldName string) (time.Time, error) {
+	return getFieldValue[time.Time](r, fieldName)
+}
+
+func getFieldValue[T any](r *Record, fieldName string) (T, error) {
+	var zero T
+
 	field, exists := r.ColumnNameToField[fieldName]
 	if !exists {
-		return false, ErrNoSuchColumnInSchema(fieldName)
+		return zero, ErrNoSuchColumnInSchema(fieldName)
 	}
 
-	deserialized, err := deserializeValue(field.Column.Type, field.Value)
+	if field.Null {
+		return zero, ErrFieldIsNull(fieldName)
+	}
+
+	deserialized, err := deserializeValue(field.Column, field.Value)
 	if err != nil {
-		return false, fmt.Errorf("deserializeValue: %w", err)
+		return zero, fmt.Errorf("deserializeValue: %w", err)
 	}
 
-	casted, ok := deserialized.(bool)
+	casted, ok := deserialized.(T)
 	if !ok {
-		return false, ErrFailedToCast(field.Column.Type)
+		return zero, ErrFailedToCast(field.Column.Type)
 	}
 
 	return casted, nil
@@ -115,7 +161,16 @@ func NewRecordInSchema(schema *schema.Schema, rawRecord map[string]any) (*Record
 			return nil, ErrNoSuchColumnInSchema(inputColumnName)
 		}
 
-		serializedValue, err := serializeValue(column.Type, inputValue)
+		if inputValue == nil {
+			if !column.Nullable {
+				return nil, ErrNotNullViolation(column.Name)
+			}
+
+			columnNameToField[column.Name] = &Field{Column: column, Null: true}
+			continue
+		}
+
+		serializedValue, err := serializeValue(column, inputValue)
 		if err != nil {
 			return nil, fmt.Errorf("serializeValue: %w", err)
 		}
@@ -132,9 +187,14 @@ func NewRecordInSchema(schema *schema.Schema, rawRecord map[string]any) (*Record
 
 	for _, column := range schema.Columns {
 		field, exists := columnNameToField[column.Name]
-		// todo реализовать default value
 		if !exists {
-			return nil, ErrFieldNotProvided(column.Name)
+			var err error
+
+			field, err = newOmittedField(column)
+			if err != nil {
+				return nil, err
+			}
+			columnNameToField[column.Name] = field
 		}
 
 		record.Fields = append(record.Fields, field)
@@ -145,26 +205,80 @@ func NewRecordInSchema(schema *schema.Schema, rawRecord map[string]any) (*Record
 	return record, nil
 }
 
-func serializeValue(columnType schema.ColumnType, raw any) ([]byte, error) {
-	switch columnType {
+// поле колонки, значение которой не передали: значение по умолчанию,
+// а без него null, если колонка это допускает
+func newOmittedField(column *schema.Column) (*Field, error) {
+	if defaultValue := column.DefaultValue(); defaultValue != nil {
+		serializedValue, err := serializeValue(column, defaultValue)
+		if err != nil {
+			return nil, fmt.Errorf("serializeValue: %w", err)
+		}
+
+		return &Field{Column: column, Value: serializedValue}, nil
+	}
+
+	if column.Nullable {
+		return &Field{Column: column, Null: true}, nil
+	}
+
+	return nil, ErrFieldNotProvided(column.Name)
+}
+
+func serializeValue(column *schema.Column, raw any) ([]byte, error) {
+	switch column.Type {
 	case schema.Int32Type:
 		return serializeInt32(raw)
 	case schema.StringType:
 		return serializeString(raw)
 	case schema.BoolType:
 		return serializeBool(raw)
+	case schema.Int64Type:
+		return serializeInt64(raw)
+	case schema.Float64Type:
+		return serializeFloat64(raw)
+	case schema.TimestampType:
+		return serializeTimestamp(raw)
+	case schema.BytesType:
+		return serializeBytes(raw)
+	case schema.UUIDType:
+		return serializeUUID(raw)
+	case schema.CharType:
+		return serializeChar(raw, column.Size)
+	case schema.DecimalType:
+		return serializeDecimal(raw, column)
+	case schema.JSONType:
+		return serializeJSON(raw)
+	case schema.Int32ArrayType, schema.StringArrayType, schema.BoolArrayType:
+		return serializeArray(column, raw)
+	case schema.EnumType:
+		return serializeEnum(raw, column)
 	default:
-		return nil, ErrUnexpectedType(columnType)
+		return nil, ErrUnexpectedType(column.Type)
 	}
 }
 
 func serializeInt32(raw any) ([]byte, error) {
-	serialized := make([]byte, 4)
-	intVal, ok := raw.(int32)
-	if !ok {
+	var intVal int32
+
+	switch v := raw.(type) {
+	case int32:
+		intVal = v
+	// нетипизированные константы в go имеют тип int
+	case int:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, ErrFailedToSerialize(schema.Int32Type)
+		}
+		intVal = int32(v)
+	case int64:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, ErrFailedToSerialize(schema.Int32Type)
+		}
+		intVal = int32(v)
+	default:
 		return nil, ErrFailedToSerialize(schema.Int32Type)
 	}
 
+	serialized := make([]byte, 4)
 	binary.BigEndian.PutUint32(serialized, uint32(intVal))
 	return serialized, nil
 }
@@ -191,16 +305,244 @@ func serializeBool(raw any) ([]byte, error) {
 	}
 }
 
-func deserializeValue(columnType schema.ColumnType, raw []byte) (any, error) {
-	switch columnType {
+func serializeInt64(raw any) ([]byte, error) {
+	var intVal int64
+
+	switch v := raw.(type) {
+	case int64:
+		intVal = v
+	case int:
+		intVal = int64(v)
+	case int32:
+		intVal = int64(v)
+	default:
+		return nil, ErrFailedToSerialize(schema.Int64Type)
+	}
+
+	return binary.BigEndian.AppendUint64(nil, uint64(intVal)), nil
+}
+
+func serializeFloat64(raw any) ([]byte, error) {
+	var floatVal float64
+
+	switch v := raw.(type) {
+	case float64:
+		floatVal = v
+	case float32:
+		floatVal = float64(v)
+	case int:
+		if !isExactFloat64(int64(v)) {
+			return nil, ErrFailedToSerialize(schema.Float64Type)
+		}
+		floatVal = float64(v)
+	case int32:
+		floatVal = float64(v)
+	case int64:
+		if !isExactFloat64(v) {
+			return nil, ErrFailedToSerialize(schema.Float64Type)
+		}
+		floatVal = float64(v)
+	default:
+		return nil, ErrFailedToSerialize(schema.Float64Type)
+	}
+
+	// у NaN нет места в порядке значений, по которому работают индексы
+	if math.IsNaN(floatVal) {
+		return nil, ErrFailedToSerialize(schema.Float64Type)
+	}
+
+	// -0 и 0 равны, поэтому хранятся одинаково
+	if floatVal == 0 {
+		floatVal = 0
+	}
+
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(floatVal)), nil
+}
+
+// целые по модулю больше 2^53 в float64 округляются
+func isExactFloat64(v int64) bool {
+	const maxExactInt = 1 << 53
+	return v >= -maxExactInt && v <= maxExactInt
+}
+
+// время хранится в наносекундах от начала эпохи unix в UTC,
+// поэтому часовой пояс значения не сохраняется
+func serializeTimestamp(raw any) ([]byte, error) {
+	timeVal, ok := raw.(time.Time)
+	if !ok {
+		return nil, ErrFailedToSerialize(schema.TimestampType)
+	}
+
+	// за пределами этого диапазона наносекунды не помещаются в int64
+	if timeVal.Before(minTimestamp) || timeVal.After(maxTimestamp) {
+		return nil, ErrFailedToSerialize(schema.TimestampType)
+	}
+
+	return binary.BigEndian.AppendUint64(nil, uint64(timeVal.UnixNano())), nil
+}
+
+func serializeBytes(raw any) ([]byte, error) {
+	bytesVal, ok := raw.([]byte)
+	if !ok {
+		return nil, ErrFailedToSerialize(schema.BytesType)
+	}
+
+	// значение не должно меняться вместе с буфером вызывающего
+	return bytes.Clone(bytesVal), nil
+}
+
+func serializeUUID(raw any) ([]byte, error) {
+	var uuidVal uuid.UUID
+
+	switch v := raw.(type) {
+	case uuid.UUID:
+		uuidVal = v
+	case [16]byte:
+		uuidVal = v
+	case string:
+		parsed, err := uuid.Parse(v)
+		if err != nil {
+			return nil, ErrFailedToSerialize(schema.UUIDType)
+		}
+		uuidVal = parsed
+	default:
+		return nil, ErrFailedToSerialize(schema.UUIDType)
+	}
+
+	return uuidVal[:], nil
+}
+
+// значение дополняется пробелами до длины колонки
+func serializeChar(raw any, size int) ([]byte, error) {
+	strVal, ok := raw.(string)
+	if !ok {
+		return nil, ErrFailedToSerialize(schema.CharType)
+	}
+
+	if len(strVal) > size {
+		return nil, ErrCharValueTooLong(len(strVal), size)
+	}
+
+	serialized := make([]byte, size)
+	copy(serialized, strVal)
+	for i := len(strVal); i < size; i++ {
+		serialized[i] = CharPaddingByte
+	}
+
+	return serialized, nil
+}
+
+// число округляется до масштаба колонки
+func serializeDecimal(raw any, column *schema.Column) ([]byte, error) {
+	var decimalVal decimal.Decimal
+
+	switch v := raw.(type) {
+	case decimal.Decimal:
+		decimalVal = v
+	case string:
+		parsed, err := decimal.Parse(v)
+		if err != nil {
+			return nil, ErrFailedToSerialize(schema.DecimalType)
+		}
+		decimalVal = parsed
+	case int:
+		decimalVal = decimal.New(int64(v), 0)
+	case int32:
+		decimalVal = decimal.New(int64(v), 0)
+	case int64:
+		decimalVal = decimal.New(v, 0)
+	// float64 не принимается: значение уже может быть неточным
+	default:
+		return nil, ErrFailedToSerialize(schema.DecimalType)
+	}
+
+	// лишние цифры после точки не округляются молча:
+	// 1.005 в decimal(10,2) - ошибка, а 1.000 - нет
+	rescaled := decimalVal.Rescale(column.Scale)
+	if !rescaled.Equal(decimalVal) {
+		return nil, ErrDecimalScaleOverflow(decimalVal, column.Precision, column.Scale)
+	}
+
+	if !rescaled.Fits(column.Precision) {
+		return nil, ErrDecimalOverflow(rescaled, column.Precision, column.Scale)
+	}
+
+	return decimal.AppendBinary(nil, rescaled, column.Precision)
+}
+
+// хранится номер метки в порядке объявления
+func serializeEnum(raw any, column *schema.Column) ([]byte, error) {
+	label, ok := raw.(string)
+	if !ok {
+		return nil, ErrFailedToSerialize(schema.EnumType)
+	}
+
+	ordinal := slices.Index(column.Labels, label)
+	if ordinal < 0 {
+		return nil, ErrUnknownEnumLabel(column.Name, label)
+	}
+
+	if column.Size == 1 {
+		return []byte{byte(ordinal)}, nil
+	}
+	return binary.BigEndian.AppendUint16(nil, uint16(ordinal)), nil
+}
+
+// строка или []byte - текст документа, остальные значения
+// приводятся к документу как есть
+func serializeJSON(raw any) ([]byte, error) {
+	var (
+		document any
+		err      error
+	)
+
+	switch v := raw.(type) {
+	case string:
+		document, err = jsondoc.Parse([]byte(v))
+	case []byte:
+		document, err = jsondoc.Parse(v)
+	case json.RawMessage:
+		document, err = jsondoc.Parse(v)
+	default:
+		document, err = jsondoc.FromGo(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return jsondoc.Encode(document)
+}
+
+func deserializeValue(column *schema.Column, raw []byte) (any, error) {
+	switch column.Type {
 	case schema.Int32Type:
 		return deserializeInt32(raw)
 	case schema.StringType:
 		return deserializeString(raw)
 	case schema.BoolType:
 		return deserializeBool(raw)
+	case schema.Int64Type:
+		return deserializeInt64(raw)
+	case schema.Float64Type:
+		return deserializeFloat64(raw)
+	case schema.TimestampType:
+		return deserializeTimestamp(raw)
+	case schema.BytesType:
+		return deserializeBytes(raw)
+	case schema.UUIDType:
+		return deserializeUUID(raw)
+	case schema.CharType:
+		return deserializeChar(raw)
+	case schema.DecimalType:
+		return decimal.FromBinary(raw, column.Scale), nil
+	case schema.JSONType:
+		return jsondoc.Decode(raw)
+	case schema.Int32ArrayType, schema.StringArrayType, schema.BoolArrayType:
+		return deserializeArray(column, raw)
+	case schema.EnumType:
+		return deserializeEnum(raw, column)
 	default:
-		return nil, ErrUnexpectedType(columnType)
+		return nil, ErrUnexpectedType(column.Type)
 	}
 }
 
@@ -208,6 +550,46 @@ func deserializeInt32(raw []byte) (int32, error) {
 	return int32(binary.BigEndian.Uint32(raw)), nil
 }
 
+func deserializeInt64(raw []byte) (int64, error) {
+	return int64(binary.BigEndian.Uint64(raw)), nil
+}
+
+func deserializeFloat64(raw []byte) (float64, error) {
+	return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+}
+
+func deserializeTimestamp(raw []byte) (time.Time, error) {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(raw))).UTC(), nil
+}
+
+func deserializeBytes(raw []byte) ([]byte, error) {
+	return bytes.Clone(raw), nil
+}
+
+func deserializeUUID(raw []byte) (uuid.UUID, error) {
+	return uuid.FromBytes(raw)
+}
+
+func deserializeEnum(raw []byte, column *schema.Column) (string, error) {
+	var ordinal int
+	if len(raw) == 1 {
+		ordinal = int(raw[0])
+	} else {
+		ordinal = int(binary.BigEndian.Uint16(raw))
+	}
+
+	if ordinal >= len(column.Labels) {
+		return "", ErrUnknownEnumOrdinal(column.Name, ordinal)
+	}
+
+	return column.Labels[ordinal], nil
+}
+
+// пробелы в конце значения char не значимы
+func deserializeChar(raw []byte) (string, error) {
+	return string(bytes.TrimRight(raw, string(CharPaddingByte))), nil
+}
+
 func deserializeString(raw []byte) (string, error) {
 	return string(raw), nil
 }
@@ -232,69 +614,188 @@ func ErrFailedToSerialize(t schema.ColumnType) error {
 	return fmt.Errorf("failed to serialize %s value", t)
 }
 
+func ErrCharValueTooLong(valueLen int, size int) error {
+	return fmt.Errorf("value of %d bytes is too long for char(%d)", valueLen, size)
+}
+
+func ErrDecimalOverflow(value decimal.Decimal, precision int, scale int) error {
+	return fmt.Errorf("value %s is out of range for decimal(%d,%d)", value, precision, scale)
+}
+
+func ErrDecimalScaleOverflow(value decimal.Decimal, precision int, scale int) error {
+	return fmt.Errorf(
+		"value %s has more than %d digits after the point for decimal(%d,%d)",
+		value,
+		scale,
+		precision,
+		scale,
+	)
+}
+
+func ErrUnknownEnumLabel(column string, label string) error {
+	return fmt.Errorf("unknown label %q for enum column %s", label, column)
+}
+
+func ErrUnknownEnumOrdinal(column string, ordinal int) error {
+	return fmt.Errorf("failed to deserialize enum value: column %s has no label with ordinal %d", column, ordinal)
+}
+
 func ErrFailedToDeserializeBool(actualBoolLen int) error {
 	return fmt.Errorf("failed to deserialize bool value: got unexpected value len %d", actualBoolLen)
 }
 
-func DeserializeRecordBySchema(bySchema *schema.Schema, data []byte) *Record {
-	var offset int
+func ErrNotNullViolation(column string) error {
+	return fmt.Errorf("null value in column %s violates not-null constraint", column)
+}
+
+func ErrFieldIsNull(fieldName string) error {
+	return fmt.Errorf("value of field %s is null", fieldName)
+}
+
+// значения, вынесенные в страницы переполнения, читаются из overflow
+func DeserializeRecordBySchema(
+	bySchema *schema.Schema,
+	data []byte,
+	overflow io.ReaderAt,
+) (*Record, error) {
 	record := &Record{
 		Fields:            make([]*Field, 0, len(bySchema.Columns)),
 		ColumnNameToField: make(map[string]*Field, len(bySchema.Columns)),
 	}
 
-	for _, column := range bySchema.Columns {
-		var size int
+	if err := splitRecord(bySchema, data, func(column *schema.Column, value []byte, external bool) error {
+		field := &Field{
+			Column: column,
+			Value:  value,
+			Null:   value == nil,
+		}
+
+		if external {
+			assembled, err := readOverflowValue(overflow, value)
+			if err != nil {
+				return fmt.Errorf("readOverflowValue: %w", err)
+			}
+
+			field.Value = assembled
+			field.overflowPointer = value
+		}
+
+		record.Fields = append(record.Fields, field)
+		record.ColumnNameToField[column.Name] = field
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// указатели на значения записи в страницах переполнения.
+// сами значения не читаются
+func overflowPointers(bySchema *schema.Schema, data []byte) [][]byte {
+	pointers := make([][]byte, 0)
+
+	splitRecord(bySchema, data, func(_ *schema.Column, value []byte, external bool) error {
+		if external {
+			pointers = append(pointers, value)
+		}
+		return nil
+	})
+
+	return pointers
+}
+
+// разбирает сериализованную запись на значения колонок.
+// у вынесенного значения вместо него передается указатель,
+// у null-значения передается nil
+func splitRecord(
+	bySchema *schema.Schema,
+	data []byte,
+	do func(column *schema.Column, value []byte, external bool) error,
+) error {
+	nullBitmap := data[:nullBitmapSize(len(bySchema.Columns))]
+	offset := len(nullBitmap)
+
+	for i, column := range bySchema.Columns {
+		if isNull(nullBitmap, i) {
+			if err := do(column, nil, false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var (
+			size     int
+			external bool
+		)
 
 		isDynamicMemoType := column.Size == schema.DynamicMemoTypeColumnSize
 		if isDynamicMemoType {
-			size = int(
-				binary.BigEndian.Uint16(data[offset:DynamicValuePrefixSize]),
-			)
+			prefix := binary.BigEndian.Uint16(data[offset : offset+DynamicValuePrefixSize])
 			offset += DynamicValuePrefixSize
+
+			external = prefix&externalValueFlag != 0
+			size = int(prefix &^ externalValueFlag)
 		} else {
 			size = column.Size
 		}
 
-		field := &Field{
-			Column: column,
-			Value:  data[offset : offset+size],
+		if err := do(column, data[offset:offset+size], external); err != nil {
+			return err
 		}
 
-		record.Fields = append(record.Fields, field)
-		record.ColumnNameToField[column.Name] = field
-
 		offset += size
 	}
 
-	return record
+	return nil
 }
 
+/*
+Сериализованная запись:
+
+Битовая карта null-значений (бит i - колонка i, 1 - null)
+Значения колонок не null по порядку схемы. значение с динамическим
+размером предваряет префикс с его длиной
+*/
 func (r *Record) Serialize() []byte {
-	var serializedLen int
+	serializedLen := nullBitmapSize(len(r.Fields))
 
 	for _, field := range r.Fields {
+		if field.Null {
+			continue
+		}
+
 		isDynamicMemoType := field.Column.Size == schema.DynamicMemoTypeColumnSize
 		if isDynamicMemoType {
-			serializedLen += len(field.Value) + DynamicValuePrefixSize
+			serializedLen += len(field.serializedValue()) + DynamicValuePrefixSize
 		} else {
 			serializedLen += field.Column.Size
 		}
 	}
 
-	serialized := make([]byte, 0, serializedLen)
+	serialized := make([]byte, nullBitmapSize(len(r.Fields)), serializedLen)
+
+	for i, field := range r.Fields {
+		if field.Null {
+			serialized[i/8] |= 1 << (i % 8)
+			continue
+		}
+
+		value := field.serializedValue()
 
-	for _, field := range r.Fields {
 		isDynamicMemoType := field.Column.Size == schema.DynamicMemoTypeColumnSize
 		if isDynamicMemoType {
-			// добавляем в начало значения префикс с длиной
+			// добавляем перед значением префикс с длиной
 			// размер префикса - 2 байта
-			prefix := make([]byte, 2)
-			binary.BigEndian.PutUint16(prefix, uint16(len(field.Value)))
-			field.Value = append(prefix, field.Value...)
+			prefix := uint16(len(value))
+			if field.overflowPointer != nil {
+				prefix |= externalValueFlag
+			}
+			serialized = binary.BigEndian.AppendUint16(serialized, prefix)
 		}
 
-		serialized = append(serialized, field.Value...)
+		serialized = append(serialized, value...)
 	}
 
 	return serialized
@@ -303,4 +804,24 @@ func (r *Record) Serialize() []byte {
 type Field struct {
 	Column *schema.Column
 	Value  []byte
+	Null   bool
+	// указатель на значение в страницах переполнения, если оно вынесено
+	overflowPointer []byte
+}
+
+// вынесенное значение хранится в записи указателем
+func (f *Field) serializedValue() []byte {
+	if f.overflowPointer != nil {
+		return f.overflowPointer
+	}
+
+	return f.Value
+}
+
+func nullBitmapSize(numColumns int) int {
+	return (numColumns + 7) / 8
+}
+
+func isNull(nullBitmap []byte, columnIndex int) bool {
+	return nullBitmap[columnIndex/8]&(1<<(columnIndex%8)) != 0
 }
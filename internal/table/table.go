@@ -11,11 +11,81 @@ type Table struct {
 	Name      string
 	NumPages  int
 	Schema    *schema.Schema
+	Indexes   []*Index
 	CreatedAt time.Time
+	// версия формата файла данных
+	FormatVersion uint16
+	// номера транзакций в файле таблицы не превышают эту границу
+	TxIDHorizon uint64
+	Stats       TableStats
+	// файл данных подменяется, и карта свободного места
+	// и индексы могут указывать на строки старого файла
+	RebuildPending bool
 }
 
 type TableMetadata struct {
-	SchemaID  string    `json:"schemaId"`
-	NumPages  int       `json:"numPages"`
-	CreatedAt time.Time `json:"createdAt"`
+	SchemaID string `json:"schemaId"`
+	// версия формата файла данных. у таблиц, созданных
+	// до ее появления, поля нет
+	FormatVersion uint16 `json:"formatVersion,omitempty"`
+	// размер страниц базы. у таблиц, созданных до того, как
+	// он стал настраиваемым, поля нет
+	PageSize    int        `json:"pageSize,omitempty"`
+	NumPages    int        `json:"numPages"`
+	Indexes     []*Index   `json:"indexes,omitempty"`
+	TxIDHorizon uint64     `json:"txIdHorizon,omitempty"`
+	Stats       TableStats `json:"stats"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	// полный вакуум прервался, и при загрузке карта свободного
+	// места и индексы строятся заново по файлу данных
+	RebuildPending bool `json:"rebuildPending,omitempty"`
+}
+
+// статистика версий строк в файле таблицы
+type TableStats struct {
+	LiveTuples int64 `json:"liveTuples"`
+	// удаленные версии, которые еще занимают место в файле
+	DeadTuples int64 `json:"deadTuples"`
+	// место, которое освободит вакуум, вместе с указателями
+	DeadBytes int64 `json:"deadBytes"`
+}
+
+// доля удаленных версий среди всех версий в файле
+func (s TableStats) BloatRatio() float64 {
+	total := s.LiveTuples + s.DeadTuples
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.DeadTuples) / float64(total)
+}
+
+// статистика приблизительная, например у таблиц, созданных до ее
+// появления, поэтому не опускается ниже нуля
+func (s *TableStats) add(delta TableStats) {
+	s.LiveTuples = max(s.LiveTuples+delta.LiveTuples, 0)
+	s.DeadTuples = max(s.DeadTuples+delta.DeadTuples, 0)
+	s.DeadBytes = max(s.DeadBytes+delta.DeadBytes, 0)
+}
+
+// имя индекса по первичному ключу, который создается вместе с таблицей
+const primaryKeyIndexName = "pk"
+
+// индекс по одной или нескольким колонкам таблицы
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	// индекс по первичному ключу, гарантирует уникальность
+	Primary bool   `json:"primary,omitempty"`
+	Path    string `json:"-"`
+}
+
+func (t *Table) primaryKeyIndex() *Index {
+	for _, tableIndex := range t.Indexes {
+		if tableIndex.Primary {
+			return tableIndex
+		}
+	}
+
+	return nil
 }
@@ -4,28 +4,74 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/index"
 	"github.com/artem-vildanov/small-db/internal/page"
 	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/artem-vildanov/small-db/internal/wal"
 )
 
 const recordsPreallocSize = 10
 const vacuumBloatTreshold = 0.3
 
+const walFileName = "journal" + consts.WalExtension
+
 type TableManager struct {
 	tableDirPath string
 	NameToTable  map[string]*Table
+	wal          *wal.Log
+	pool         *bufferPool
+	// размер страниц всех файлов базы
+	pageSize int
+
+	// защищает NameToTable, tableLocks, lastTxID, activeSnapshots и autovacuum
+	mu         sync.RWMutex
+	tableLocks map[string]*tableLock
+	lastTxID   uint64
+	// снимки незавершенных транзакций
+	activeSnapshots map[uint64]*snapshot
+
+	// коммиты выполняются по одному, чтобы не перемешивать журнал
+	commitMu    sync.Mutex
+	lockTimeout time.Duration
+
+	// фоновый вакуум, если запущен
+	autovacuum *autovacuum
+}
+
+// настройки базы
+type Config struct {
+	// размер страниц файлов таблиц и индексов. он записан в метаданных
+	// каждой таблицы и должен у всех совпадать. 0 - размер существующих
+	// таблиц, а у новой базы page.DefaultPageSize
+	PageSize int
 }
 
 func InitTableManager(
 	tableDirPath string,
 	schemaManager *schema.SchemaManager,
 ) (*TableManager, error) {
+	return InitTableManagerWithConfig(tableDirPath, schemaManager, Config{})
+}
+
+func InitTableManagerWithConfig(
+	tableDirPath string,
+	schemaManager *schema.SchemaManager,
+	config Config,
+) (*TableManager, error) {
+	if config.PageSize != 0 {
+		if err := page.ValidatePageSize(config.PageSize); err != nil {
+			return nil, err
+		}
+	}
+
 	entries, err := os.ReadDir(tableDirPath)
 	if err != nil {
 		return nil, fmt.Errorf("os.ReadDir: %w", err)
@@ -34,8 +80,23 @@ func InitTableManager(
 	tableManager := &TableManager{
 		tableDirPath: tableDirPath,
 		NameToTable:  make(map[string]*Table, len(entries)/2),
+		wal:          wal.NewLog(tableDirPath + walFileName),
+		pageSize:     config.PageSize,
+		tableLocks:   make(map[string]*tableLock, len(entries)/2),
+
+		activeSnapshots: make(map[uint64]*snapshot),
+		lockTimeout:     defaultLockTimeout,
 	}
 
+	// до загрузки метаданных дописываем в файлы
+	// изменения, которые не успели попасть в них до сбоя
+	recoveredFiles, err := tableManager.recover()
+	if err != nil {
+		return nil, fmt.Errorf("TableManager.recover: %w", err)
+	}
+
+	tableNameToSchemaID := make(map[string]string, len(entries)/2)
+
 	for _, entry := range entries {
 		isFile := entry.Type().IsRegular()
 		isMetadata := filepath.Ext(entry.Name()) == consts.JsonExtension
@@ -58,12 +119,65 @@ func InitTableManager(
 			return nil, fmt.Errorf("json.Unmarshal: %w", err)
 		}
 
+		for _, tableIndex := range metadata.Indexes {
+			tableIndex.Path = tableManager.getIndexFilePath(tableName, tableIndex.Name)
+		}
+
+		tableSchema, _ := schemaManager.GetSchema(metadata.SchemaID)
+
+		// таблица создана до появления версии в метаданных. граница
+		// номеров транзакций появилась вместе с заголовками версий строк,
+		// и без нее записи в файле хранятся без заголовков
+		formatVersion := metadata.FormatVersion
+		if formatVersion == page.RawRecordsFormatVersion && metadata.TxIDHorizon != 0 {
+			formatVersion = page.LegacyFormatVersion
+		}
+
+		// и до того, как размер страниц стал настраиваемым
+		pageSize := metadata.PageSize
+		if pageSize == 0 {
+			pageSize = page.DefaultPageSize
+		}
+
+		if tableManager.pageSize == 0 {
+			tableManager.pageSize = pageSize
+		} else if pageSize != tableManager.pageSize {
+			return nil, ErrTablePageSizeMismatch(tableName, pageSize, tableManager.pageSize)
+		}
+
 		tableManager.NameToTable[tableName] = &Table{
-			Path:      dataFilePath,
-			Name:      tableName,
-			NumPages:  metadata.NumPages,
-			CreatedAt: metadata.CreatedAt,
-			Schema:    schemaManager.IdToSchema[metadata.SchemaID],
+			Path:           dataFilePath,
+			Name:           tableName,
+			NumPages:       metadata.NumPages,
+			CreatedAt:      metadata.CreatedAt,
+			Schema:         tableSchema,
+			Indexes:        metadata.Indexes,
+			FormatVersion:  formatVersion,
+			TxIDHorizon:    metadata.TxIDHorizon,
+			Stats:          metadata.Stats,
+			RebuildPending: metadata.RebuildPending,
+		}
+		tableManager.tableLocks[tableName] = newTableLock()
+		tableNameToSchemaID[tableName] = metadata.SchemaID
+
+		// новые транзакции получают номера больше всех, что есть на диске
+		tableManager.lastTxID = max(tableManager.lastTxID, metadata.TxIDHorizon)
+	}
+
+	if tableManager.pageSize == 0 {
+		tableManager.pageSize = page.DefaultPageSize
+	}
+	tableManager.pool = newBufferPool(defaultBufferPoolSize, tableManager.pageSize)
+
+	if err := tableManager.finishRecovery(recoveredFiles); err != nil {
+		return nil, fmt.Errorf("TableManager.finishRecovery: %w", err)
+	}
+
+	// файлы обновляются после очистки журнала: его образы
+	// страниц записаны в старом формате
+	for tableName, schemaID := range tableNameToSchemaID {
+		if err := tableManager.openDataFile(tableManager.NameToTable[tableName], schemaID); err != nil {
+			return nil, fmt.Errorf("TableManager.openDataFile: %w", err)
 		}
 	}
 
@@ -71,36 +185,76 @@ func InitTableManager(
 }
 
 func (m *TableManager) CreateNewTable(tableName string, schema *schema.Schema) (*Table, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if _, exists := m.NameToTable[tableName]; exists {
 		return nil, ErrTableWithNameExists(tableName)
 	}
 
 	var (
-		createdAt         = time.Now()
+		createdAt         = time.Now().UTC()
 		dataPath     = m.getDataFilePath(tableName)
 		metadataPath = m.getMetadataFilePath(tableName)
 		table             = &Table{
-			Path:      dataPath,
-			Name:      tableName,
-			NumPages:  0,
-			Schema:    schema,
-			CreatedAt: createdAt,
+			Path:          dataPath,
+			Name:          tableName,
+			NumPages:      0,
+			Schema:        schema,
+			CreatedAt:     createdAt,
+			FormatVersion: page.CurrentFormatVersion,
 		}
 	)
 
-	// создаем пустой файл для данных
+	// создаем файл для данных, в котором пока только заголовок
 	dataFile, err := m.createIfNotExists(dataPath)
 	if err != nil {
 		return nil, fmt.Errorf("TableManager.createIfNotExists: %w", err)
 	}
 	defer dataFile.Close()
 
+	fileHeader, err := page.NewFileHeader(schema.ID, m.pageSize).Serialize()
+	if err != nil {
+		return nil, fmt.Errorf("FileHeader.Serialize: %w", err)
+	}
+
+	if _, err := dataFile.Write(fileHeader); err != nil {
+		return nil, fmt.Errorf("File.Write: %w", err)
+	}
+
+	// и пустую карту свободного места
+	fsmFile, err := m.createIfNotExists(m.getFsmFilePath(tableName))
+	if err != nil {
+		return nil, fmt.Errorf("TableManager.createIfNotExists: %w", err)
+	}
+	defer fsmFile.Close()
+
+	// индекс по первичному ключу поддерживается автоматически
+	if len(schema.PrimaryKeys) != 0 {
+		primaryKeyIndex := &Index{
+			Name:    primaryKeyIndexName,
+			Columns: schema.PrimaryKeys,
+			Primary: true,
+			Path:    m.getIndexFilePath(tableName, primaryKeyIndexName),
+		}
+
+		if err := m.buildIndex(table, primaryKeyIndex); err != nil {
+			return nil, fmt.Errorf("TableManager.buildIndex: %w", err)
+		}
+
+		table.Indexes = append(table.Indexes, primaryKeyIndex)
+	}
+
 	m.NameToTable[tableName] = table
+	m.tableLocks[tableName] = newTableLock()
 
 	tableMetadata := &TableMetadata{
-		SchemaID:  schema.ID,
-		NumPages:  0,
-		CreatedAt: createdAt,
+		SchemaID:      schema.ID,
+		FormatVersion: page.CurrentFormatVersion,
+		PageSize:      m.pageSize,
+		NumPages:      0,
+		Indexes:       table.Indexes,
+		CreatedAt:     createdAt,
 	}
 
 	metadataFile, err := m.createIfNotExists(metadataPath)
@@ -122,174 +276,214 @@ func (m *TableManager) CreateNewTable(tableName string, schema *schema.Schema) (
 }
 
 func (m *TableManager) Insert(tableName string, rawRecord map[string]any) error {
-	table, exists := m.NameToTable[tableName]
-	if !exists {
-		return ErrTableWithNameDoesntExist(tableName)
+	return m.autocommit(func(tx *Tx) error {
+		return tx.Insert(tableName, rawRecord)
+	})
+}
+
+// выполняет действие в отдельной транзакции
+func (m *TableManager) autocommit(do func(tx *Tx) error) error {
+	tx := m.Begin()
+	defer tx.Rollback()
+
+	if err := do(tx); err != nil {
+		return err
 	}
 
-	dataDescriptor, err := m.openFile(table.Path)
-	if err != nil {
-		return fmt.Errorf("TableManager.openFile: %w", err)
+	return tx.Commit()
+}
+
+// проверяет, что в таблице нет другой неудаленной версии строки
+// с таким же первичным ключом
+func (m *TableManager) checkUniqueConstraintViolation(
+	pager *pager,
+	table *Table,
+	record *Record,
+) error {
+	if len(table.Schema.PrimaryKeys) == 0 {
+		return nil
+	}
+
+	primaryKeyIndex := table.primaryKeyIndex()
+	if primaryKeyIndex == nil {
+		// таблица создана до появления индекса по первичному ключу
+		return m.checkUniqueConstraintViolationByPredicate(pager, table, record)
 	}
-	defer dataDescriptor.Close()
 
-	record, err := NewRecordInSchema(table.Schema, rawRecord)
+	key, err := encodeIndexKey(primaryKeyIndex, record)
 	if err != nil {
-		return fmt.Errorf("NewRecordInSchema: %w", err)
+		return fmt.Errorf("encodeIndexKey: %w", err)
 	}
 
-	if err := m.checkUniqueConstraintViolation(table, record); err != nil {
-		return fmt.Errorf("TableManager.checkUniqueConstraintViolation: %w", err)
+	// у укороченного ключа одинаковы только префикс и хеш,
+	// поэтому найденные строки сравниваются с записью целиком
+	if index.IsTruncatedKey(key) {
+		return m.checkUniqueConstraintViolationByPredicate(pager, table, record)
 	}
 
-	if err := m.insertRecord(
-		table,
-		dataDescriptor,
-		record,
-	); err != nil {
-		return fmt.Errorf("insertRecord: %w", err)
+	var locations []index.Location
+	if err := m.withIndex(pager, primaryKeyIndex, func(tree *index.BTree) error {
+		locations, err = tree.Find(key)
+		return err
+	}); err != nil {
+		return fmt.Errorf("TableManager.withIndex: %w", err)
 	}
 
-	return nil
-}
+	dataFile, err := pager.file(table.Path)
+	if err != nil {
+		return fmt.Errorf("pager.file: %w", err)
+	}
 
-func (m *TableManager) checkUniqueConstraintViolation(table *Table, record *Record) error {
-	pkToValue := make(map[string]any, len(table.Schema.PrimaryKeys))
-	for _, pk := range table.Schema.PrimaryKeys {
-		field := record.ColumnNameToField[pk]
-		value, err := deserializeValue(field.Column.Type, field.Value)
+	for _, location := range locations {
+		// в индексе остаются и удаленные версии строк.
+		// ключ занят, только если версия не удалена
+		header, exists, err := readTupleHeaderAt(dataFile, location.PageOffset, location.PointerIndex)
 		if err != nil {
-			return fmt.Errorf("deserializeValue: %w", err)
+			return fmt.Errorf("readTupleHeaderAt: %w", err)
 		}
 
-		pkToValue[pk] = value
+		if exists && isLive(header) {
+			return ErrUniqueConstraintViolation(table.Schema.PrimaryKeys)
+		}
 	}
 
-	violatedFields := make([]string, 0)
-	notUniqueRecs, err := m.FindByCondition(
-		table.Name,
-		func(r map[string]any) bool {
-			violationsCnt := 0
-
-			for _, pk := range table.Schema.PrimaryKeys {
-				if r[pk] != pkToValue[pk] {
-					if len(violatedFields) == violationsCnt {
-						violatedFields = append(violatedFields, pk)
-					} 
+	return nil
+}
 
-					violationsCnt++
-				}
-			}
+// ищет живые строки с тем же первичным ключом предикатом на равенство:
+// индекс, если он есть, только сужает выборку, а строки сравниваются целиком
+func (m *TableManager) checkUniqueConstraintViolationByPredicate(
+	pager *pager,
+	table *Table,
+	record *Record,
+) error {
+	predicate, err := primaryKeyPredicate(table, record)
+	if err != nil {
+		return fmt.Errorf("primaryKeyPredicate: %w", err)
+	}
 
-			return violationsCnt != 0
+	var violated bool
+	// уникальность проверяется среди последних версий строк,
+	// в том числе созданных после снимка транзакции
+	if err := m.doByCondition(
+		pager,
+		isLive,
+		table.Name,
+		predicate,
+		func(_ page.File, _ *Table, matches []*matchedCondition) error {
+			violated = len(matches) != 0
+			return nil
 		},
-	)
-	if err != nil {
-		return fmt.Errorf("TableManager.FindByCondition: %w", err)
+	); err != nil {
+		return fmt.Errorf("TableManager.doByCondition: %w", err)
 	}
 
-	if len(notUniqueRecs) != 0 {
-		return ErrUniqueConstraintViolation(violatedFields)
+	if violated {
+		return ErrUniqueConstraintViolation(table.Schema.PrimaryKeys)
 	}
 
 	return nil
 }
 
+// предикат на равенство всех колонок первичного ключа значениям из записи
+func primaryKeyPredicate(table *Table, record *Record) (*Predicate, error) {
+	operands := make([]*Predicate, 0, len(table.Schema.PrimaryKeys))
+	for _, pk := range table.Schema.PrimaryKeys {
+		field, exists := record.ColumnNameToField[pk]
+		if !exists {
+			return nil, ErrFieldNotProvided(pk)
+		}
+
+		value, err := deserializeValue(field.Column, field.Value)
+		if err != nil {
+			return nil, fmt.Errorf("deserializeValue: %w", err)
+		}
+
+		operands = append(operands, Eq(pk, value))
+	}
+
+	return And(operands...), nil
+}
+
+// вставляет версию строки, созданную транзакцией txID, на страницу,
+// которую подсказывает карта свободного места, и возвращает ее расположение
 func (m *TableManager) insertRecord(
+	pager *pager,
 	table *Table,
-	dataDescriptor *os.File,
+	txID uint64,
 	record *Record,
-) error {
-	serializedRecord := record.Serialize()
+) (index.Location, error) {
+	serializedRecord, err := m.serializeRecord(pager, table, record)
+	if err != nil {
+		return index.Location{}, fmt.Errorf("TableManager.serializeRecord: %w", err)
+	}
+	serializedRecord = newTuple(txID, serializedRecord)
 
-	dataFileInfo, err := dataDescriptor.Stat()
+	dataFile, err := pager.file(table.Path)
 	if err != nil {
-		return fmt.Errorf("File.Stat: %w", err)
+		return index.Location{}, fmt.Errorf("pager.file: %w", err)
 	}
 
-	tableIsEmpty := (dataFileInfo.Size() / page.PageSize) == 0
-	if tableIsEmpty {
-		page := page.NewEmptyPage()
+	fsm, err := m.openFreeSpaceMap(pager, table, dataFile)
+	if err != nil {
+		return index.Location{}, fmt.Errorf("TableManager.openFreeSpaceMap: %w", err)
+	}
 
-		if err := page.Insert(serializedRecord); err != nil {
-			return fmt.Errorf("Page.Insert: %w", err)
+	for {
+		pageOffset, found, err := fsm.find(len(serializedRecord))
+		if err != nil {
+			return index.Location{}, fmt.Errorf("freeSpaceMap.find: %w", err)
 		}
 
-		serializedPage := page.Serialize()
-
-		if _, err := dataDescriptor.Write(serializedPage); err != nil {
-			return fmt.Errorf("File.Write: %w", err)
+		// места нет ни на одной странице, поэтому
+		// создаем новую страницу в конце файла
+		tablePage := page.NewEmptyPage(m.pageSize)
+		if !found {
+			pageOffset = fsm.numPages * int64(m.pageSize)
+		} else if tablePage, err = page.ReadPageAt(dataFile, pageOffset, m.pageSize); err != nil {
+			return index.Location{}, fmt.Errorf("page.ReadPageAt: %w", err)
 		}
 
-		table.NumPages++
-		if err := m.atomicUpdateMetadata(table); err != nil {
-			return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
+		// карта приблизительная: если места все же нет,
+		// исправляем ее и ищем другую страницу
+		if found && !tablePage.FreeSpaceMoreThanRequired(len(serializedRecord)) {
+			if err := fsm.update(pageOffset, tablePage.FreeSpace()); err != nil {
+				return index.Location{}, fmt.Errorf("freeSpaceMap.update: %w", err)
+			}
+			continue
 		}
 
-		return nil
-	} else {
-		iterator, err := page.NewPagesIter(dataDescriptor)
+		pointerIndex, err := tablePage.Insert(serializedRecord)
 		if err != nil {
-			return fmt.Errorf("NewPagesIter: %w", err)
+			return index.Location{}, fmt.Errorf("Page.Insert: %w", err)
 		}
 
-		for iterator.Next() {
-			tablePage, err := iterator.GetPage()
-			if err != nil {
-				return fmt.Errorf("pagesIterator.GetPage: %w", err)
-			}
-
-			if !tablePage.FreeSpaceMoreThanRequired(len(serializedRecord)) {
-				continue
-			}
-
-			if err := tablePage.Insert(serializedRecord); err != nil {
-				return fmt.Errorf("Page.Insert: %w", err)
-			}
-
-			if _, err := dataDescriptor.WriteAt(
-				tablePage.Serialize(),
-				iterator.GetPageOffset(),
-			); err != nil {
-				return fmt.Errorf("os.File.WriteAt: %w", err)
-			}
-
-			break
+		if _, err := dataFile.WriteAt(tablePage.Serialize(), pageOffset); err != nil {
+			return index.Location{}, fmt.Errorf("File.WriteAt: %w", err)
 		}
 
-		// todo: покрыть корнер кейс тестом
-		if iterator.ReachedEnd() {
-			// если обошли все существующие страницы и не нашли достаточно места,
-			// то создаем новую страницу
-			tablePage := page.NewEmptyPage()
-
-			if err := tablePage.Insert(serializedRecord); err != nil {
-				return fmt.Errorf("Page.Insert: %w", err)
-			}
-
-			newPageOffset := iterator.GetPageOffset() + page.PageSize
-			if _, err := dataDescriptor.WriteAt(
-				tablePage.Serialize(),
-				newPageOffset,
-			); err != nil {
-				return fmt.Errorf("os.File.WriteAt: %w", err)
-			}
-
-			table.NumPages++
-			if err := m.atomicUpdateMetadata(table); err != nil {
-				return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
-			}
+		if err := fsm.update(pageOffset, tablePage.FreeSpace()); err != nil {
+			return index.Location{}, fmt.Errorf("freeSpaceMap.update: %w", err)
 		}
-	}
 
-	return nil
+		return index.Location{
+			PageOffset:   pageOffset,
+			PointerIndex: pointerIndex,
+		}, nil
+	}
 }
 
 func (m *TableManager) atomicUpdateMetadata(table *Table) error {
 	tableMetadata := &TableMetadata{
-		SchemaID:  table.Schema.ID,
-		NumPages:  table.NumPages,
-		CreatedAt: table.CreatedAt,
+		SchemaID:       table.Schema.ID,
+		FormatVersion:  table.FormatVersion,
+		PageSize:       m.pageSize,
+		NumPages:       table.NumPages,
+		Indexes:        table.Indexes,
+		TxIDHorizon:    table.TxIDHorizon,
+		Stats:          table.Stats,
+		CreatedAt:      table.CreatedAt,
+		RebuildPending: table.RebuildPending,
 	}
 
 	metadataMarshalled, err := json.Marshal(tableMetadata)
@@ -324,45 +518,45 @@ func (m *TableManager) atomicUpdateMetadata(table *Table) error {
 	return nil
 }
 
-func (m *TableManager) GetAllRecords(tableName string) ([]*Record, error) {
-	result := make([]*Record, 0, recordsPreallocSize)
-	if err := m.doByCondition(
-		tableName,
-		func(_ map[string]any) bool {
-			return true
-		},
-		func(_ *os.File, _ *Table, matches []*matchedCondition) error {
-			for _, matched := range matches {
-				result = append(result, matched.Record)
-			}
-			return nil
-		},
-	); err != nil {
-		return nil, fmt.Errorf("doByCondition: %w", err)
-	}
+func (m *TableManager) GetAllRecords(tableName string) (records []*Record, err error) {
+	err = m.autocommit(func(tx *Tx) error {
+		records, err = tx.GetAllRecords(tableName)
+		return err
+	})
 
-	return result, nil
+	return records, err
 }
 
 func (m *TableManager) FindByCondition(
 	tableName string,
 	match func(record map[string]any) bool,
 ) ([]*Record, error) {
-	result := make([]*Record, 0, recordsPreallocSize)
-	if err := m.doByCondition(
-		tableName,
-		match,
-		func(_ *os.File, _ *Table, matches []*matchedCondition) error {
-			for _, matched := range matches {
-				result = append(result, matched.Record)
-			}
-			return nil
-		},
-	); err != nil {
-		return nil, fmt.Errorf("doByCondition: %w", err)
-	}
+	return m.FindByPredicate(tableName, Func(match))
+}
 
-	return result, nil
+func (m *TableManager) FindByPredicate(
+	tableName string,
+	predicate *Predicate,
+) (records []*Record, err error) {
+	err = m.autocommit(func(tx *Tx) error {
+		records, err = tx.FindByPredicate(tableName, predicate)
+		return err
+	})
+
+	return records, err
+}
+
+// ищет строку по значениям всех колонок первичного ключа
+func (m *TableManager) GetByPrimaryKey(
+	tableName string,
+	primaryKey map[string]any,
+) (record *Record, err error) {
+	err = m.autocommit(func(tx *Tx) error {
+		record, err = tx.GetByPrimaryKey(tableName, primaryKey)
+		return err
+	})
+
+	return record, err
 }
 
 func (m *TableManager) UpdateByCondition(
@@ -370,86 +564,71 @@ func (m *TableManager) UpdateByCondition(
 	match func(record map[string]any) bool,
 	update func(record map[string]any),
 ) error {
-	metadataDescriptor, err := m.openFile(m.getMetadataFilePath(tableName))
-	if err != nil {
-		return fmt.Errorf("TableManager.openFile: %w", err)
-	}
-	defer metadataDescriptor.Close()
-
-	callback := func(dataDescriptor *os.File, table *Table, matches []*matchedCondition) error {
-		for _, matched := range matches {
-			nameToValue, err := matched.Record.IntoNameToValue()
-			if err != nil {
-				return fmt.Errorf("Record.IntoNameToValue: %w", err)
-			}
-
-			update(nameToValue)
-
-			updatedRecord, err := NewRecordInSchema(table.Schema, nameToValue)
-			if err != nil {
-				return fmt.Errorf("NewRecordInSchema: %w", err)
-			}
-
-			if err := m.insertRecord(
-				table,
-				dataDescriptor,
-				updatedRecord,
-			); err != nil {
-				return fmt.Errorf("TableManager.insertRecord: %w", err)
-			}
-
-			if err := m.markRowAsDeleted(dataDescriptor, matched); err != nil {
-				return fmt.Errorf("TableManager.markMatchedAsRemoved: %w", err)
-			}
-		}
-
-		return nil
-	}
-
-	if err := m.doByCondition(
-		tableName,
-		match,
-		callback,
-	); err != nil {
-		return fmt.Errorf("doByCondition: %w", err)
-	}
+	return m.UpdateByPredicate(tableName, Func(match), update)
+}
 
-	return nil
+// строки изменяются все вместе или ни одна:
+// при ошибке изменения не попадают в файлы
+func (m *TableManager) UpdateByPredicate(
+	tableName string,
+	predicate *Predicate,
+	update func(record map[string]any),
+) error {
+	return m.autocommit(func(tx *Tx) error {
+		return tx.UpdateByPredicate(tableName, predicate, update)
+	})
 }
 
 func (m *TableManager) DeleteByCondition(
 	tableName string,
 	match func(record map[string]any) bool,
 ) error {
-	callback := func(dataDescriptor *os.File, table *Table, matches []*matchedCondition) error {
-		for _, match := range matches {
-			if err := m.markRowAsDeleted(dataDescriptor, match); err != nil {
-				return fmt.Errorf("TableManager.markRowAsDeleted: %w", err)
-			}
-		}
-		return nil
+	return m.DeleteByPredicate(tableName, Func(match))
+}
+
+func (m *TableManager) DeleteByPredicate(
+	tableName string,
+	predicate *Predicate,
+) error {
+	return m.autocommit(func(tx *Tx) error {
+		return tx.DeleteByPredicate(tableName, predicate)
+	})
+}
+
+// проставляет Xmax версии строки. старые снимки продолжают
+// видеть версию, пока ее не удалит вакуум
+func (m *TableManager) markRowAsDeleted(
+	descriptor page.File,
+	txID uint64,
+	matched *matchedCondition,
+) error {
+	// страница читается заново: транзакция могла изменить ее
+	// после того, как строка была найдена
+	tablePage, err := page.ReadPageAt(descriptor, matched.PageOffset, m.pageSize)
+	if err != nil {
+		return fmt.Errorf("page.ReadPageAt: %w", err)
 	}
 
-	if err := m.doByCondition(tableName, match, callback); err != nil {
-		return fmt.Errorf("TableManager.doByCondition: %w", err)
+	if matched.PointerIndex >= len(tablePage.Pointers) {
+		return ErrConcurrentUpdate()
 	}
 
-	return nil
-}
+	pointer := tablePage.Pointers[matched.PointerIndex]
+	if pointer.Status != page.StatusActive {
+		return ErrConcurrentUpdate()
+	}
 
-func (m *TableManager) markRowAsDeleted(descriptor *os.File, matched *matchedCondition) error {
-	// устанавливаем статус deleted для указателя
-	pointerOffset := matched.PageOffset +
-		page.PageHeaderSize +
-		int64(matched.PointerIndex)*page.ItemPointerSize
+	// версию уже удалила или обновила транзакция,
+	// выполненная после снимка
+	tuple := tablePage.GetDataByPointer(pointer)
+	if !isLive(readTupleHeader(tuple)) {
+		return ErrConcurrentUpdate()
+	}
 
-	// Offset(2) + Size(2)
-	statusOffset := pointerOffset + 2 + 2
+	setTupleXmax(tuple, txID)
 
-	if _, err := descriptor.WriteAt(
-		[]byte{page.StatusDeleted},
-		statusOffset,
-	); err != nil {
+	// страница записывается целиком, чтобы пересчитать контрольную сумму
+	if _, err := descriptor.WriteAt(tablePage.Serialize(), matched.PageOffset); err != nil {
 		return fmt.Errorf("File.WriteAt: %w", err)
 	}
 
@@ -463,25 +642,66 @@ type matchedCondition struct {
 	Page         *page.Page
 }
 
+// visible решает, какие версии строк видны операции
 func (m *TableManager) doByCondition(
+	pager *pager,
+	visible func(tupleHeader) bool,
 	tableName string,
-	match func(record map[string]any) bool,
-	do func(*os.File, *Table, []*matchedCondition) error,
+	predicate *Predicate,
+	do func(page.File, *Table, []*matchedCondition) error,
 ) error {
-	table, exists := m.NameToTable[tableName]
-	if !exists {
-		return ErrTableWithNameDoesntExist(tableName)
+	table, _, err := m.getTable(tableName)
+	if err != nil {
+		return err
+	}
+
+	bound, err := predicate.bind(table.Schema)
+	if err != nil {
+		return fmt.Errorf("Predicate.bind: %w", err)
+	}
+
+	dataFile, err := pager.file(table.Path)
+	if err != nil {
+		return fmt.Errorf("pager.file: %w", err)
 	}
 
-	dataDescriptor, err := m.openFile(table.Path)
+	overflow, err := m.overflowReader(pager, table)
 	if err != nil {
-		return fmt.Errorf("TableManager.openFile: %w", err)
+		return fmt.Errorf("TableManager.overflowReader: %w", err)
+	}
+
+	var matches []*matchedCondition
+
+	if scans := chooseIndexScans(table, bound); scans != nil {
+		matches, err = m.matchByIndexScans(pager, visible, dataFile, overflow, table, scans, bound)
+		if err != nil {
+			return fmt.Errorf("TableManager.matchByIndexScans: %w", err)
+		}
+	} else {
+		matches, err = m.matchBySeqScan(visible, dataFile, overflow, table, bound)
+		if err != nil {
+			return fmt.Errorf("TableManager.matchBySeqScan: %w", err)
+		}
 	}
-	defer dataDescriptor.Close()
 
+	if err := do(dataFile, table, matches); err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+
+	return nil
+}
+
+// полный обход всех страниц таблицы
+func (m *TableManager) matchBySeqScan(
+	visible func(tupleHeader) bool,
+	dataDescriptor page.File,
+	overflow io.ReaderAt,
+	table *Table,
+	predicate *boundPredicate,
+) ([]*matchedCondition, error) {
 	iter, err := page.NewPagesIter(dataDescriptor)
 	if err != nil {
-		return fmt.Errorf("NewPagesIter: %w", err)
+		return nil, fmt.Errorf("NewPagesIter: %w", err)
 	}
 
 	matches := make([]*matchedCondition, 0, recordsPreallocSize)
@@ -489,56 +709,112 @@ func (m *TableManager) doByCondition(
 	for iter.Next() {
 		tablePage, err := iter.GetPage()
 		if err != nil {
-			return fmt.Errorf("pagesIterator.GetPage: %w", err)
+			return nil, fmt.Errorf("pagesIterator.GetPage: %w", err)
 		}
 
-		for pointerIndex, pointer := range tablePage.Pointers {
-			// пропускаем блоат
-			if pointer.Status != page.StatusActive {
-				continue
-			}
-
-			data := tablePage.GetDataByPointer(pointer)
-			record := DeserializeRecordBySchema(table.Schema, data)
-			nameToValue, err := record.IntoNameToValue()
+		for pointerIndex := range tablePage.Pointers {
+			matched, err := matchRow(
+				table,
+				overflow,
+				predicate,
+				visible,
+				tablePage,
+				iter.GetPageOffset(),
+				pointerIndex,
+			)
 			if err != nil {
-				return fmt.Errorf("Record.IntoNameToValue: %w", err)
+				return nil, fmt.Errorf("matchRow: %w", err)
 			}
 
-			if match(nameToValue) {
-				matches = append(matches, &matchedCondition{
-					Record:       record,
-					PointerIndex: pointerIndex,
-					PageOffset:   iter.GetPageOffset(),
-					Page:         tablePage,
-				})
+			if matched != nil {
+				matches = append(matches, matched)
 			}
 		}
 	}
 
-	if err := do(dataDescriptor, table, matches); err != nil {
-		return fmt.Errorf("do: %w", err)
+	return matches, nil
+}
+
+// проверяет строку по предикату. возвращает nil, если версия
+// строки не видна или не подходит под условие
+func matchRow(
+	table *Table,
+	overflow io.ReaderAt,
+	predicate *boundPredicate,
+	visible func(tupleHeader) bool,
+	tablePage *page.Page,
+	pageOffset int64,
+	pointerIndex int,
+) (*matchedCondition, error) {
+	pointer := tablePage.Pointers[pointerIndex]
+
+	// пропускаем блоат
+	if pointer.Status != page.StatusActive {
+		return nil, nil
 	}
 
-	return nil
+	tuple := tablePage.GetDataByPointer(pointer)
+	if !visible(readTupleHeader(tuple)) {
+		return nil, nil
+	}
+
+	record, err := DeserializeRecordBySchema(table.Schema, tupleRecordData(tuple), overflow)
+	if err != nil {
+		return nil, fmt.Errorf("DeserializeRecordBySchema: %w", err)
+	}
+
+	nameToValue, err := record.IntoNameToValue()
+	if err != nil {
+		return nil, fmt.Errorf("Record.IntoNameToValue: %w", err)
+	}
+
+	matched, err := predicate.match(nameToValue)
+	if err != nil {
+		return nil, fmt.Errorf("boundPredicate.match: %w", err)
+	}
+
+	if !matched {
+		return nil, nil
+	}
+
+	return &matchedCondition{
+		Record:       record,
+		PointerIndex: pointerIndex,
+		PageOffset:   pageOffset,
+		Page:         tablePage,
+	}, nil
 }
 
-func (m *TableManager) ShouldVacuum(tableName string) (bool, error) {
-	// todo: implement
-	return false, errors.New("not implemented")
+// решает, пора ли вакуумировать таблицу: доля удаленных версий
+// строк превышает vacuumBloatTreshold. статистика возвращается,
+// чтобы решение можно было принять и по своим правилам
+func (m *TableManager) ShouldVacuum(tableName string) (bool, TableStats, error) {
+	table, lock, err := m.getTable(tableName)
+	if err != nil {
+		return false, TableStats{}, err
+	}
+
+	lock.files.RLock()
+	stats := table.Stats
+	lock.files.RUnlock()
+
+	return stats.BloatRatio() > vacuumBloatTreshold, stats, nil
 }
 
-func (m *TableManager) FullVacuum(tableName string) error {
-	table, exists := m.NameToTable[tableName]
-	if !exists {
-		return ErrTableWithNameDoesntExist(tableName)
+func (m *TableManager) FullVacuum(tableName string) (err error) {
+	defer func() { page.SetCorruptedPageTable(err, tableName) }()
+
+	table, lock, err := m.lockTableWriter(tableName)
+	if err != nil {
+		return err
 	}
+	defer lock.unlockWriter()
 
-	dataDescriptor, err := m.openFile(table.Path)
+	// страницы читаются через пул: часть изменений может быть еще не на диске
+	dataFile, err := newPager(m.pool).file(table.Path)
 	if err != nil {
-		return fmt.Errorf("TableManager.openFile: %w", err)
+		return fmt.Errorf("pager.file: %w", err)
 	}
-	defer dataDescriptor.Close()
 
 	tmpDescriptor, err := os.CreateTemp(m.tableDirPath, tableName+".data.tmp")
 	if err != nil {
@@ -546,15 +822,27 @@ func (m *TableManager) FullVacuum(tableName string) error {
 	}
 	defer tmpDescriptor.Close()
 
-	iter, err := page.NewPagesIter(dataDescriptor)
+	iter, err := page.NewPagesIter(dataFile)
 	if err != nil {
 		return fmt.Errorf("NewPagesIter: %w", err)
 	}
 
+	fileHeader, err := page.NewFileHeader(table.Schema.ID, m.pageSize).Serialize()
+	if err != nil {
+		return fmt.Errorf("FileHeader.Serialize: %w", err)
+	}
+
+	if _, err := tmpDescriptor.WriteAt(fileHeader, 0); err != nil {
+		return fmt.Errorf("File.WriteAt: %w", err)
+	}
+
 	var (
 		numPages         int
-		bufferPage       = page.NewEmptyPage()
-		bufferPageOffset int64
+		stats            TableStats
+		bufferPage       = page.NewEmptyPage(m.pageSize)
+		bufferPageOffset = page.FirstDataPageOffset(m.pageSize)
+		// вынесенные значения удаленных версий
+		deadOverflowValues [][]byte
 	)
 
 	for iter.Next() {
@@ -568,9 +856,25 @@ func (m *TableManager) FullVacuum(tableName string) error {
 				continue
 			}
 
+			// версия нужна, пока ее видит хотя бы одна транзакция
 			data := oldPage.GetDataByPointer(ptr)
+			header := readTupleHeader(data)
+			if m.isDead(header) {
+				deadOverflowValues = append(
+					deadOverflowValues,
+					overflowPointers(table.Schema, tupleRecordData(data))...,
+				)
+				continue
+			}
+
+			if isLive(header) {
+				stats.LiveTuples++
+			} else {
+				stats.DeadTuples++
+				stats.DeadBytes += int64(len(data)) + page.ItemPointerSize
+			}
 
-			err := bufferPage.Insert(data)
+			_, err := bufferPage.Insert(data)
 			if err == nil {
 				continue
 			}
@@ -587,11 +891,11 @@ func (m *TableManager) FullVacuum(tableName string) error {
 				return fmt.Errorf("File.WriteAt: %w", err)
 			}
 
-			bufferPage = page.NewEmptyPage()
-			bufferPageOffset += page.PageSize
+			bufferPage = page.NewEmptyPage(m.pageSize)
+			bufferPageOffset += int64(m.pageSize)
 			numPages++
 
-			if err := bufferPage.Insert(data); err != nil {
+			if _, err := bufferPage.Insert(data); err != nil {
 				return fmt.Errorf("Page.Insert: %w", err)
 			}
 		}
@@ -604,30 +908,275 @@ func (m *TableManager) FullVacuum(tableName string) error {
 		); err != nil {
 			return fmt.Errorf("File.WriteAt: %w", err)
 		}
+		numPages++
 	}
 
 	if err := tmpDescriptor.Sync(); err != nil {
 		return fmt.Errorf("File.Sync: %w", err)
 	}
 
-	table.NumPages = numPages
+	// читатели не должны видеть новый файл данных со старыми индексами
+	lock.files.Lock()
+	defer lock.files.Unlock()
+
+	// в журнале не должно остаться образов страниц старого файла:
+	// при восстановлении они испортили бы новый
+	if err := m.Flush(); err != nil {
+		return fmt.Errorf("TableManager.Flush: %w", err)
+	}
+
+	// карта свободного места и индексы перестраиваются после подмены
+	// файла данных. если вакуум не дойдет до конца, их перестроит загрузка
+	table.RebuildPending = true
 	if err := m.atomicUpdateMetadata(table); err != nil {
 		return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
 	}
 
 	if err := os.Rename(
 		tmpDescriptor.Name(),
-		dataDescriptor.Name(),
+		table.Path,
 	); err != nil {
 		return fmt.Errorf("os.Rename: %w", err)
 	}
+	m.pool.invalidate(table.Path)
+
+	if err := m.syncTableDir(); err != nil {
+		return fmt.Errorf("TableManager.syncTableDir: %w", err)
+	}
+
+	table.NumPages = numPages
+	table.Stats = stats
+
+	if err := m.buildFreeSpaceMap(table); err != nil {
+		return fmt.Errorf("TableManager.buildFreeSpaceMap: %w", err)
+	}
+
+	// строки переехали на другие страницы
+	if err := m.rebuildIndexes(table); err != nil {
+		return fmt.Errorf("TableManager.rebuildIndexes: %w", err)
+	}
+
+	table.RebuildPending = false
+	if err := m.atomicUpdateMetadata(table); err != nil {
+		return fmt.Errorf("TableManager.atomicUpdateMetadata: %w", err)
+	}
+
+	// страницы значений освобождаются, только когда на них
+	// больше не ссылается файл данных: сбой до этого момента
+	// оставит их занятыми, но не испортит
+	if err := m.freeDeadOverflowValues(table, deadOverflowValues); err != nil {
+		return fmt.Errorf("TableManager.freeDeadOverflowValues: %w", err)
+	}
+
+	return nil
+}
+
+func (m *TableManager) freeDeadOverflowValues(table *Table, pointers [][]byte) error {
+	if len(pointers) == 0 {
+		return nil
+	}
+
+	pager := newPager(m.pool)
+	if err := m.freeOverflowValues(pager, table, pointers); err != nil {
+		return fmt.Errorf("TableManager.freeOverflowValues: %w", err)
+	}
+
+	txSnapshot := m.beginSnapshot()
+	defer m.endSnapshot(txSnapshot)
+
+	if err := m.commit(pager, txSnapshot.txID, []*Table{table}, nil); err != nil {
+		return fmt.Errorf("TableManager.commit: %w", err)
+	}
 
 	return nil
 }
 
+/*
+Вакуум, который не останавливает работу с таблицей.
+
+Страницы обрабатываются по одной, каждая в своей короткой транзакции:
+версии строк, которые уже не видит ни одна транзакция, убираются
+из индексов, их указатели помечаются удаленными, и страница
+уплотняется на месте. Номера указателей остальных версий
+не меняются, поэтому индексы остаются верными.
+
+Писатели ждут только обработку одной страницы, читатели не ждут.
+Файл не уменьшается: освобожденное место попадает в карту
+свободного места и занимается новыми строками.
+*/
 func (m *TableManager) ConcurrentVacuum(tableName string) error {
-	// todo: impelement
-	return errors.New("not implemented")
+	for pageOffset := page.FirstDataPageOffset(m.pageSize); ; pageOffset += int64(m.pageSize) {
+		processed, err := m.vacuumPage(tableName, pageOffset)
+		if err != nil {
+			return fmt.Errorf("TableManager.vacuumPage: %w", err)
+		}
+
+		if !processed {
+			return nil
+		}
+	}
+}
+
+// возвращает false, если страницы с таким смещением нет
+func (m *TableManager) vacuumPage(tableName string, pageOffset int64) (_ bool, err error) {
+	defer func() { page.SetCorruptedPageTable(err, tableName) }()
+
+	tx := m.Begin()
+	defer func() {
+		if !tx.done {
+			tx.Rollback()
+		}
+	}()
+
+	table, err := tx.lockForWrite(tableName)
+	if err != nil {
+		return false, err
+	}
+
+	dataFile, err := tx.pager.file(table.Path)
+	if err != nil {
+		return false, fmt.Errorf("pager.file: %w", err)
+	}
+
+	if pageOffset >= dataFile.size {
+		return false, nil
+	}
+
+	tablePage, err := page.ReadPageAt(dataFile, pageOffset, m.pageSize)
+	if err != nil {
+		return false, fmt.Errorf("page.ReadPageAt: %w", err)
+	}
+
+	pruned, err := m.prunePage(tx.pager, table, tablePage, pageOffset, tx.tableStats(tableName))
+	if err != nil {
+		return false, fmt.Errorf("TableManager.prunePage: %w", err)
+	}
+
+	// на странице нечего убирать
+	if !pruned {
+		return true, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("Tx.Commit: %w", err)
+	}
+
+	return true, nil
+}
+
+// убирает со страницы версии, которые не видит ни одна транзакция,
+// вместе с их ключами в индексах, и уплотняет страницу.
+// возвращает false, если таких версий нет
+func (m *TableManager) prunePage(
+	pager *pager,
+	table *Table,
+	tablePage *page.Page,
+	pageOffset int64,
+	stats *TableStats,
+) (bool, error) {
+	overflow, err := m.overflowReader(pager, table)
+	if err != nil {
+		return false, fmt.Errorf("TableManager.overflowReader: %w", err)
+	}
+
+	pruned := false
+	for pointerIndex, pointer := range tablePage.Pointers {
+		if pointer.Status != page.StatusActive {
+			continue
+		}
+
+		tuple := tablePage.GetDataByPointer(pointer)
+		if !m.isDead(readTupleHeader(tuple)) {
+			continue
+		}
+
+		record, err := DeserializeRecordBySchema(table.Schema, tupleRecordData(tuple), overflow)
+		if err != nil {
+			return false, fmt.Errorf("DeserializeRecordBySchema: %w", err)
+		}
+
+		location := index.Location{PageOffset: pageOffset, PointerIndex: pointerIndex}
+
+		if err := m.doWithIndexes(pager, table, record, func(tree *index.BTree, key []byte) error {
+			if err := tree.Delete(key, location); err != nil {
+				return fmt.Errorf("BTree.Delete: %w", err)
+			}
+			return nil
+		}); err != nil {
+			return false, fmt.Errorf("TableManager.doWithIndexes: %w", err)
+		}
+
+		if err := m.freeOverflowValues(
+			pager,
+			table,
+			overflowPointers(table.Schema, tupleRecordData(tuple)),
+		); err != nil {
+			return false, fmt.Errorf("TableManager.freeOverflowValues: %w", err)
+		}
+
+		pointer.Status = page.StatusDeleted
+		stats.DeadTuples--
+		stats.DeadBytes -= int64(pointer.Size) + page.ItemPointerSize
+		pruned = true
+	}
+
+	if !pruned {
+		return false, nil
+	}
+
+	tablePage.Compact()
+
+	dataFile, err := pager.file(table.Path)
+	if err != nil {
+		return false, fmt.Errorf("pager.file: %w", err)
+	}
+
+	if _, err := dataFile.WriteAt(tablePage.Serialize(), pageOffset); err != nil {
+		return false, fmt.Errorf("File.WriteAt: %w", err)
+	}
+
+	fsm, err := m.openFreeSpaceMap(pager, table, dataFile)
+	if err != nil {
+		return false, fmt.Errorf("TableManager.openFreeSpaceMap: %w", err)
+	}
+
+	if err := fsm.update(pageOffset, tablePage.FreeSpace()); err != nil {
+		return false, fmt.Errorf("freeSpaceMap.update: %w", err)
+	}
+
+	return true, nil
+}
+
+// перед записью новой версии строки убирает прошлые версии с ее
+// страницы, если новой версии может не хватить там места. так
+// частые обновления не увеличивают файл и без вакуума
+func (m *TableManager) pruneForUpdate(
+	pager *pager,
+	table *Table,
+	matched *matchedCondition,
+	stats *TableStats,
+) error {
+	dataFile, err := pager.file(table.Path)
+	if err != nil {
+		return fmt.Errorf("pager.file: %w", err)
+	}
+
+	tablePage, err := page.ReadPageAt(dataFile, matched.PageOffset, m.pageSize)
+	if err != nil {
+		return fmt.Errorf("page.ReadPageAt: %w", err)
+	}
+
+	// новая версия обычно того же размера, что и старая
+	pointer := tablePage.Pointers[matched.PointerIndex]
+	if tablePage.FreeSpaceMoreThanRequired(int(pointer.Size)) {
+		return nil
+	}
+
+	if _, err := m.prunePage(pager, table, tablePage, matched.PageOffset, stats); err != nil {
+		return fmt.Errorf("TableManager.prunePage: %w", err)
+	}
+
+	return nil
 }
 
 func (m *TableManager) openFile(filePath string) (*os.File, error) {
@@ -656,6 +1205,22 @@ func (m *TableManager) createIfNotExists(filePath string) (*os.File, error) {
 	return file, nil
 }
 
+// сбрасывает на диск каталог таблиц: без этого созданный
+// или переименованный файл может пропасть после сбоя питания
+func (m *TableManager) syncTableDir() error {
+	dir, err := os.Open(m.tableDirPath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer dir.Close()
+
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("File.Sync: %w", err)
+	}
+
+	return nil
+}
+
 func (m *TableManager) getDataFilePath(fileName string) string {
 	return fmt.Sprintf("%s%s%s", m.tableDirPath, fileName, consts.DataExtension)
 }
@@ -8,6 +8,8 @@ import (
 	"math/big"
 	"os"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -98,6 +100,7 @@ func Test_InitTableManager(t *testing.T) {
 	tableMeta1Json := `
 	{
 		"schemaId": "qwerqwer",
+		"formatVersion": 4,
 		"numPages": 12,
 		"createdAt": "2012-01-02T15:04:05Z"
 	}
@@ -106,6 +109,7 @@ func Test_InitTableManager(t *testing.T) {
 	tableMeta2Json := `
 	{
 		"schemaId": "zxvxcvzxzvc",
+		"formatVersion": 4,
 		"numPages": 12,
 		"createdAt": "2012-01-02T15:04:05Z"
 	}
@@ -114,33 +118,37 @@ func Test_InitTableManager(t *testing.T) {
 	tableMeta3Json := `
 	{
 		"schemaId": "asdfasdfasfas",
+		"formatVersion": 4,
 		"numPages": 1,
 		"createdAt": "2012-01-02T15:04:05Z"
 	}
 	`
 
 	table1 := &Table{
-		Path:      dataPath1,
-		Name:      tableName1,
-		NumPages:  12,
-		Schema:    schemaManager.IdToSchema[schemaID1],
-		CreatedAt: createdAt,
+		Path:          dataPath1,
+		Name:          tableName1,
+		NumPages:      12,
+		Schema:        schemaManager.IdToSchema[schemaID1],
+		CreatedAt:     createdAt,
+		FormatVersion: page.CurrentFormatVersion,
 	}
 
 	table2 := &Table{
-		Path:      dataPath2,
-		Name:      tableName2,
-		NumPages:  12,
-		Schema:    schemaManager.IdToSchema[schemaID2],
-		CreatedAt: createdAt,
+		Path:          dataPath2,
+		Name:          tableName2,
+		NumPages:      12,
+		Schema:        schemaManager.IdToSchema[schemaID2],
+		CreatedAt:     createdAt,
+		FormatVersion: page.CurrentFormatVersion,
 	}
 
 	table3 := &Table{
-		Path:      dataPath3,
-		Name:      tableName3,
-		NumPages:  1,
-		Schema:    schemaManager.IdToSchema[schemaID3],
-		CreatedAt: createdAt,
+		Path:          dataPath3,
+		Name:          tableName3,
+		NumPages:      1,
+		Schema:        schemaManager.IdToSchema[schemaID3],
+		CreatedAt:     createdAt,
+		FormatVersion: page.CurrentFormatVersion,
 	}
 
 	require.NoError(t, os.WriteFile(
@@ -159,10 +167,24 @@ func Test_InitTableManager(t *testing.T) {
 		consts.PosixAccessRight,
 	))
 
+	// файлы данных, в которых только заголовок
+	for dataPath, schemaID := range map[string]string{
+		dataPath1: schemaID1,
+		dataPath2: schemaID2,
+		dataPath3: schemaID3,
+	} {
+		fileHeader, err := page.NewFileHeader(schemaID, page.DefaultPageSize).Serialize()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dataPath, fileHeader, consts.PosixAccessRight))
+	}
+
 	defer func() {
 		require.NoError(t, os.Remove(metadataPath1))
 		require.NoError(t, os.Remove(metadataPath2))
 		require.NoError(t, os.Remove(metadataPath3))
+		require.NoError(t, os.Remove(dataPath1))
+		require.NoError(t, os.Remove(dataPath2))
+		require.NoError(t, os.Remove(dataPath3))
 	}()
 
 	tableManager, err := InitTableManager(tableDirPath, schemaManager)
@@ -211,13 +233,16 @@ func TestTableManager_CreateNewTable(t *testing.T) {
 	tableManager := &TableManager{
 		tableDirPath: tableDirPath,
 		NameToTable:  make(map[string]*Table, 0),
+		tableLocks:   make(map[string]*tableLock, 0),
+		pageSize:     page.DefaultPageSize,
 	}
 
 	expectedTable := &Table{
-		Path:     tableManager.getDataFilePath(tableName),
-		Name:     tableName,
-		NumPages: 0,
-		Schema:   schema,
+		Path:          tableManager.getDataFilePath(tableName),
+		Name:          tableName,
+		NumPages:      0,
+		Schema:        schema,
+		FormatVersion: page.CurrentFormatVersion,
 	}
 
 	gotTable, err := tableManager.CreateNewTable(tableName, schema)
@@ -226,6 +251,7 @@ func TestTableManager_CreateNewTable(t *testing.T) {
 	defer func() {
 		require.NoError(t, os.Remove(metadataPath))
 		require.NoError(t, os.Remove(dataPath))
+		require.NoError(t, os.Remove(tableManager.getFsmFilePath(tableName)))
 	}()
 
 	expectedTable.CreatedAt = gotTable.CreatedAt
@@ -238,7 +264,8 @@ func TestTableManager_CreateNewTable(t *testing.T) {
 	dataFileInfo, err := os.Stat(dataPath)
 	require.NoError(t, err)
 
-	assert.Equal(t, int64(0), dataFileInfo.Size())
+	// в новом файле данных только заголовок
+	assert.Equal(t, int64(page.DefaultPageSize), dataFileInfo.Size())
 	assert.NotEqual(t, int64(0), metadataFileInfo.Size())
 
 	metadataMarshalled, err := os.ReadFile(metadataPath)
@@ -248,6 +275,7 @@ func TestTableManager_CreateNewTable(t *testing.T) {
 	require.NoError(t, json.Unmarshal(metadataMarshalled, &metadata))
 
 	assert.Equal(t, metadata.SchemaID, expectedTable.Schema.ID)
+	assert.Equal(t, page.CurrentFormatVersion, metadata.FormatVersion)
 	assert.Equal(t, metadata.NumPages, expectedTable.NumPages)
 	assert.Equal(
 		t,
@@ -393,7 +421,9 @@ func TestTableManager_Insert(t *testing.T) {
 			require.NoError(t, err)
 
 			defer func() {
+				require.NoError(t, tableManager.Flush())
 				require.NoError(t, os.Remove(dataFilePath))
+				require.NoError(t, os.Remove(tableManager.getFsmFilePath(tableName)))
 				require.NoError(t, os.Remove(metadataFilePath))
 			}()
 
@@ -404,7 +434,7 @@ func TestTableManager_Insert(t *testing.T) {
 				info, err := os.Stat(dataFilePath)
 				require.NoError(t, err)
 
-				assert.Equal(t, int64(0), info.Size())
+				assert.Equal(t, int64(page.DefaultPageSize), info.Size())
 
 				return
 			}
@@ -564,6 +594,244 @@ func TestTableManager_UpdateByCondition(t *testing.T) {
 	})
 }
 
+func TestTableManager_PrimaryKey(t *testing.T) {
+	const recordsNum = 300
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+	require.Equal(t, 1, len(table.Indexes))
+	assert.Equal(t, &Index{
+		Name:    "pk",
+		Columns: []string{"id"},
+		Primary: true,
+		Path:    "./predicate_table.pk.index",
+	}, table.Indexes[0])
+
+	t.Run("поиск по первичному ключу", func(t *testing.T) {
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": 123})
+		require.NoError(t, err)
+
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "name_0123", name)
+	})
+
+	t.Run("запись не найдена", func(t *testing.T) {
+		_, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": recordsNum})
+		assert.EqualError(t, err, "record not found")
+	})
+
+	t.Run("не передана колонка ключа", func(t *testing.T) {
+		_, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"name": "name_0001"})
+		assert.EqualError(t, err, "field id not provided")
+	})
+
+	t.Run("вставка дубликата", func(t *testing.T) {
+		err := tableManager.Insert(tableName, map[string]any{
+			"id":     int32(10),
+			"name":   "duplicate",
+			"group":  int32(0),
+			"active": true,
+		})
+		assert.EqualError(
+			t,
+			err,
+			"TableManager.checkUniqueConstraintViolation: unique constraint violation on fields: [id]",
+		)
+	})
+
+	t.Run("обновление ключа на существующий", func(t *testing.T) {
+		err := tableManager.UpdateByPredicate(tableName, Eq("id", 1), func(r map[string]any) {
+			r["id"] = int32(2)
+		})
+		assert.ErrorContains(t, err, "unique constraint violation on fields: [id]")
+	})
+
+	t.Run("обновление строки без изменения ключа", func(t *testing.T) {
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", 1), func(r map[string]any) {
+			r["name"] = "updated"
+		}))
+
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": 1})
+		require.NoError(t, err)
+
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "updated", name)
+	})
+
+	t.Run("вставка после удаления", func(t *testing.T) {
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Eq("id", 5)))
+
+		_, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": 5})
+		assert.EqualError(t, err, "record not found")
+
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(5),
+			"name":   "reinserted",
+			"group":  int32(5),
+			"active": false,
+		}))
+
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": 5})
+		require.NoError(t, err)
+
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "reinserted", name)
+	})
+}
+
+func TestTableManager_LongIndexKeys(t *testing.T) {
+	tableName, tableManager := initTableWithColumns(t, []*schema.Column{
+		{Name: "code", Type: schema.StringType, Size: schema.DynamicMemoTypeColumnSize},
+		{Name: "note", Type: schema.StringType, Size: schema.DynamicMemoTypeColumnSize},
+	}, "code")
+
+	_, err := tableManager.CreateIndex(tableName, "by_note", []string{"note"})
+	require.NoError(t, err)
+
+	// ключи длиннее индексного, с общим началом
+	prefix := strings.Repeat("k", 2048)
+	codes := []string{prefix + "1", prefix + "2", prefix + "3"}
+	for i, code := range codes {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"code": code,
+			"note": fmt.Sprintf("%s_note_%d", prefix, i%2),
+		}))
+	}
+
+	getNote := func(t *testing.T, code string) string {
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"code": code})
+		require.NoError(t, err)
+
+		note, err := record.GetStringFieldValue("note")
+		require.NoError(t, err)
+		return note
+	}
+
+	getCodes := func(t *testing.T, predicate *Predicate) []string {
+		records, err := tableManager.FindByPredicate(tableName, predicate)
+		require.NoError(t, err)
+
+		codes := make([]string, 0, len(records))
+		for _, record := range records {
+			code, err := record.GetStringFieldValue("code")
+			require.NoError(t, err)
+			codes = append(codes, code)
+		}
+		return codes
+	}
+
+	t.Run("поиск по длинному первичному ключу", func(t *testing.T) {
+		assert.Equal(t, prefix+"_note_1", getNote(t, codes[1]))
+
+		_, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"code": prefix + "4"})
+		assert.EqualError(t, err, "record not found")
+	})
+
+	t.Run("дубликат длинного ключа", func(t *testing.T) {
+		err := tableManager.Insert(tableName, map[string]any{
+			"code": codes[2],
+			"note": "duplicate",
+		})
+		assert.ErrorContains(t, err, "unique constraint violation on fields: [code]")
+	})
+
+	t.Run("диапазон по длинным ключам", func(t *testing.T) {
+		assert.ElementsMatch(t, codes[1:], getCodes(t, Gt("code", codes[0])))
+		assert.ElementsMatch(t, codes[:2], getCodes(t, Lte("code", codes[1])))
+		assert.ElementsMatch(t, codes[1:2], getCodes(t, And(Gte("code", codes[1]), Lt("code", codes[2]))))
+	})
+
+	t.Run("длинные значения в индексе пользователя", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{codes[0], codes[2]}, getCodes(t, Eq("note", prefix+"_note_0")))
+
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("code", codes[0]), func(r map[string]any) {
+			r["note"] = prefix + "_note_1"
+		}))
+		assert.ElementsMatch(t, codes[:2], getCodes(t, Eq("note", prefix+"_note_1")))
+	})
+}
+
+func TestTableManager_FullVacuumInterrupted(t *testing.T) {
+	const recordsNum = 300
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+	_, err := tableManager.CreateIndex(tableName, "by_group", []string{"group"})
+	require.NoError(t, err)
+
+	// после вакуума оставшиеся строки переедут в начало файла
+	require.NoError(t, tableManager.DeleteByPredicate(tableName, Lt("id", recordsNum/2)))
+	require.NoError(t, tableManager.Flush())
+
+	staleIndexes := make(map[string][]byte, len(table.Indexes))
+	for _, tableIndex := range table.Indexes {
+		data, err := os.ReadFile(tableIndex.Path)
+		require.NoError(t, err)
+		staleIndexes[tableIndex.Path] = data
+	}
+
+	require.NoError(t, tableManager.FullVacuum(tableName))
+	require.NoError(t, tableManager.Flush())
+
+	// состояние после сбоя сразу после подмены файла данных: индекс
+	// по первичному ключу указывает на строки старого файла, а второго
+	// индекса нет
+	require.NoError(t, os.WriteFile(table.Indexes[0].Path, staleIndexes[table.Indexes[0].Path], consts.PosixAccessRight))
+	require.NoError(t, os.Remove(table.Indexes[1].Path))
+
+	marshalledMetadata, err := os.ReadFile(tableManager.getMetadataFilePath(tableName))
+	require.NoError(t, err)
+
+	var metadata TableMetadata
+	require.NoError(t, json.Unmarshal(marshalledMetadata, &metadata))
+	metadata.RebuildPending = true
+
+	marshalledMetadata, err = json.Marshal(&metadata)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(tableManager.getMetadataFilePath(tableName), marshalledMetadata, consts.PosixAccessRight))
+
+	reloaded, err := InitTableManager("./", &schema.SchemaManager{
+		IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, reloaded.Flush()) }()
+
+	t.Run("индексы перестроены при загрузке", func(t *testing.T) {
+		assert.False(t, reloaded.NameToTable[tableName].RebuildPending)
+
+		record, err := reloaded.GetByPrimaryKey(tableName, map[string]any{"id": int32(200)})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "name_0200", name)
+
+		records, err := reloaded.FindByPredicate(tableName, Eq("group", 3))
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum/2/10, len(records))
+
+		_, stats, err := reloaded.ShouldVacuum(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, TableStats{LiveTuples: recordsNum / 2}, stats)
+	})
+
+	t.Run("первичный ключ остается уникальным", func(t *testing.T) {
+		err := reloaded.Insert(tableName, map[string]any{
+			"id":     int32(200),
+			"name":   "duplicate",
+			"group":  int32(0),
+			"active": true,
+		})
+		assert.ErrorContains(t, err, "unique constraint violation on fields: [id]")
+	})
+}
+
 func TestTableManager_FullVacuum(t *testing.T) {
 	const (
 		columnName1                 = "schema1_col1"
@@ -638,8 +906,10 @@ func TestTableManager_FullVacuum(t *testing.T) {
 		require.NoError(t, err)
 
 		for _, ptr := range tablePage.Pointers {
-			data := tablePage.GetDataByPointer(ptr)
-			gotRecords = append(gotRecords, DeserializeRecordBySchema(table.Schema, data))
+			data := tupleRecordData(tablePage.GetDataByPointer(ptr))
+			record, err := DeserializeRecordBySchema(table.Schema, data, nil)
+			require.NoError(t, err)
+			gotRecords = append(gotRecords, record)
 		}
 	}
 
@@ -672,17 +942,221 @@ func TestTableManager_FullVacuum(t *testing.T) {
 	assert.Equal(t, expectActiveRecord3, gotActiveRecord3)
 }
 
-func TestTableManager_Vacuum(t *testing.T) {
-	// todo: implement
+func TestTableManager_ConcurrentVacuum(t *testing.T) {
+	const recordsNum = 10
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	assertIDs := func(t *testing.T, expected []int32) {
+		records, err := tableManager.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, expected, getRecordIDs(t, records))
+	}
+
+	t.Run("версии, которые видит транзакция, остаются", func(t *testing.T) {
+		tx := tableManager.Begin()
+		defer tx.Rollback()
+
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Lt("id", 3)))
+		require.NoError(t, tableManager.ConcurrentVacuum(tableName))
+		assert.Equal(t, recordsNum, countTuples(t, tableManager, tableName))
+
+		records, err := tx.GetAllRecords(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, recordsNum, len(records))
+	})
+
+	t.Run("удаленные версии убираются на месте", func(t *testing.T) {
+		tableFileSize := func(t *testing.T) int64 {
+			fileInfo, err := tableManager.pool.stat(tableManager.NameToTable[tableName].Path)
+			require.NoError(t, err)
+			return fileInfo.Size()
+		}
+		sizeBefore := tableFileSize(t)
+
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", 5), func(r map[string]any) {
+			r["name"] = "updated"
+		}))
+		require.NoError(t, tableManager.ConcurrentVacuum(tableName))
+
+		assert.Equal(t, recordsNum-3, countTuples(t, tableManager, tableName))
+		assert.Equal(t, sizeBefore, tableFileSize(t))
+		assertIDs(t, []int32{3, 4, 5, 6, 7, 8, 9})
+
+		_, stats, err := tableManager.ShouldVacuum(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, TableStats{LiveTuples: recordsNum - 3}, stats)
+
+		// удаленные строки убраны и из индекса
+		_, err = tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(1)})
+		assert.EqualError(t, err, ErrRecordNotFound().Error())
+
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(5)})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, "updated", name)
+
+		// освободившееся место занимают новые строки
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":     int32(1),
+			"name":   "reinserted",
+			"group":  int32(1),
+			"active": true,
+		}))
+		assert.Equal(t, sizeBefore, tableFileSize(t))
+		assertIDs(t, []int32{1, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("частые обновления не увеличивают файл без вакуума", func(t *testing.T) {
+		longName := strings.Repeat("x", 2000)
+		numPages := 0
+
+		for i := 0; i < 20; i++ {
+			require.NoError(t, tableManager.UpdateByPredicate(tableName, Eq("id", 9), func(r map[string]any) {
+				r["name"] = fmt.Sprintf("%s%d", longName, i)
+			}))
+
+			// обновление убирает прошлые версии со страницы,
+			// и их указатели и место занимают новые
+			if i == 0 {
+				numPages = tableManager.NameToTable[tableName].NumPages
+			}
+			assert.Equal(t, numPages, tableManager.NameToTable[tableName].NumPages)
+		}
+
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(9)})
+		require.NoError(t, err)
+		name, err := record.GetStringFieldValue("name")
+		require.NoError(t, err)
+		assert.Equal(t, longName+"19", name)
+
+		// вакууму остается только последняя старая версия
+		_, stats, err := tableManager.ShouldVacuum(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.DeadTuples)
+
+		require.NoError(t, tableManager.ConcurrentVacuum(tableName))
+		assert.Equal(t, recordsNum-2, countTuples(t, tableManager, tableName))
+		assertIDs(t, []int32{1, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("вакуум во время записи", func(t *testing.T) {
+		const updatesNum = 30
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updatesNum; i++ {
+				if err := tableManager.UpdateByPredicate(tableName, Gte("id", 6), func(r map[string]any) {
+					r["group"] = r["group"].(int32) + 1
+				}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updatesNum/3; i++ {
+				if err := tableManager.ConcurrentVacuum(tableName); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, tableManager.ConcurrentVacuum(tableName))
+		assert.Equal(t, recordsNum-2, countTuples(t, tableManager, tableName))
+
+		records, err := tableManager.FindByPredicate(tableName, Gte("id", 6))
+		require.NoError(t, err)
+		for _, record := range records {
+			id, err := record.GetInt32FieldValue("id")
+			require.NoError(t, err)
+			group, err := record.GetInt32FieldValue("group")
+			require.NoError(t, err)
+			assert.Equal(t, id%10+updatesNum, group)
+		}
+	})
 }
 
 func TestTableManager_ShouldVacuum(t *testing.T) {
-	// todo: implement
+	const recordsNum = 10
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	assertStats := func(t *testing.T, manager *TableManager, should bool, live, dead int64) TableStats {
+		gotShould, stats, err := manager.ShouldVacuum(tableName)
+		require.NoError(t, err)
+		assert.Equal(t, should, gotShould)
+		assert.Equal(t, live, stats.LiveTuples)
+		assert.Equal(t, dead, stats.DeadTuples)
+		return stats
+	}
+
+	t.Run("таблица без удаленных строк", func(t *testing.T) {
+		stats := assertStats(t, tableManager, false, recordsNum, 0)
+		assert.Equal(t, int64(0), stats.DeadBytes)
+	})
+
+	t.Run("откат не меняет статистику", func(t *testing.T) {
+		tx := tableManager.Begin()
+		require.NoError(t, tx.DeleteByPredicate(tableName, Lt("id", 5)))
+		require.NoError(t, tx.Rollback())
+
+		assertStats(t, tableManager, false, recordsNum, 0)
+	})
+
+	t.Run("удаление и обновление оставляют мертвые версии", func(t *testing.T) {
+		require.NoError(t, tableManager.DeleteByPredicate(tableName, Lt("id", 2)))
+		stats := assertStats(t, tableManager, false, recordsNum-2, 2)
+		assert.Greater(t, stats.DeadBytes, int64(2*page.ItemPointerSize))
+
+		require.NoError(t, tableManager.UpdateByPredicate(tableName, Lt("id", 4), func(r map[string]any) {
+			r["name"] = "updated"
+		}))
+		stats = assertStats(t, tableManager, true, recordsNum-2, 4)
+		assert.InDelta(t, 4.0/12.0, stats.BloatRatio(), 1e-9)
+	})
+
+	t.Run("статистика сохраняется в метаданных", func(t *testing.T) {
+		table := tableManager.NameToTable[tableName]
+		reloaded, err := InitTableManager("./", &schema.SchemaManager{
+			IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+		})
+		require.NoError(t, err)
+
+		assertStats(t, reloaded, true, recordsNum-2, 4)
+	})
+
+	t.Run("вакуум убирает мертвые версии", func(t *testing.T) {
+		require.NoError(t, tableManager.FullVacuum(tableName))
+
+		stats := assertStats(t, tableManager, false, recordsNum-2, 0)
+		assert.Equal(t, int64(0), stats.DeadBytes)
+	})
+
+	t.Run("несуществующая таблица", func(t *testing.T) {
+		_, _, err := tableManager.ShouldVacuum("unknown")
+		assert.Error(t, err)
+	})
 }
 
 func getTestSerializer(t *testing.T) func(schema.ColumnType, any) []byte {
 	return func(columnType schema.ColumnType, r any) []byte {
-		v, err := serializeValue(columnType, r)
+		v, err := serializeValue(&schema.Column{Type: columnType}, r)
 		require.NoError(t, err)
 		return v
 	}
@@ -874,7 +1348,10 @@ func initTableWithStaticRecords(t *testing.T) (
 	require.NoError(t, err)
 
 	clear = func(t *testing.T) {
+		// журнал не должен ссылаться на удаленные файлы
+		require.NoError(t, tableManager.Flush())
 		require.NoError(t, os.Remove(dataFilePath))
+		require.NoError(t, os.Remove(tableManager.getFsmFilePath(tableName)))
 		require.NoError(t, os.Remove(metadataFilePath))
 	}
 
@@ -1033,7 +1510,10 @@ func initTableWithRandRecords(
 	require.NoError(t, err)
 
 	clear = func() {
+		// журнал не должен ссылаться на удаленные файлы
+		require.NoError(t, tableManager.Flush())
 		require.NoError(t, os.Remove(dataFilePath))
+		require.NoError(t, os.Remove(tableManager.getFsmFilePath(tableName)))
 		require.NoError(t, os.Remove(metadataFilePath))
 	}
 
@@ -1056,3 +1536,133 @@ func initTableWithRandRecords(
 
 	return
 }
+
+func TestTableManager_CorruptedPage(t *testing.T) {
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, 500, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+	require.Greater(t, table.NumPages, 2)
+
+	// портим данные второй страницы со строками прямо на диске
+	require.NoError(t, tableManager.Flush())
+
+	descriptor, err := tableManager.openFile(table.Path)
+	require.NoError(t, err)
+	_, err = descriptor.WriteAt([]byte{0xFF}, 3*page.DefaultPageSize-1)
+	require.NoError(t, err)
+	require.NoError(t, descriptor.Close())
+
+	tableManager.pool.invalidate(table.Path)
+
+	_, err = tableManager.GetAllRecords(tableName)
+
+	var corrupted *page.ErrCorruptedPage
+	require.ErrorAs(t, err, &corrupted)
+	assert.Equal(t, tableName, corrupted.TableName)
+	assert.Equal(t, int64(2), corrupted.PageNum)
+
+	// строки с других страниц читаются через индекс
+	record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(0)})
+	require.NoError(t, err)
+	id, err := record.GetInt32FieldValue("id")
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), id)
+}
+
+func TestTableManager_PageSize(t *testing.T) {
+	const recordsNum = 300
+
+	columns := []*schema.Column{
+		{
+			Name: "id",
+			Type: schema.Int32Type,
+			Size: int(schema.Int32Size),
+		},
+		{
+			Name: "name",
+			Type: schema.StringType,
+			Size: schema.DynamicMemoTypeColumnSize,
+		},
+	}
+
+	tableSchema := &schema.Schema{
+		ID:           "page_size_schema",
+		Hash:         "hashhash",
+		Columns:      columns,
+		PrimaryKeys:  []string{"id"},
+		NameToColumn: make(map[string]*schema.Column, len(columns)),
+	}
+	for _, column := range columns {
+		tableSchema.NameToColumn[column.Name] = column
+	}
+
+	schemaManager := &schema.SchemaManager{
+		IdToSchema: map[string]*schema.Schema{tableSchema.ID: tableSchema},
+	}
+
+	for _, pageSize := range []int{page.MinPageSize, page.MaxPageSize} {
+		t.Run(fmt.Sprintf("страницы по %d байт", pageSize), func(t *testing.T) {
+			tableDirPath := t.TempDir() + "/"
+
+			tableManager, err := InitTableManagerWithConfig(tableDirPath, schemaManager, Config{PageSize: pageSize})
+			require.NoError(t, err)
+
+			table, err := tableManager.CreateNewTable("page_size_table", tableSchema)
+			require.NoError(t, err)
+
+			// каждая десятая строка выносит имя в страницы переполнения
+			for i := 0; i < recordsNum; i++ {
+				name := fmt.Sprintf("name_%04d", i)
+				if i%10 == 0 {
+					name = strings.Repeat("x", pageSize)
+				}
+
+				require.NoError(t, tableManager.Insert(table.Name, map[string]any{
+					"id":   int32(i),
+					"name": name,
+				}))
+			}
+
+			require.NoError(t, tableManager.DeleteByPredicate(table.Name, Lt("id", 100)))
+			require.NoError(t, tableManager.ConcurrentVacuum(table.Name))
+			require.NoError(t, tableManager.FullVacuum(table.Name))
+			require.NoError(t, tableManager.Flush())
+
+			dataFileInfo, err := os.Stat(table.Path)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), dataFileInfo.Size()%int64(pageSize))
+			assert.Equal(t, page.DataPagesNum(dataFileInfo.Size(), pageSize), table.NumPages)
+
+			// размер страниц берется из метаданных
+			reloaded, err := InitTableManager(tableDirPath, schemaManager)
+			require.NoError(t, err)
+
+			records, err := reloaded.GetAllRecords(table.Name)
+			require.NoError(t, err)
+			assert.Equal(t, recordsNum-100, len(records))
+
+			record, err := reloaded.GetByPrimaryKey(table.Name, map[string]any{"id": int32(250)})
+			require.NoError(t, err)
+			name, err := record.GetStringFieldValue("name")
+			require.NoError(t, err)
+			assert.Equal(t, strings.Repeat("x", pageSize), name)
+
+			_, err = InitTableManagerWithConfig(tableDirPath, schemaManager, Config{PageSize: page.DefaultPageSize})
+			assert.EqualError(
+				t,
+				err,
+				fmt.Sprintf(
+					"table page_size_table has page size %d, database page size is %d",
+					pageSize,
+					page.DefaultPageSize,
+				),
+			)
+		})
+	}
+
+	t.Run("недопустимый размер страницы", func(t *testing.T) {
+		_, err := InitTableManagerWithConfig(t.TempDir()+"/", schemaManager, Config{PageSize: 65536})
+		assert.EqualError(t, err, "page size 65536 must be a power of two from 4096 to 32768")
+	})
+}
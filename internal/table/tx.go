@@ -0,0 +1,441 @@
+package table
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/artem-vildanov/small-db/internal/page"
+)
+
+/*
+Транзакция из нескольких операций над одной или несколькими таблицами.
+
+Изменения транзакции держатся в ее pager и видны только ей самой.
+Commit записывает все изменения в журнал одной операцией, поэтому
+после сбоя они восстанавливаются целиком или не восстанавливаются вовсе.
+Rollback просто отбрасывает измененные страницы.
+
+Если операция упала, успев изменить часть строк, транзакция
+прерывается: дальше ее можно только откатить.
+
+Разные транзакции можно выполнять из разных горутин,
+но одну транзакцию нельзя использовать конкурентно.
+*/
+type Tx struct {
+	manager  *TableManager
+	pager    *pager
+	snapshot *snapshot
+	// таблицы, заблокированные транзакцией на запись
+	tables map[string]*Table
+	locks  map[string]*tableLock
+	// как транзакция изменила статистику таблиц
+	stats   map[string]*TableStats
+	done    bool
+	aborted bool
+}
+
+func (m *TableManager) Begin() *Tx {
+	return &Tx{
+		manager:  m,
+		pager:    newPager(m.pool),
+		snapshot: m.beginSnapshot(),
+		tables:   make(map[string]*Table),
+		locks:    make(map[string]*tableLock),
+		stats:    make(map[string]*TableStats),
+	}
+}
+
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone()
+	}
+
+	tx.done = true
+	defer tx.release()
+
+	if tx.aborted {
+		return ErrTxAborted()
+	}
+
+	names := make([]string, 0, len(tx.tables))
+	for tableName := range tx.tables {
+		names = append(names, tableName)
+	}
+	slices.Sort(names)
+
+	// пока страницы переносятся в файлы, читатели этих таблиц ждут
+	tables := make([]*Table, 0, len(names))
+	for _, tableName := range names {
+		tables = append(tables, tx.tables[tableName])
+
+		tx.locks[tableName].files.Lock()
+		defer tx.locks[tableName].files.Unlock()
+	}
+
+	if err := tx.manager.commit(tx.pager, tx.snapshot.txID, tables, tx.stats); err != nil {
+		return fmt.Errorf("TableManager.commit: %w", err)
+	}
+
+	return nil
+}
+
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone()
+	}
+
+	tx.done = true
+	tx.release()
+
+	return nil
+}
+
+func (tx *Tx) release() {
+	tx.pager.close()
+
+	for _, lock := range tx.locks {
+		lock.unlockWriter()
+	}
+
+	tx.manager.endSnapshot(tx.snapshot)
+}
+
+func (tx *Tx) Insert(tableName string, rawRecord map[string]any) (err error) {
+	defer func() { page.SetCorruptedPageTable(err, tableName) }()
+
+	table, err := tx.lockForWrite(tableName)
+	if err != nil {
+		return err
+	}
+
+	record, err := NewRecordInSchema(table.Schema, rawRecord)
+	if err != nil {
+		return fmt.Errorf("NewRecordInSchema: %w", err)
+	}
+
+	if err := tx.manager.checkUniqueConstraintViolation(tx.pager, table, record); err != nil {
+		return fmt.Errorf("TableManager.checkUniqueConstraintViolation: %w", err)
+	}
+
+	location, err := tx.manager.insertRecord(
+		tx.pager,
+		table,
+		tx.snapshot.txID,
+		record,
+	)
+	if err != nil {
+		tx.aborted = true
+		return fmt.Errorf("insertRecord: %w", err)
+	}
+
+	if err := tx.manager.addToIndexes(tx.pager, table, record, location); err != nil {
+		tx.aborted = true
+		return fmt.Errorf("TableManager.addToIndexes: %w", err)
+	}
+
+	tx.countInserted(tableName)
+
+	return nil
+}
+
+func (tx *Tx) GetAllRecords(tableName string) ([]*Record, error) {
+	return tx.FindByPredicate(tableName, Func(func(_ map[string]any) bool {
+		return true
+	}))
+}
+
+func (tx *Tx) FindByCondition(
+	tableName string,
+	match func(record map[string]any) bool,
+) ([]*Record, error) {
+	return tx.FindByPredicate(tableName, Func(match))
+}
+
+func (tx *Tx) FindByPredicate(
+	tableName string,
+	predicate *Predicate,
+) (_ []*Record, err error) {
+	defer func() { page.SetCorruptedPageTable(err, tableName) }()
+
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+
+	_, lock, err := tx.manager.getTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// таблицу, заблокированную транзакцией на запись, никто
+	// кроме нее не меняет. иначе ждем, пока чужой коммит
+	// перенесет страницы в файлы
+	if _, locked := tx.locks[tableName]; !locked {
+		lock.files.RLock()
+		defer lock.files.RUnlock()
+
+		// вакуум может подменить файлы таблицы между операциями
+		defer tx.pager.closeClean()
+	}
+
+	result := make([]*Record, 0, recordsPreallocSize)
+	if err := tx.manager.doByCondition(
+		tx.pager,
+		tx.snapshot.visible,
+		tableName,
+		predicate,
+		func(_ page.File, _ *Table, matches []*matchedCondition) error {
+			for _, matched := range matches {
+				result = append(result, matched.Record)
+			}
+			return nil
+		},
+	); err != nil {
+		return nil, fmt.Errorf("doByCondition: %w", err)
+	}
+
+	return result, nil
+}
+
+// ищет строку по значениям всех колонок первичного ключа
+func (tx *Tx) GetByPrimaryKey(
+	tableName string,
+	primaryKey map[string]any,
+) (*Record, error) {
+	table, err := tx.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(table.Schema.PrimaryKeys) == 0 {
+		return nil, ErrTableHasNoPrimaryKey(tableName)
+	}
+
+	operands := make([]*Predicate, 0, len(table.Schema.PrimaryKeys))
+	for _, pk := range table.Schema.PrimaryKeys {
+		value, exists := primaryKey[pk]
+		if !exists {
+			return nil, ErrFieldNotProvided(pk)
+		}
+
+		operands = append(operands, Eq(pk, value))
+	}
+
+	// условия на равенство всех колонок ключа
+	// выполняются через индекс по первичному ключу
+	records, err := tx.FindByPredicate(tableName, And(operands...))
+	if err != nil {
+		return nil, fmt.Errorf("Tx.FindByPredicate: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, ErrRecordNotFound()
+	}
+
+	return records[0], nil
+}
+
+func (tx *Tx) UpdateByCondition(
+	tableName string,
+	match func(record map[string]any) bool,
+	update func(record map[string]any),
+) error {
+	return tx.UpdateByPredicate(tableName, Func(match), update)
+}
+
+func (tx *Tx) UpdateByPredicate(
+	tableName string,
+	predicate *Predicate,
+	update func(record map[string]any),
+) (err error) {
+	defer func() { page.SetCorruptedPageTable(err, tableName) }()
+
+	if _, err := tx.lockForWrite(tableName); err != nil {
+		return err
+	}
+
+	callback := func(dataFile page.File, table *Table, matches []*matchedCondition) error {
+		for _, matched := range matches {
+			nameToValue, err := matched.Record.IntoNameToValue()
+			if err != nil {
+				return fmt.Errorf("Record.IntoNameToValue: %w", err)
+			}
+
+			update(nameToValue)
+
+			updatedRecord, err := NewRecordInSchema(table.Schema, nameToValue)
+			if err != nil {
+				return fmt.Errorf("NewRecordInSchema: %w", err)
+			}
+
+			// старую версию удаляем первой: так изменение строки
+			// другой транзакцией после снимка выявляется как конфликт
+			if err := tx.manager.markRowAsDeleted(dataFile, tx.snapshot.txID, matched); err != nil {
+				return fmt.Errorf("TableManager.markRowAsDeleted: %w", err)
+			}
+			tx.countDeleted(tableName, matched)
+
+			if err := tx.manager.pruneForUpdate(
+				tx.pager,
+				table,
+				matched,
+				tx.tableStats(tableName),
+			); err != nil {
+				return fmt.Errorf("TableManager.pruneForUpdate: %w", err)
+			}
+
+			if err := tx.manager.checkUniqueConstraintViolation(
+				tx.pager,
+				table,
+				updatedRecord,
+			); err != nil {
+				return fmt.Errorf("TableManager.checkUniqueConstraintViolation: %w", err)
+			}
+
+			location, err := tx.manager.insertRecord(
+				tx.pager,
+				table,
+				tx.snapshot.txID,
+				updatedRecord,
+			)
+			if err != nil {
+				return fmt.Errorf("TableManager.insertRecord: %w", err)
+			}
+
+			if err := tx.manager.addToIndexes(tx.pager, table, updatedRecord, location); err != nil {
+				return fmt.Errorf("TableManager.addToIndexes: %w", err)
+			}
+			tx.countInserted(tableName)
+		}
+
+		return nil
+	}
+
+	if err := tx.manager.doByCondition(
+		tx.pager,
+		tx.snapshot.visible,
+		tableName,
+		predicate,
+		tx.abortOnError(callback),
+	); err != nil {
+		return fmt.Errorf("doByCondition: %w", err)
+	}
+
+	return nil
+}
+
+func (tx *Tx) DeleteByCondition(
+	tableName string,
+	match func(record map[string]any) bool,
+) error {
+	return tx.DeleteByPredicate(tableName, Func(match))
+}
+
+func (tx *Tx) DeleteByPredicate(
+	tableName string,
+	predicate *Predicate,
+) (err error) {
+	defer func() { page.SetCorruptedPageTable(err, tableName) }()
+
+	if _, err := tx.lockForWrite(tableName); err != nil {
+		return err
+	}
+
+	callback := func(dataFile page.File, table *Table, matches []*matchedCondition) error {
+		for _, match := range matches {
+			if err := tx.manager.markRowAsDeleted(dataFile, tx.snapshot.txID, match); err != nil {
+				return fmt.Errorf("TableManager.markRowAsDeleted: %w", err)
+			}
+			tx.countDeleted(tableName, match)
+		}
+		return nil
+	}
+
+	if err := tx.manager.doByCondition(tx.pager, tx.snapshot.visible, tableName, predicate, tx.abortOnError(callback)); err != nil {
+		return fmt.Errorf("TableManager.doByCondition: %w", err)
+	}
+
+	return nil
+}
+
+// ошибка при изменении строк может оставить часть изменений,
+// поэтому после нее транзакцию нельзя закоммитить
+func (tx *Tx) abortOnError(
+	do func(page.File, *Table, []*matchedCondition) error,
+) func(page.File, *Table, []*matchedCondition) error {
+	return func(dataFile page.File, table *Table, matches []*matchedCondition) error {
+		if err := do(dataFile, table, matches); err != nil {
+			tx.aborted = true
+			return err
+		}
+		return nil
+	}
+}
+
+func (tx *Tx) check() error {
+	switch {
+	case tx.done:
+		return ErrTxDone()
+	case tx.aborted:
+		return ErrTxAborted()
+	default:
+		return nil
+	}
+}
+
+func (tx *Tx) table(tableName string) (*Table, error) {
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+
+	table, _, err := tx.manager.getTable(tableName)
+	return table, err
+}
+
+func (tx *Tx) countInserted(tableName string) {
+	tx.tableStats(tableName).LiveTuples++
+}
+
+// удаленная версия остается в файле до вакуума
+func (tx *Tx) countDeleted(tableName string, matched *matchedCondition) {
+	pointer := matched.Page.Pointers[matched.PointerIndex]
+
+	stats := tx.tableStats(tableName)
+	stats.LiveTuples--
+	stats.DeadTuples++
+	stats.DeadBytes += int64(pointer.Size) + page.ItemPointerSize
+}
+
+func (tx *Tx) tableStats(tableName string) *TableStats {
+	stats, exists := tx.stats[tableName]
+	if !exists {
+		stats = &TableStats{}
+		tx.stats[tableName] = stats
+	}
+
+	return stats
+}
+
+// блокирует таблицу на запись до конца транзакции
+func (tx *Tx) lockForWrite(tableName string) (*Table, error) {
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+
+	if table, locked := tx.tables[tableName]; locked {
+		return table, nil
+	}
+
+	table, lock, err := tx.manager.getTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !lock.lockWriter(tx.manager.lockTimeout) {
+		return nil, ErrLockTimeout(tableName)
+	}
+
+	tx.tables[tableName] = table
+	tx.locks[tableName] = lock
+
+	return table, nil
+}
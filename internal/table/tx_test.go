@@ -0,0 +1,137 @@
+package table
+
+import (
+	"os"
+	"testing"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTx(t *testing.T) {
+	const (
+		recordsNum   = 10
+		archiveTable = "archive_table"
+	)
+
+	tableName, tableManager, clear := initTableWithSequentialRecords(t, recordsNum, "id")
+	defer clear()
+
+	table := tableManager.NameToTable[tableName]
+
+	_, err := tableManager.CreateNewTable(archiveTable, table.Schema)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, tableManager.Flush())
+		for _, tableIndex := range tableManager.NameToTable[archiveTable].Indexes {
+			require.NoError(t, os.Remove(tableIndex.Path))
+		}
+		require.NoError(t, os.Remove("./"+archiveTable+consts.DataExtension))
+		require.NoError(t, os.Remove("./"+archiveTable+consts.FsmExtension))
+		require.NoError(t, os.Remove("./"+archiveTable+consts.JsonExtension))
+	}()
+
+	// переносит строку из одной таблицы в другую
+	moveRecord := func(t *testing.T, tx *Tx, id int32) {
+		record, err := tx.GetByPrimaryKey(tableName, map[string]any{"id": id})
+		require.NoError(t, err)
+
+		nameToValue, err := record.IntoNameToValue()
+		require.NoError(t, err)
+
+		require.NoError(t, tx.Insert(archiveTable, nameToValue))
+		require.NoError(t, tx.DeleteByPredicate(tableName, Eq("id", id)))
+	}
+
+	assertIDs := func(t *testing.T, manager *TableManager, name string, expected []int32) {
+		records, err := manager.GetAllRecords(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, getRecordIDs(t, records))
+	}
+
+	t.Run("коммит", func(t *testing.T) {
+		tx := tableManager.Begin()
+		moveRecord(t, tx, 1)
+		moveRecord(t, tx, 2)
+
+		// изменения видны внутри транзакции
+		records, err := tx.FindByPredicate(archiveTable, Lt("id", 10))
+		require.NoError(t, err)
+		assert.Equal(t, []int32{1, 2}, getRecordIDs(t, records))
+
+		// но не снаружи
+		assertIDs(t, tableManager, archiveTable, []int32{})
+		assertIDs(t, tableManager, tableName, []int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+		require.NoError(t, tx.Commit())
+
+		assertIDs(t, tableManager, archiveTable, []int32{1, 2})
+		assertIDs(t, tableManager, tableName, []int32{0, 3, 4, 5, 6, 7, 8, 9})
+
+		reloaded, err := InitTableManager("./", &schema.SchemaManager{
+			IdToSchema: map[string]*schema.Schema{table.Schema.ID: table.Schema},
+		})
+		require.NoError(t, err)
+
+		assertIDs(t, reloaded, archiveTable, []int32{1, 2})
+		assertIDs(t, reloaded, tableName, []int32{0, 3, 4, 5, 6, 7, 8, 9})
+		assert.Equal(t, 1, reloaded.NameToTable[archiveTable].NumPages)
+	})
+
+	t.Run("откат", func(t *testing.T) {
+		tx := tableManager.Begin()
+		moveRecord(t, tx, 3)
+		require.NoError(t, tx.UpdateByPredicate(tableName, Gte("id", 5), func(r map[string]any) {
+			r["name"] = "updated"
+		}))
+
+		require.NoError(t, tx.Rollback())
+
+		assertIDs(t, tableManager, archiveTable, []int32{1, 2})
+		assertIDs(t, tableManager, tableName, []int32{0, 3, 4, 5, 6, 7, 8, 9})
+
+		records, err := tableManager.FindByPredicate(tableName, Eq("name", "updated"))
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(records))
+	})
+
+	t.Run("ошибка прерывает транзакцию", func(t *testing.T) {
+		tx := tableManager.Begin()
+		moveRecord(t, tx, 4)
+
+		// ошибка проверки до изменений не прерывает транзакцию
+		err := tx.Insert(archiveTable, map[string]any{
+			"id":     int32(4),
+			"name":   "duplicate",
+			"group":  int32(0),
+			"active": true,
+		})
+		assert.ErrorContains(t, err, "unique constraint violation on fields: [id]")
+
+		// первая строка обновится, на второй нарушится уникальность
+		err = tx.UpdateByPredicate(tableName, Gte("id", 5), func(r map[string]any) {
+			r["id"] = int32(1000)
+		})
+		assert.ErrorContains(t, err, "unique constraint violation on fields: [id]")
+
+		_, err = tx.FindByPredicate(tableName, Eq("id", 1000))
+		assert.EqualError(t, err, "transaction is aborted, only rollback is allowed")
+
+		assert.EqualError(t, tx.Commit(), "transaction is aborted, only rollback is allowed")
+
+		assertIDs(t, tableManager, archiveTable, []int32{1, 2})
+		assertIDs(t, tableManager, tableName, []int32{0, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("завершенная транзакция", func(t *testing.T) {
+		tx := tableManager.Begin()
+		require.NoError(t, tx.Commit())
+
+		const txDoneErr = "transaction has already been committed or rolled back"
+
+		assert.EqualError(t, tx.DeleteByPredicate(tableName, Eq("id", 0)), txDoneErr)
+		assert.EqualError(t, tx.Rollback(), txDoneErr)
+	})
+}
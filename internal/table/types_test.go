@@ -0,0 +1,497 @@
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/artem-vildanov/small-db/internal/decimal"
+	"github.com/artem-vildanov/small-db/internal/schema"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager_NumericAndTimeTypes(t *testing.T) {
+	tableName, tableManager := initTableWithColumns(t, []*schema.Column{
+		{Name: "id", Type: schema.Int64Type, Size: int(schema.Int64Size)},
+		{Name: "amount", Type: schema.Float64Type, Size: int(schema.Float64Size)},
+		{Name: "created_at", Type: schema.TimestampType, Size: int(schema.TimestampSize)},
+	}, "id")
+
+	_, err := tableManager.CreateIndex(tableName, "by_amount", []string{"amount"})
+	require.NoError(t, err)
+	_, err = tableManager.CreateIndex(tableName, "by_created_at", []string{"created_at"})
+	require.NoError(t, err)
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	base := time.Date(2024, time.March, 1, 12, 0, 0, 123456789, time.UTC)
+
+	amounts := []float64{-1e10, -2.5, math.Copysign(0, -1), 0.25, 3, 1e10}
+	for i, amount := range amounts {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			// значения за пределами int32
+			"id":     int64(i) << 40,
+			"amount": amount,
+			// часовой пояс не важен, хранится момент времени
+			"created_at": base.Add(time.Duration(i) * time.Hour).In(moscow),
+		}))
+	}
+
+	getIDs := func(t *testing.T, records []*Record) []int64 {
+		ids := make([]int64, 0, len(records))
+		for _, record := range records {
+			id, err := record.GetInt64FieldValue("id")
+			require.NoError(t, err)
+			ids = append(ids, id>>40)
+		}
+		return ids
+	}
+
+	t.Run("значения читаются типизированными геттерами", func(t *testing.T) {
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int64(2) << 40})
+		require.NoError(t, err)
+
+		amount, err := record.GetFloat64FieldValue("amount")
+		require.NoError(t, err)
+		// -0 хранится как 0
+		assert.Equal(t, 0.0, amount)
+		assert.False(t, math.Signbit(amount))
+
+		createdAt, err := record.GetTimestampFieldValue("created_at")
+		require.NoError(t, err)
+		assert.Equal(t, base.Add(2*time.Hour), createdAt)
+		assert.Equal(t, time.UTC, createdAt.Location())
+
+		_, err = record.GetInt32FieldValue("id")
+		assert.EqualError(t, err, ErrFailedToCast(schema.Int64Type).Error())
+	})
+
+	t.Run("сравнения в порядке значений", func(t *testing.T) {
+		records, err := tableManager.FindByPredicate(tableName, Lt("amount", 0))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int64{0, 1}, getIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Eq("amount", math.Copysign(0, -1)))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int64{2}, getIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Gte("amount", 3))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int64{4, 5}, getIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, And(
+			Gt("created_at", base.Add(time.Hour)),
+			Lte("created_at", base.Add(4*time.Hour).In(moscow)),
+		))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int64{2, 3, 4}, getIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Gt("id", 3<<40))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int64{4, 5}, getIDs(t, records))
+	})
+
+	t.Run("недопустимые значения", func(t *testing.T) {
+		err := tableManager.Insert(tableName, map[string]any{
+			"id":         int64(100),
+			"amount":     math.NaN(),
+			"created_at": base,
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.Float64Type).Error())
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":         int64(100),
+			"amount":     1.0,
+			"created_at": time.Date(3000, time.January, 1, 0, 0, 0, 0, time.UTC),
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.TimestampType).Error())
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":         int64(100),
+			"amount":     1.0,
+			"created_at": base.Unix(),
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.TimestampType).Error())
+	})
+}
+
+func TestTableManager_BinaryTypes(t *testing.T) {
+	tableName, tableManager := initTableWithColumns(t, []*schema.Column{
+		{Name: "id", Type: schema.UUIDType, Size: int(schema.UUIDSize)},
+		{Name: "payload", Type: schema.BytesType, Size: schema.DynamicMemoTypeColumnSize},
+		{Name: "code", Type: schema.CharType, Size: 4},
+	}, "id")
+
+	_, err := tableManager.CreateIndex(tableName, "by_payload", []string{"payload"})
+	require.NoError(t, err)
+	_, err = tableManager.CreateIndex(tableName, "by_code", []string{"code"})
+	require.NoError(t, err)
+
+	ids := []uuid.UUID{
+		uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		uuid.MustParse("7f000000-0000-0000-0000-000000000000"),
+		uuid.MustParse("80000000-0000-0000-0000-000000000000"),
+		uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff"),
+	}
+	payloads := [][]byte{{}, {0x00}, {0x00, 0xFF}, bytes.Repeat([]byte{0xAB}, 500)}
+	codes := []string{"a", "ab", "abcd", "b"}
+
+	for i := range ids {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":      ids[i],
+			"payload": payloads[i],
+			"code":    codes[i],
+		}))
+	}
+
+	getIndices := func(t *testing.T, records []*Record) []int {
+		indices := make([]int, 0, len(records))
+		for _, record := range records {
+			id, err := record.GetUUIDFieldValue("id")
+			require.NoError(t, err)
+			indices = append(indices, slices.Index(ids, id))
+		}
+		return indices
+	}
+
+	t.Run("значения читаются типизированными геттерами", func(t *testing.T) {
+		// uuid можно передать строкой
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": ids[3].String()})
+		require.NoError(t, err)
+
+		payload, err := record.GetBytesFieldValue("payload")
+		require.NoError(t, err)
+		assert.Equal(t, payloads[3], payload)
+
+		// пробелы, которыми дополнено значение, не возвращаются
+		code, err := record.GetStringFieldValue("code")
+		require.NoError(t, err)
+		assert.Equal(t, "b", code)
+	})
+
+	t.Run("сравнения в порядке значений", func(t *testing.T) {
+		records, err := tableManager.FindByPredicate(tableName, Gte("id", ids[2]))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{2, 3}, getIndices(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Lt("payload", []byte{0x00, 0xFF}))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{0, 1}, getIndices(t, records))
+
+		// короткие значения дополняются пробелами, поэтому "ab" == "ab  "
+		records, err = tableManager.FindByPredicate(tableName, Eq("code", "ab  "))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{1}, getIndices(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, And(Gt("code", "a"), Lt("code", "b")))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{1, 2}, getIndices(t, records))
+	})
+
+	t.Run("недопустимые значения", func(t *testing.T) {
+		err := tableManager.Insert(tableName, map[string]any{
+			"id":      "not-a-uuid",
+			"payload": []byte{},
+			"code":    "c",
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.UUIDType).Error())
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":      uuid.New(),
+			"payload": "string",
+			"code":    "c",
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.BytesType).Error())
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":      uuid.New(),
+			"payload": []byte{},
+			"code":    "abcde",
+		})
+		assert.ErrorContains(t, err, ErrCharValueTooLong(5, 4).Error())
+	})
+}
+
+func TestTableManager_Decimal(t *testing.T) {
+	tableName, tableManager := initTableWithColumns(t, []*schema.Column{
+		{Name: "id", Type: schema.Int32Type, Size: int(schema.Int32Size)},
+		{
+			Name:      "price",
+			Type:      schema.DecimalType,
+			Size:      decimal.Size(8),
+			Precision: 8,
+			Scale:     2,
+		},
+		{
+			Name:      "balance",
+			Type:      schema.DecimalType,
+			Size:      decimal.Size(decimal.MaxPrecision),
+			Precision: decimal.MaxPrecision,
+		},
+	}, "id")
+
+	_, err := tableManager.CreateIndex(tableName, "by_price", []string{"price"})
+	require.NoError(t, err)
+
+	prices := []any{"-100.5", decimal.New(-1, 2), 0, "19.99", "20.000", int64(999999)}
+	for i, price := range prices {
+		require.NoError(t, tableManager.Insert(tableName, map[string]any{
+			"id":      int32(i),
+			"price":   price,
+			"balance": "-99999999999999999999999999999999999999",
+		}))
+	}
+
+	getPrice := func(t *testing.T, id int32) string {
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": id})
+		require.NoError(t, err)
+
+		price, err := record.GetDecimalFieldValue("price")
+		require.NoError(t, err)
+		return price.String()
+	}
+
+	t.Run("значения хранятся с масштабом колонки", func(t *testing.T) {
+		assert.Equal(t, "-100.50", getPrice(t, 0))
+		assert.Equal(t, "-0.01", getPrice(t, 1))
+		assert.Equal(t, "0.00", getPrice(t, 2))
+		// нули после точки сверх масштаба отбрасываются
+		assert.Equal(t, "20.00", getPrice(t, 4))
+
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(5)})
+		require.NoError(t, err)
+
+		nameToValue, err := record.IntoNameToValue()
+		require.NoError(t, err)
+		require.IsType(t, decimal.Decimal{}, nameToValue["balance"])
+		assert.Equal(t, "-99999999999999999999999999999999999999", nameToValue["balance"].(decimal.Decimal).String())
+	})
+
+	t.Run("сравнения в порядке чисел", func(t *testing.T) {
+		records, err := tableManager.FindByPredicate(tableName, Lt("price", 0))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 1}, getRecordIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, And(Gt("price", "0"), Lte("price", "20")))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{3, 4}, getRecordIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Eq("price", "19.990"))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{3}, getRecordIDs(t, records))
+	})
+
+	t.Run("число не помещается в колонку", func(t *testing.T) {
+		err := tableManager.Insert(tableName, map[string]any{
+			"id":      int32(100),
+			"price":   "1000000",
+			"balance": 0,
+		})
+		assert.ErrorContains(t, err, "value 1000000.00 is out of range for decimal(8,2)")
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":      int32(100),
+			"price":   "999999.999",
+			"balance": 0,
+		})
+		assert.ErrorContains(t, err, "value 999999.999 has more than 2 digits after the point for decimal(8,2)")
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":      int32(100),
+			"price":   19.99,
+			"balance": 0,
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.DecimalType).Error())
+	})
+
+	t.Run("лишние цифры после точки не округляются", func(t *testing.T) {
+		expectedErr := ErrDecimalScaleOverflow(decimal.New(1005, 3), 8, 2).Error()
+
+		err := tableManager.Insert(tableName, map[string]any{
+			"id":      int32(100),
+			"price":   "1.005",
+			"balance": 0,
+		})
+		assert.ErrorContains(t, err, expectedErr)
+
+		err = tableManager.UpdateByPredicate(tableName, Eq("id", 3), func(record map[string]any) {
+			record["price"] = decimal.New(1005, 3)
+		})
+		assert.ErrorContains(t, err, expectedErr)
+		assert.Equal(t, "19.99", getPrice(t, 3))
+
+		_, err = tableManager.FindByPredicate(tableName, Eq("price", "1.005"))
+		assert.ErrorContains(t, err, expectedErr)
+
+		_, err = tableManager.FindByPredicate(tableName, Lt("price", "19.995"))
+		assert.ErrorContains(t, err, ErrDecimalScaleOverflow(decimal.New(19995, 3), 8, 2).Error())
+	})
+}
+
+func TestTableManager_Enum(t *testing.T) {
+	manyLabels := make([]string, 300)
+	for i := range manyLabels {
+		manyLabels[i] = fmt.Sprintf("label_%d", i)
+	}
+
+	tableName, tableManager := initTableWithColumns(t, []*schema.Column{
+		{Name: "id", Type: schema.Int32Type, Size: int(schema.Int32Size)},
+		{
+			Name:   "status",
+			Type:   schema.EnumType,
+			Size:   schema.EnumSize(3),
+			Labels: []string{"new", "in_progress", "done"},
+		},
+		{
+			Name:     "label",
+			Type:     schema.EnumType,
+			Size:     schema.EnumSize(len(manyLabels)),
+			Labels:   manyLabels,
+			Nullable: true,
+		},
+	}, "id")
+
+	_, err := tableManager.CreateIndex(tableName, "by_status", []string{"status"})
+	require.NoError(t, err)
+
+	rows := []map[string]any{
+		{"status": "done", "label": "label_299"},
+		{"status": "new", "label": "label_0"},
+		{"status": "in_progress", "label": nil},
+		{"status": "new", "label": "label_256"},
+	}
+	for i, row := range rows {
+		row["id"] = int32(i)
+		require.NoError(t, tableManager.Insert(tableName, row))
+	}
+
+	t.Run("значения читаются метками", func(t *testing.T) {
+		record, err := tableManager.GetByPrimaryKey(tableName, map[string]any{"id": int32(0)})
+		require.NoError(t, err)
+
+		// номер метки занимает 1 и 2 байта
+		assert.Equal(t, []byte{2}, record.ColumnNameToField["status"].Value)
+		assert.Equal(t, []byte{1, 43}, record.ColumnNameToField["label"].Value)
+
+		status, err := record.GetStringFieldValue("status")
+		require.NoError(t, err)
+		assert.Equal(t, "done", status)
+
+		nameToValue, err := record.IntoNameToValue()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"id":     int32(0),
+			"status": "done",
+			"label":  "label_299",
+		}, nameToValue)
+	})
+
+	t.Run("сравнения в порядке объявления меток", func(t *testing.T) {
+		records, err := tableManager.FindByPredicate(tableName, Eq("status", "new"))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{1, 3}, getRecordIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Gt("status", "new"))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 2}, getRecordIDs(t, records))
+
+		records, err = tableManager.FindByPredicate(tableName, Gte("label", "label_256"))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int32{0, 3}, getRecordIDs(t, records))
+	})
+
+	t.Run("неизвестная метка", func(t *testing.T) {
+		err := tableManager.Insert(tableName, map[string]any{
+			"id":     int32(100),
+			"status": "Done",
+		})
+		assert.ErrorContains(t, err, ErrUnknownEnumLabel("status", "Done").Error())
+
+		err = tableManager.Insert(tableName, map[string]any{
+			"id":     int32(100),
+			"status": 2,
+		})
+		assert.ErrorContains(t, err, ErrFailedToSerialize(schema.EnumType).Error())
+
+		_, err = tableManager.FindByPredicate(tableName, Eq("status", "closed"))
+		assert.ErrorContains(t, err, ErrUnknownEnumLabel("status", "closed").Error())
+	})
+}
+
+func TestSerializeValue_IntegerLiterals(t *testing.T) {
+	testCases := []struct {
+		columnType schema.ColumnType
+		raw        any
+		expected   any
+	}{
+		// нетипизированные константы в go имеют тип int
+		{columnType: schema.Int32Type, raw: 7, expected: int32(7)},
+		{columnType: schema.Int32Type, raw: int64(math.MinInt32), expected: int32(math.MinInt32)},
+		{columnType: schema.Int64Type, raw: -7, expected: int64(-7)},
+		{columnType: schema.Int64Type, raw: int32(7), expected: int64(7)},
+		{columnType: schema.Float64Type, raw: 7, expected: float64(7)},
+		{columnType: schema.Float64Type, raw: int32(-7), expected: float64(-7)},
+		{columnType: schema.Float64Type, raw: int64(1) << 53, expected: float64(1 << 53)},
+	}
+
+	for _, tc := range testCases {
+		column := &schema.Column{Type: tc.columnType}
+
+		serialized, err := serializeValue(column, tc.raw)
+		require.NoError(t, err, tc)
+
+		deserialized, err := deserializeValue(column, serialized)
+		require.NoError(t, err, tc)
+		assert.Equal(t, tc.expected, deserialized, tc)
+	}
+
+	t.Run("значение не помещается в тип колонки", func(t *testing.T) {
+		testCases := []struct {
+			columnType schema.ColumnType
+			raw        any
+		}{
+			{columnType: schema.Int32Type, raw: math.MaxInt32 + 1},
+			{columnType: schema.Int32Type, raw: int64(math.MinInt32 - 1)},
+			// float64 не хранит такие целые точно
+			{columnType: schema.Float64Type, raw: int64(1)<<53 + 1},
+			{columnType: schema.Float64Type, raw: math.MinInt64},
+		}
+
+		for _, tc := range testCases {
+			_, err := serializeValue(&schema.Column{Type: tc.columnType}, tc.raw)
+			assert.EqualError(t, err, ErrFailedToSerialize(tc.columnType).Error(), tc)
+		}
+	})
+}
+
+// таблица с заданными колонками в отдельной директории
+func initTableWithColumns(t *testing.T, columns []*schema.Column, primaryKeys ...string) (string, *TableManager) {
+	const tableName = "typed_table"
+
+	tableSchema := &schema.Schema{
+		ID:           "typed_schema",
+		Hash:         "hashhash",
+		Columns:      columns,
+		PrimaryKeys:  primaryKeys,
+		NameToColumn: make(map[string]*schema.Column, len(columns)),
+	}
+	for _, column := range columns {
+		tableSchema.NameToColumn[column.Name] = column
+	}
+
+	tableManager, err := InitTableManager(t.TempDir()+"/", &schema.SchemaManager{
+		IdToSchema: map[string]*schema.Schema{tableSchema.ID: tableSchema},
+	})
+	require.NoError(t, err)
+
+	_, err = tableManager.CreateNewTable(tableName, tableSchema)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, tableManager.Flush()) })
+
+	return tableName, tableManager
+}
@@ -0,0 +1,5 @@
+package wal
+
+import "errors"
+
+var ErrCorruptedRecord = errors.New("corrupted wal record")
@@ -0,0 +1,261 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/artem-vildanov/small-db/internal/consts"
+)
+
+/*
+Журнал предзаписи (write-ahead log).
+
+Изменения страниц сначала дописываются в журнал и сбрасываются на диск,
+и только потом записываются в файлы данных. Операция считается
+выполненной, если в журнале есть ее запись CommitRecord.
+
+При восстановлении после сбоя образы страниц выполненных операций
+повторно записываются в файлы, а записи незавершенных операций
+отбрасываются: до коммита они не попадают в файлы данных.
+
+Запись в журнале:
+
+Length (4) - размер Payload
+Checksum (4) - CRC32 от Payload
+Payload:
+	Type (1)
+	TxID (8)
+	FileLen (2) + File
+	Offset (8)
+	ImageLen (4) + Image
+*/
+
+type RecordType byte
+
+const (
+	// образ страницы после изменения
+	PageImageRecord RecordType = 1
+	// операция выполнена, все ее образы страниц уже в журнале
+	CommitRecord RecordType = 2
+)
+
+// размеры в байтах
+const (
+	// Length (4) + Checksum (4)
+	recordHeaderSize = 4 + 4
+
+	// Type (1) + TxID (8) + FileLen (2) + Offset (8) + ImageLen (4)
+	recordPayloadFixedSize = 1 + 8 + 2 + 8 + 4
+)
+
+type Record struct {
+	Type   RecordType
+	TxID   uint64
+	File   string
+	Offset int64
+	Image  []byte
+}
+
+func NewPageImageRecord(txID uint64, file string, offset int64, image []byte) *Record {
+	return &Record{
+		Type:   PageImageRecord,
+		TxID:   txID,
+		File:   file,
+		Offset: offset,
+		Image:  image,
+	}
+}
+
+func NewCommitRecord(txID uint64) *Record {
+	return &Record{
+		Type: CommitRecord,
+		TxID: txID,
+	}
+}
+
+type Log struct {
+	path string
+}
+
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+func (l *Log) Path() string {
+	return l.path
+}
+
+// дописывает записи в конец журнала и сбрасывает их на диск
+func (l *Log) Append(records ...*Record) error {
+	descriptor, err := os.OpenFile(
+		l.path,
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		consts.PosixAccessRight,
+	)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+	defer descriptor.Close()
+
+	serialized := make([]byte, 0)
+	for _, record := range records {
+		serialized = append(serialized, record.Serialize()...)
+	}
+
+	if _, err := descriptor.Write(serialized); err != nil {
+		return fmt.Errorf("File.Write: %w", err)
+	}
+
+	if err := descriptor.Sync(); err != nil {
+		return fmt.Errorf("File.Sync: %w", err)
+	}
+
+	return nil
+}
+
+// читает все целые записи журнала. оборванный или поврежденный
+// хвост (сбой во время Append) отбрасывается
+func (l *Log) ReadAll() ([]*Record, error) {
+	serialized, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	records := make([]*Record, 0)
+	for offset := 0; offset < len(serialized); {
+		record, size, err := deserializeRecord(serialized[offset:])
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrCorruptedRecord) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("deserializeRecord: %w", err)
+		}
+
+		records = append(records, record)
+		offset += size
+	}
+
+	return records, nil
+}
+
+// размер журнала в байтах
+func (l *Log) Size() (int64, error) {
+	fileInfo, err := os.Stat(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("os.Stat: %w", err)
+	}
+
+	return fileInfo.Size(), nil
+}
+
+// очищает журнал, когда все изменения уже сброшены в файлы данных
+func (l *Log) Reset() error {
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+
+	return nil
+}
+
+// образы страниц выполненных операций в порядке записи в журнал
+func CommittedPageImages(records []*Record) []*Record {
+	committed := make(map[uint64]bool)
+	for _, record := range records {
+		if record.Type == CommitRecord {
+			committed[record.TxID] = true
+		}
+	}
+
+	images := make([]*Record, 0, len(records))
+	for _, record := range records {
+		if record.Type == PageImageRecord && committed[record.TxID] {
+			images = append(images, record)
+		}
+	}
+
+	return images
+}
+
+func (r *Record) Serialize() []byte {
+	payloadSize := recordPayloadFixedSize + len(r.File) + len(r.Image)
+	serialized := make([]byte, recordHeaderSize, recordHeaderSize+payloadSize)
+
+	serialized = append(serialized, byte(r.Type))
+	serialized = binary.BigEndian.AppendUint64(serialized, r.TxID)
+	serialized = binary.BigEndian.AppendUint16(serialized, uint16(len(r.File)))
+	serialized = append(serialized, r.File...)
+	serialized = binary.BigEndian.AppendUint64(serialized, uint64(r.Offset))
+	serialized = binary.BigEndian.AppendUint32(serialized, uint32(len(r.Image)))
+	serialized = append(serialized, r.Image...)
+
+	payload := serialized[recordHeaderSize:]
+	binary.BigEndian.PutUint32(serialized[0:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(serialized[4:], crc32.ChecksumIEEE(payload))
+
+	return serialized
+}
+
+// возвращает запись и ее полный размер в байтах
+func deserializeRecord(serialized []byte) (*Record, int, error) {
+	if len(serialized) < recordHeaderSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	payloadSize := int(binary.BigEndian.Uint32(serialized[0:]))
+	checksum := binary.BigEndian.Uint32(serialized[4:])
+
+	if len(serialized) < recordHeaderSize+payloadSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	payload := serialized[recordHeaderSize : recordHeaderSize+payloadSize]
+	if crc32.ChecksumIEEE(payload) != checksum || payloadSize < recordPayloadFixedSize {
+		return nil, 0, ErrCorruptedRecord
+	}
+
+	record := &Record{}
+
+	offset := 0
+	record.Type = RecordType(payload[offset])
+	offset += 1
+
+	record.TxID = binary.BigEndian.Uint64(payload[offset:])
+	offset += 8
+
+	fileLen := int(binary.BigEndian.Uint16(payload[offset:]))
+	offset += 2
+
+	if offset+fileLen+8+4 > len(payload) {
+		return nil, 0, ErrCorruptedRecord
+	}
+
+	record.File = string(payload[offset : offset+fileLen])
+	offset += fileLen
+
+	record.Offset = int64(binary.BigEndian.Uint64(payload[offset:]))
+	offset += 8
+
+	imageLen := int(binary.BigEndian.Uint32(payload[offset:]))
+	offset += 4
+
+	if offset+imageLen != len(payload) {
+		return nil, 0, ErrCorruptedRecord
+	}
+
+	if imageLen != 0 {
+		record.Image = make([]byte, imageLen)
+		copy(record.Image, payload[offset:])
+	}
+
+	return record, recordHeaderSize + payloadSize, nil
+}
@@ -0,0 +1,99 @@
+package wal
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_AppendReadAll(t *testing.T) {
+	log := createTestLog(t)
+
+	var (
+		image1 = []byte("first page image")
+		image2 = []byte("second page image")
+		image3 = []byte("uncommitted page image")
+	)
+
+	require.NoError(t, log.Append(
+		NewPageImageRecord(1, "users.data", 0, image1),
+		NewPageImageRecord(1, "users.pk.index", 8192, image2),
+		NewCommitRecord(1),
+	))
+	require.NoError(t, log.Append(
+		NewPageImageRecord(2, "users.data", 8192, image3),
+	))
+
+	t.Run("записи читаются в порядке добавления", func(t *testing.T) {
+		records, err := log.ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, 4, len(records))
+
+		assert.Equal(t, NewPageImageRecord(1, "users.data", 0, image1), records[0])
+		assert.Equal(t, NewPageImageRecord(1, "users.pk.index", 8192, image2), records[1])
+		assert.Equal(t, NewCommitRecord(1), records[2])
+		assert.Equal(t, NewPageImageRecord(2, "users.data", 8192, image3), records[3])
+	})
+
+	t.Run("образы незавершенных операций отбрасываются", func(t *testing.T) {
+		records, err := log.ReadAll()
+		require.NoError(t, err)
+
+		images := CommittedPageImages(records)
+		require.Equal(t, 2, len(images))
+		assert.Equal(t, image1, images[0].Image)
+		assert.Equal(t, image2, images[1].Image)
+	})
+
+	t.Run("оборванный хвост журнала", func(t *testing.T) {
+		tail := NewPageImageRecord(3, "users.data", 0, image1).Serialize()
+
+		descriptor, err := os.OpenFile(log.Path(), os.O_APPEND|os.O_WRONLY, 0)
+		require.NoError(t, err)
+		_, err = descriptor.Write(tail[:len(tail)-3])
+		require.NoError(t, err)
+		require.NoError(t, descriptor.Close())
+
+		records, err := log.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, 4, len(records))
+	})
+
+	t.Run("поврежденная запись", func(t *testing.T) {
+		serialized, err := os.ReadFile(log.Path())
+		require.NoError(t, err)
+
+		// портим последний байт образа первой записи
+		firstRecordSize := len(NewPageImageRecord(1, "users.data", 0, image1).Serialize())
+		serialized[firstRecordSize-1] ^= 0xFF
+		require.NoError(t, os.WriteFile(log.Path(), serialized, 0))
+
+		records, err := log.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(records))
+	})
+
+	t.Run("очистка журнала", func(t *testing.T) {
+		require.NoError(t, log.Reset())
+
+		records, err := log.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(records))
+
+		require.NoError(t, log.Reset())
+	})
+}
+
+func createTestLog(t *testing.T) *Log {
+	descriptor, err := os.CreateTemp("./", "test_wal")
+	require.NoError(t, err)
+	require.NoError(t, descriptor.Close())
+
+	t.Cleanup(func() {
+		os.Remove(descriptor.Name())
+	})
+
+	return NewLog(descriptor.Name())
+}